@@ -0,0 +1,236 @@
+package bus
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/st3v/cfkit/env"
+	"github.com/st3v/cfkit/service"
+	"github.com/streadway/amqp"
+)
+
+const (
+	RefreshEventType           = "RefreshRemoteApplicationEvent"
+	EnvironmentChangeEventType = "EnvironmentChangeRemoteApplicationEvent"
+	AckEventType               = "AckRemoteApplicationEvent"
+
+	eventClassPrefix = "org.springframework.cloud.bus.event."
+)
+
+var DefaultExchange = "springCloudBus"
+
+var (
+	channelProvider = rabbitChannel
+	now             = time.Now
+)
+
+func rabbitChannel(rabbit *service.RabbitMQ) (service.AMQPChannel, error) {
+	return rabbit.Channel()
+}
+
+type Event struct {
+	Type                  string            `json:"type"`
+	ID                    string            `json:"id"`
+	Timestamp             int64             `json:"timestamp"`
+	OriginService         string            `json:"originService"`
+	DestinationService    string            `json:"destinationService"`
+	Values                map[string]string `json:"values,omitempty"`
+	AckID                 string            `json:"ackId,omitempty"`
+	AckDestinationService string            `json:"ackDestinationService,omitempty"`
+	Event                 string            `json:"event,omitempty"`
+}
+
+type Callback func(e Event)
+
+type Client struct {
+	sequence  uint64
+	channel   service.AMQPChannel
+	app       env.App
+	id        string
+	exchange  string
+	mutex     sync.RWMutex
+	callbacks map[string][]Callback
+	closeOnce sync.Once
+	listeners sync.WaitGroup
+}
+
+func NewClient(rabbit *service.RabbitMQ, app env.App) (*Client, error) {
+	channel, err := channelProvider(rabbit)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening AMQP channel: %s", err)
+	}
+
+	client := &Client{
+		channel:   channel,
+		app:       app,
+		id:        ServiceID(app),
+		exchange:  DefaultExchange,
+		callbacks: map[string][]Callback{},
+	}
+
+	if err := channel.ExchangeDeclare(client.exchange, "topic", true, false, false, false, nil); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("Error declaring exchange '%s': %s", client.exchange, err)
+	}
+
+	return client, nil
+}
+
+// ServiceID follows the bus id Spring Cloud Bus uses on Cloud Foundry,
+// i.e. name:index:instance-id.
+func ServiceID(app env.App) string {
+	return fmt.Sprintf("%s:%d:%s", app.Name, app.Instance.Index, app.Instance.ID)
+}
+
+func (c *Client) ID() string {
+	return c.id
+}
+
+func (c *Client) Queue() string {
+	return fmt.Sprintf("%s.%s.%s", c.exchange, c.app.Name, c.app.Instance.ID)
+}
+
+func (c *Client) OnRefresh(cb Callback) {
+	c.register(RefreshEventType, cb)
+}
+
+func (c *Client) OnEnvironmentChange(cb Callback) {
+	c.register(EnvironmentChangeEventType, cb)
+}
+
+func (c *Client) OnAck(cb Callback) {
+	c.register(AckEventType, cb)
+}
+
+func (c *Client) Listen() error {
+	queue := c.Queue()
+
+	if _, err := c.channel.QueueDeclare(queue, false, true, true, false, nil); err != nil {
+		return fmt.Errorf("Error declaring queue '%s': %s", queue, err)
+	}
+
+	if err := c.channel.QueueBind(queue, "#", c.exchange, false, nil); err != nil {
+		return fmt.Errorf("Error binding queue '%s': %s", queue, err)
+	}
+
+	deliveries, err := c.channel.Consume(queue, c.id, true, true, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("Error consuming from queue '%s': %s", queue, err)
+	}
+
+	c.listeners.Add(1)
+	go c.dispatch(deliveries)
+
+	return nil
+}
+
+func (c *Client) PublishRefresh(destination string) error {
+	e := c.newEvent(RefreshEventType, destination)
+	return c.Publish(e)
+}
+
+func (c *Client) PublishEnvironmentChange(destination string, values map[string]string) error {
+	e := c.newEvent(EnvironmentChangeEventType, destination)
+	e.Values = values
+	return c.Publish(e)
+}
+
+func (c *Client) Ack(e Event) error {
+	ack := c.newEvent(AckEventType, "")
+	ack.AckID = e.ID
+	ack.AckDestinationService = e.DestinationService
+	ack.Event = eventClassPrefix + e.Type
+	return c.Publish(ack)
+}
+
+func (c *Client) Publish(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("Error encoding %s: %s", e.Type, err)
+	}
+
+	msg := amqp.Publishing{
+		Headers:     amqp.Table{"contentType": "application/json"},
+		ContentType: "application/json",
+		Body:        body,
+	}
+
+	if err := c.channel.Publish(c.exchange, c.exchange, false, false, msg); err != nil {
+		return fmt.Errorf("Error publishing %s: %s", e.Type, err)
+	}
+
+	return nil
+}
+
+// Close closes the channel and waits for running callbacks to return. It must
+// not be called from within a callback, that would wait for itself.
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.channel.Close()
+		c.listeners.Wait()
+	})
+	return err
+}
+
+func (c *Client) register(eventType string, cb Callback) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.callbacks[eventType] = append(c.callbacks[eventType], cb)
+}
+
+func (c *Client) dispatch(deliveries <-chan amqp.Delivery) {
+	defer c.listeners.Done()
+
+	for d := range deliveries {
+		var e Event
+		if err := json.Unmarshal(d.Body, &e); err != nil {
+			log.Printf("Error decoding bus event: %s\n", err)
+			continue
+		}
+		c.handle(e)
+	}
+}
+
+func (c *Client) handle(e Event) {
+	if e.OriginService == c.id {
+		return
+	}
+
+	if e.Type != AckEventType && !Matches(e.DestinationService, c.id) {
+		return
+	}
+
+	c.mutex.RLock()
+	callbacks := c.callbacks[e.Type]
+	c.mutex.RUnlock()
+
+	if len(callbacks) == 0 {
+		return
+	}
+
+	for _, cb := range callbacks {
+		cb(e)
+	}
+
+	if e.Type != AckEventType {
+		if err := c.Ack(e); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+func (c *Client) newEvent(eventType, destination string) Event {
+	seq := atomic.AddUint64(&c.sequence, 1)
+	return Event{
+		Type:               eventType,
+		ID:                 fmt.Sprintf("%s-%d-%d", c.app.Instance.ID, now().UnixNano(), seq),
+		Timestamp:          now().UnixNano() / int64(time.Millisecond),
+		OriginService:      c.id,
+		DestinationService: normalizeDestination(destination),
+	}
+}
@@ -0,0 +1,243 @@
+package bus
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/st3v/cfkit/env"
+	"github.com/st3v/cfkit/service"
+	"github.com/st3v/cfkit/service/fake"
+	"github.com/streadway/amqp"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		fakeChannel         *fake.AMQPChannel
+		origChannelProvider = channelProvider
+		origNow             = now
+		client              *Client
+
+		app = env.App{
+			Name: "customers",
+			Instance: env.AppInstance{
+				ID:    "abc123",
+				Index: 2,
+			},
+		}
+	)
+
+	BeforeEach(func() {
+		fakeChannel = new(fake.AMQPChannel)
+		channelProvider = func(*service.RabbitMQ) (service.AMQPChannel, error) {
+			return fakeChannel, nil
+		}
+		now = func() time.Time {
+			return time.Unix(1446719400, 0)
+		}
+
+		var err error
+		client, err = NewClient(&service.RabbitMQ{}, app)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		channelProvider = origChannelProvider
+		now = origNow
+	})
+
+	Describe(".NewClient", func() {
+		It("declares the springCloudBus topic exchange", func() {
+			name, kind, durable, _, _, _, _ := fakeChannel.ExchangeDeclareArgsForCall(0)
+			Expect(name).To(Equal("springCloudBus"))
+			Expect(kind).To(Equal("topic"))
+			Expect(durable).To(BeTrue())
+		})
+
+		It("derives the service id from the app", func() {
+			Expect(client.ID()).To(Equal("customers:2:abc123"))
+		})
+
+		Context("when opening the channel fails", func() {
+			It("returns an error", func() {
+				channelProvider = func(*service.RabbitMQ) (service.AMQPChannel, error) {
+					return nil, errors.New("some-error")
+				}
+				_, err := NewClient(&service.RabbitMQ{}, app)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Error opening AMQP channel"))
+			})
+		})
+	})
+
+	Describe(".Listen", func() {
+		var deliveries chan amqp.Delivery
+
+		BeforeEach(func() {
+			deliveries = make(chan amqp.Delivery, 1)
+			fakeChannel.ConsumeReturns(deliveries, nil)
+		})
+
+		AfterEach(func() {
+			close(deliveries)
+			client.Close()
+		})
+
+		It("binds a per-instance queue to all bus events", func() {
+			Expect(client.Listen()).To(Succeed())
+
+			name, durable, autoDelete, exclusive, _, _ := fakeChannel.QueueDeclareArgsForCall(0)
+			Expect(name).To(Equal("springCloudBus.customers.abc123"))
+			Expect(durable).To(BeFalse())
+			Expect(autoDelete).To(BeTrue())
+			Expect(exclusive).To(BeTrue())
+
+			queue, key, exchange, _, _ := fakeChannel.QueueBindArgsForCall(0)
+			Expect(queue).To(Equal(name))
+			Expect(key).To(Equal("#"))
+			Expect(exchange).To(Equal("springCloudBus"))
+		})
+
+		Context("when binding the queue fails", func() {
+			It("returns an error", func() {
+				fakeChannel.QueueBindReturns(errors.New("some-error"))
+				err := client.Listen()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Error binding queue"))
+			})
+		})
+
+		Context("when a refresh event is received", func() {
+			var refreshed chan Event
+
+			BeforeEach(func() {
+				refreshed = make(chan Event, 1)
+				client.OnRefresh(func(e Event) {
+					refreshed <- e
+				})
+				Expect(client.Listen()).To(Succeed())
+			})
+
+			deliver := func(body string) {
+				deliveries <- amqp.Delivery{Body: []byte(body)}
+			}
+
+			It("invokes the callback for matching destinations", func() {
+				deliver(`{"type":"RefreshRemoteApplicationEvent","id":"1","originService":"config:0:x","destinationService":"customers:**"}`)
+				var e Event
+				Eventually(refreshed).Should(Receive(&e))
+				Expect(e.ID).To(Equal("1"))
+			})
+
+			It("acks the event", func() {
+				deliver(`{"type":"RefreshRemoteApplicationEvent","id":"1","originService":"config:0:x","destinationService":"customers:**"}`)
+				Eventually(fakeChannel.PublishCallCount).Should(Equal(1))
+
+				exchange, _, _, _, msg := fakeChannel.PublishArgsForCall(0)
+				Expect(exchange).To(Equal("springCloudBus"))
+
+				var ack Event
+				Expect(json.Unmarshal(msg.Body, &ack)).To(Succeed())
+				Expect(ack.Type).To(Equal(AckEventType))
+				Expect(ack.AckID).To(Equal("1"))
+				Expect(ack.AckDestinationService).To(Equal("customers:**"))
+				Expect(ack.Event).To(Equal("org.springframework.cloud.bus.event.RefreshRemoteApplicationEvent"))
+				Expect(ack.OriginService).To(Equal(client.ID()))
+			})
+
+			It("ignores events for other destinations", func() {
+				deliver(`{"type":"RefreshRemoteApplicationEvent","id":"1","originService":"config:0:x","destinationService":"stores:**"}`)
+				Consistently(refreshed).ShouldNot(Receive())
+				Expect(fakeChannel.PublishCallCount()).To(BeZero())
+			})
+
+			It("ignores events sent by itself", func() {
+				deliver(`{"type":"RefreshRemoteApplicationEvent","id":"1","originService":"customers:2:abc123","destinationService":"**"}`)
+				Consistently(refreshed).ShouldNot(Receive())
+			})
+
+			It("ignores invalid payloads", func() {
+				deliver(`not-json`)
+				Consistently(refreshed).ShouldNot(Receive())
+			})
+		})
+
+		Context("when an environment change event is received", func() {
+			var changed chan Event
+
+			BeforeEach(func() {
+				changed = make(chan Event, 1)
+				client.OnEnvironmentChange(func(e Event) {
+					changed <- e
+				})
+				Expect(client.Listen()).To(Succeed())
+			})
+
+			It("passes the changed values to the callback", func() {
+				deliveries <- amqp.Delivery{Body: []byte(`{
+					"type": "EnvironmentChangeRemoteApplicationEvent",
+					"id": "2",
+					"originService": "config:0:x",
+					"destinationService": "**",
+					"values": {"message": "hello"}
+				}`)}
+
+				var e Event
+				Eventually(changed).Should(Receive(&e))
+				Expect(e.Values).To(Equal(map[string]string{"message": "hello"}))
+			})
+		})
+	})
+
+	Describe(".PublishRefresh", func() {
+		It("publishes a refresh event for the normalized destination", func() {
+			Expect(client.PublishRefresh("stores")).To(Succeed())
+
+			exchange, key, _, _, msg := fakeChannel.PublishArgsForCall(0)
+			Expect(exchange).To(Equal("springCloudBus"))
+			Expect(key).To(Equal("springCloudBus"))
+			Expect(msg.ContentType).To(Equal("application/json"))
+
+			var e Event
+			Expect(json.Unmarshal(msg.Body, &e)).To(Succeed())
+			Expect(e.Type).To(Equal(RefreshEventType))
+			Expect(e.OriginService).To(Equal("customers:2:abc123"))
+			Expect(e.DestinationService).To(Equal("stores:**"))
+			Expect(e.Timestamp).To(Equal(int64(1446719400000)))
+			Expect(e.ID).ToNot(BeEmpty())
+		})
+
+		Context("when publishing fails", func() {
+			It("returns an error", func() {
+				fakeChannel.PublishReturns(errors.New("some-error"))
+				err := client.PublishRefresh("")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Error publishing RefreshRemoteApplicationEvent"))
+			})
+		})
+	})
+
+	Describe(".PublishEnvironmentChange", func() {
+		It("publishes the values", func() {
+			Expect(client.PublishEnvironmentChange("", map[string]string{"foo": "bar"})).To(Succeed())
+
+			_, _, _, _, msg := fakeChannel.PublishArgsForCall(0)
+
+			var e Event
+			Expect(json.Unmarshal(msg.Body, &e)).To(Succeed())
+			Expect(e.Type).To(Equal(EnvironmentChangeEventType))
+			Expect(e.DestinationService).To(Equal("**"))
+			Expect(e.Values).To(Equal(map[string]string{"foo": "bar"}))
+		})
+	})
+
+	Describe(".Close", func() {
+		It("closes the channel once", func() {
+			Expect(client.Close()).To(Succeed())
+			Expect(client.Close()).To(Succeed())
+			Expect(fakeChannel.CloseCallCount()).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,76 @@
+package bus
+
+import "strings"
+
+const (
+	wildcardDestination = "**"
+	separator           = ":"
+)
+
+// normalizeDestination mirrors RemoteApplicationEvent in Spring Cloud Bus,
+// which turns an empty destination into "**" and short ones into "name:**".
+func normalizeDestination(destination string) string {
+	if destination == "" || destination == wildcardDestination {
+		return wildcardDestination
+	}
+
+	if strings.Count(destination, separator) <= 1 &&
+		!strings.HasSuffix(strings.ToLower(destination), separator+wildcardDestination) {
+		return destination + separator + wildcardDestination
+	}
+
+	return destination
+}
+
+func Matches(destination, serviceID string) bool {
+	destination = normalizeDestination(destination)
+	if destination == wildcardDestination {
+		return true
+	}
+
+	return matchSegments(
+		strings.Split(destination, separator),
+		strings.Split(serviceID, separator),
+	)
+}
+
+func matchSegments(patterns, segments []string) bool {
+	if len(patterns) == 0 {
+		return len(segments) == 0
+	}
+
+	if patterns[0] == wildcardDestination {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(patterns[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 || !matchSegment(patterns[0], segments[0]) {
+		return false
+	}
+
+	return matchSegments(patterns[1:], segments[1:])
+}
+
+func matchSegment(pattern, segment string) bool {
+	if pattern == "" {
+		return segment == ""
+	}
+
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(segment); i++ {
+			if matchSegment(pattern[1:], segment[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return segment != "" && matchSegment(pattern[1:], segment[1:])
+	default:
+		return segment != "" && pattern[0] == segment[0] && matchSegment(pattern[1:], segment[1:])
+	}
+}
@@ -0,0 +1,45 @@
+package bus
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe(".Matches", func() {
+	var id = "customers:0:abc123"
+
+	It("matches everything with an empty destination", func() {
+		Expect(Matches("", id)).To(BeTrue())
+	})
+
+	It("matches everything with **", func() {
+		Expect(Matches("**", id)).To(BeTrue())
+	})
+
+	It("matches app:** patterns", func() {
+		Expect(Matches("customers:**", id)).To(BeTrue())
+		Expect(Matches("stores:**", id)).To(BeFalse())
+	})
+
+	It("treats a bare app name like app:**", func() {
+		Expect(Matches("customers", id)).To(BeTrue())
+		Expect(Matches("customer", id)).To(BeFalse())
+	})
+
+	It("matches a specific instance", func() {
+		Expect(Matches("customers:0:abc123", id)).To(BeTrue())
+		Expect(Matches("customers:1:abc123", id)).To(BeFalse())
+	})
+
+	It("supports wildcards within segments", func() {
+		Expect(Matches("cust*:**", id)).To(BeTrue())
+		Expect(Matches("customers:?:*", id)).To(BeTrue())
+		Expect(Matches("customers:??:*", id)).To(BeFalse())
+	})
+
+	It("supports ** in the middle of a pattern", func() {
+		Expect(Matches("customers:**:abc123", id)).To(BeTrue())
+		Expect(Matches("**:abc123", id)).To(BeTrue())
+		Expect(Matches("**:xyz", id)).To(BeFalse())
+	})
+})
@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// Default policy for retrying failed registrations and heartbeats.
+var (
+	DefaultRetryInitialInterval = 1 * time.Second
+	DefaultRetryMaxInterval     = 2 * time.Minute
+	DefaultRetryMultiplier      = 2.0
+	DefaultRetryRandomization   = 0.5
+)
+
+// Clock tells the time and waits, allowing tests to control the timing of a
+// Registrar.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+var SystemClock Clock = systemClock{}
+
+// NewBackOff returns the default retry policy: exponential backoff with
+// jitter, capped at DefaultRetryMaxInterval and retrying forever.
+func NewBackOff(clock Clock) backoff.BackOff {
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     DefaultRetryInitialInterval,
+		RandomizationFactor: DefaultRetryRandomization,
+		Multiplier:          DefaultRetryMultiplier,
+		MaxInterval:         DefaultRetryMaxInterval,
+		Clock:               clock,
+	}
+	b.Reset()
+	return b
+}
+
+// WithBackOff sets the policy for retrying failed registrations and
+// heartbeats. It is reset whenever a registration succeeds. The Registrar
+// gives up once the policy returns backoff.Stop. Policies are stateful and
+// cannot be shared between registrars.
+func WithBackOff(b backoff.BackOff) Option {
+	return func(s *settings) {
+		s.backOff = b
+	}
+}
+
+// WithClock replaces the system clock used to time heartbeats and retries.
+func WithClock(clock Clock) Option {
+	return func(s *settings) {
+		s.clock = clock
+	}
+}
@@ -0,0 +1,178 @@
+package discovery_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	. "github.com/st3v/cfkit/discovery"
+	"github.com/st3v/cfkit/discovery/fake"
+	"github.com/st3v/cfkit/env"
+)
+
+// fakeClock records every wait and lets the spec decide when it is over.
+type fakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waits   []time.Duration
+	pending []chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waits = append(c.waits, d)
+	c.pending = append(c.pending, ch)
+	return ch
+}
+
+// Tick ends all pending waits.
+func (c *fakeClock) Tick() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, ch := range c.pending {
+		ch <- c.now
+	}
+	c.pending = nil
+}
+
+func (c *fakeClock) Pending() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.pending)
+}
+
+func (c *fakeClock) Waits() []time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]time.Duration{}, c.waits...)
+}
+
+var _ = Describe("retry backoff", func() {
+	var (
+		fakeClient *fake.Client
+		clock      *fakeClock
+		registrar  *Registrar
+		policy     *backoff.ExponentialBackOff
+	)
+
+	// step lets the registrar finish its current wait
+	step := func() {
+		Eventually(clock.Pending).Should(Equal(1))
+		clock.Tick()
+	}
+
+	BeforeEach(func() {
+		clock = &fakeClock{now: time.Unix(1000, 0)}
+
+		fakeClient = new(fake.Client)
+		fakeClient.HeartbeatIntervalReturns(10 * time.Nanosecond)
+
+		policy = &backoff.ExponentialBackOff{
+			InitialInterval: time.Second,
+			Multiplier:      2,
+			MaxInterval:     4 * time.Second,
+			Clock:           clock,
+		}
+
+		registrar = NewRegistrar(fakeClient, env.App{Name: "app-name"}, WithClock(clock), WithBackOff(policy))
+	})
+
+	AfterEach(func() {
+		registrar.Stop(context.Background())
+	})
+
+	Context("when registering the app keeps failing", func() {
+		BeforeEach(func() {
+			fakeClient.RegisterContextReturns(errors.New("some-error"))
+		})
+
+		It("backs off exponentially up to the maximum interval", func() {
+			Expect(registrar.Start(context.Background())).To(Succeed())
+			for i := 0; i < 5; i++ {
+				step()
+			}
+
+			Eventually(clock.Waits).Should(Equal([]time.Duration{
+				0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second,
+			}))
+			Expect(fakeClient.RegisterContextCallCount()).To(Equal(5))
+		})
+	})
+
+	Context("when registering the app succeeds after a while", func() {
+		BeforeEach(func() {
+			fakeClient.RegisterContextStub = func(context.Context, env.App) error {
+				if fakeClient.RegisterContextCallCount() <= 3 {
+					return errors.New("some-error")
+				}
+				return nil
+			}
+			fakeClient.HeartbeatContextReturns(errors.New("some-error"))
+		})
+
+		It("starts over once it succeeded", func() {
+			Expect(registrar.Start(context.Background())).To(Succeed())
+			for i := 0; i < 5; i++ {
+				step()
+			}
+
+			Eventually(clock.Waits).Should(Equal([]time.Duration{
+				// failed registrations
+				0, time.Second, 2 * time.Second,
+				// successful registration, then a failed heartbeat
+				4 * time.Second, 10 * time.Nanosecond,
+				// backing off from the start
+				time.Second,
+			}))
+		})
+	})
+
+	Describe(".NewBackOff", func() {
+		It("randomizes the intervals around an exponential growth", func() {
+			b := NewBackOff(clock)
+
+			expected := DefaultRetryInitialInterval
+			for i := 0; i < 5; i++ {
+				d := b.NextBackOff()
+				Expect(d).To(BeNumerically(">=", time.Duration(float64(expected)*(1-DefaultRetryRandomization))))
+				Expect(d).To(BeNumerically("<=", time.Duration(float64(expected)*(1+DefaultRetryRandomization))+1))
+				expected = time.Duration(float64(expected) * DefaultRetryMultiplier)
+			}
+		})
+
+		It("caps the interval and never stops", func() {
+			b := NewBackOff(clock)
+			for i := 0; i < 50; i++ {
+				b.NextBackOff()
+			}
+
+			clock.now = clock.now.Add(24 * time.Hour)
+			d := b.NextBackOff()
+			Expect(d).ToNot(Equal(backoff.Stop))
+			Expect(d).To(BeNumerically("<=", time.Duration(float64(DefaultRetryMaxInterval)*(1+DefaultRetryRandomization))+1))
+		})
+
+		It("spreads the retries", func() {
+			seen := map[time.Duration]bool{}
+			for i := 0; i < 20; i++ {
+				seen[NewBackOff(clock).NextBackOff()] = true
+			}
+			Expect(len(seen)).To(BeNumerically(">", 1))
+		})
+	})
+})
@@ -0,0 +1,170 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultLookupTTL is how long a Balancer reuses the instances it looked up.
+var DefaultLookupTTL = 5 * time.Second
+
+type BalancerOption func(*Balancer)
+
+// WithStrategy replaces the default round-robin strategy.
+func WithStrategy(strategy Strategy) BalancerOption {
+	return func(b *Balancer) {
+		b.strategy = strategy
+	}
+}
+
+// WithLookupTTL sets how long looked up instances are reused. Zero looks up
+// the app on every pick.
+func WithLookupTTL(ttl time.Duration) BalancerOption {
+	return func(b *Balancer) {
+		b.ttl = ttl
+	}
+}
+
+// Balancer picks instances of apps registered with a discovery service and
+// keeps track of the requests in flight to each of them.
+type Balancer struct {
+	client   Client
+	strategy Strategy
+	ttl      time.Duration
+
+	mutex    sync.Mutex
+	lookups  map[string]lookup
+	inFlight map[string]int
+}
+
+type lookup struct {
+	app     Application
+	err     error
+	expires time.Time
+}
+
+func NewBalancer(client Client, opts ...BalancerOption) *Balancer {
+	b := &Balancer{
+		client:   client,
+		strategy: RoundRobin(),
+		ttl:      DefaultLookupTTL,
+		lookups:  map[string]lookup{},
+		inFlight: map[string]int{},
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Pick chooses an UP instance of the named app, leaving out the instances to
+// skip, e.g. those that failed already. The returned function must be called
+// once the request to the instance has finished.
+func (b *Balancer) Pick(ctx context.Context, name string, skip ...Instance) (Instance, func(), error) {
+	app, err := b.lookup(ctx, name)
+	if err != nil {
+		return Instance{}, nil, err
+	}
+
+	candidates := app.Filter(isUp, except(skip)).Instances
+	if len(candidates) == 0 {
+		return Instance{}, nil, fmt.Errorf("No instance of app '%s' available", name)
+	}
+	sort.Sort(byKey(candidates))
+
+	b.mutex.Lock()
+	inst := b.strategy(candidates, func(inst Instance) int {
+		return b.inFlight[instanceKey(inst)]
+	})
+	key := instanceKey(inst)
+	b.inFlight[key]++
+	b.mutex.Unlock()
+
+	var once sync.Once
+	return inst, func() {
+		once.Do(func() {
+			b.release(key)
+		})
+	}, nil
+}
+
+// InFlight returns the number of picks of the instance that have not been
+// released yet.
+func (b *Balancer) InFlight(inst Instance) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.inFlight[instanceKey(inst)]
+}
+
+func (b *Balancer) release(key string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.inFlight[key] <= 1 {
+		delete(b.inFlight, key)
+		return
+	}
+	b.inFlight[key]--
+}
+
+func (b *Balancer) lookup(ctx context.Context, name string) (Application, error) {
+	now := time.Now()
+
+	b.mutex.Lock()
+	l, ok := b.lookups[name]
+	b.mutex.Unlock()
+
+	if ok && now.Before(l.expires) {
+		return l.app, l.err
+	}
+
+	app, err := b.client.AppContext(ctx, name)
+	if err != nil && !IsUnknownApp(err) {
+		return Application{}, err
+	}
+
+	// unknown apps are remembered as well, sparing the registry repeated
+	// lookups of host names it cannot resolve
+	if b.ttl > 0 {
+		b.mutex.Lock()
+		b.lookups[name] = lookup{app: app, err: err, expires: now.Add(b.ttl)}
+		b.mutex.Unlock()
+	}
+
+	return app, err
+}
+
+func isUp(inst Instance) bool {
+	return inst.Status == StatusUp
+}
+
+func except(skip []Instance) InstanceFilter {
+	return func(inst Instance) bool {
+		for _, s := range skip {
+			if instanceKey(s) == instanceKey(inst) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// instanceKey identifies an instance across lookups.
+func instanceKey(inst Instance) string {
+	if inst.ID != "" {
+		return inst.App + "/" + inst.ID
+	}
+	return inst.App + "/" + inst.BaseURL()
+}
+
+type byKey []Instance
+
+func (s byKey) Len() int           { return len(s) }
+func (s byKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byKey) Less(i, j int) bool { return instanceKey(s[i]) < instanceKey(s[j]) }
@@ -0,0 +1,193 @@
+package discovery_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	. "github.com/st3v/cfkit/discovery"
+	"github.com/st3v/cfkit/discovery/fake"
+)
+
+var _ = Describe("Balancer", func() {
+	var (
+		fakeClient *fake.Client
+		balancer   *Balancer
+		app        Application
+	)
+
+	pick := func(skip ...Instance) string {
+		inst, done, err := balancer.Pick(context.Background(), "app-name", skip...)
+		Expect(err).ToNot(HaveOccurred())
+		done()
+		return inst.ID
+	}
+
+	BeforeEach(func() {
+		app = Application{
+			Name: "app-name",
+			Instances: []Instance{
+				{ID: "c", App: "app-name", Status: StatusUp},
+				{ID: "a", App: "app-name", Status: StatusUp},
+				{ID: "down", App: "app-name", Status: StatusDown},
+				{ID: "b", App: "app-name", Status: StatusUp},
+				{ID: "starting", App: "app-name", Status: StatusStarting},
+			},
+		}
+
+		fakeClient = new(fake.Client)
+		fakeClient.AppContextReturns(app, nil)
+
+		balancer = NewBalancer(fakeClient)
+	})
+
+	Describe(".Pick", func() {
+		It("looks up the app", func() {
+			pick()
+			Expect(fakeClient.AppContextCallCount()).To(Equal(1))
+			_, name := fakeClient.AppContextArgsForCall(0)
+			Expect(name).To(Equal("app-name"))
+		})
+
+		It("cycles through the UP instances", func() {
+			Expect([]string{pick(), pick(), pick(), pick()}).To(Equal([]string{"a", "b", "c", "a"}))
+		})
+
+		It("leaves out the instances to skip", func() {
+			skip := []Instance{app.Instances[1], app.Instances[3]}
+			Expect([]string{pick(skip...), pick(skip...)}).To(Equal([]string{"c", "c"}))
+		})
+
+		It("reuses the instances it looked up", func() {
+			pick()
+			pick()
+			Expect(fakeClient.AppContextCallCount()).To(Equal(1))
+		})
+
+		Context("when the lookup expired", func() {
+			BeforeEach(func() {
+				balancer = NewBalancer(fakeClient, WithLookupTTL(10*time.Millisecond))
+			})
+
+			It("looks up the app again", func() {
+				pick()
+				time.Sleep(20 * time.Millisecond)
+				pick()
+				Expect(fakeClient.AppContextCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when lookups are not cached", func() {
+			BeforeEach(func() {
+				balancer = NewBalancer(fakeClient, WithLookupTTL(0))
+			})
+
+			It("looks up the app on every pick", func() {
+				pick()
+				pick()
+				Expect(fakeClient.AppContextCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when no instance is UP", func() {
+			BeforeEach(func() {
+				fakeClient.AppContextReturns(app.Filter(func(inst Instance) bool {
+					return inst.Status != StatusUp
+				}), nil)
+			})
+
+			It("returns an error", func() {
+				_, _, err := balancer.Pick(context.Background(), "app-name")
+				Expect(err).To(MatchError("No instance of app 'app-name' available"))
+			})
+		})
+
+		Context("when the lookup fails", func() {
+			BeforeEach(func() {
+				fakeClient.AppContextReturns(Application{}, errors.New("some-error"))
+			})
+
+			It("returns the error", func() {
+				_, _, err := balancer.Pick(context.Background(), "app-name")
+				Expect(err).To(MatchError("some-error"))
+			})
+
+			It("does not cache the failure", func() {
+				balancer.Pick(context.Background(), "app-name")
+				fakeClient.AppContextReturns(app, nil)
+				Expect(pick()).To(Equal("a"))
+			})
+		})
+
+		Context("when the app is unknown", func() {
+			BeforeEach(func() {
+				fakeClient.AppContextReturns(Application{}, notFoundError(true))
+			})
+
+			It("returns the error", func() {
+				_, _, err := balancer.Pick(context.Background(), "app-name")
+				Expect(IsUnknownApp(err)).To(BeTrue())
+			})
+
+			It("remembers it", func() {
+				balancer.Pick(context.Background(), "app-name")
+				_, _, err := balancer.Pick(context.Background(), "app-name")
+				Expect(IsUnknownApp(err)).To(BeTrue())
+				Expect(fakeClient.AppContextCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("with a custom strategy", func() {
+			BeforeEach(func() {
+				balancer = NewBalancer(fakeClient, WithStrategy(func(candidates []Instance, _ func(Instance) int) Instance {
+					return candidates[len(candidates)-1]
+				}))
+			})
+
+			It("uses the strategy", func() {
+				Expect(pick()).To(Equal("c"))
+			})
+		})
+	})
+
+	Describe("requests in flight", func() {
+		It("counts the picks until they are released", func() {
+			inst, done, err := balancer.Pick(context.Background(), "app-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(balancer.InFlight(inst)).To(Equal(1))
+
+			done()
+			Expect(balancer.InFlight(inst)).To(BeZero())
+		})
+
+		It("ignores repeated releases", func() {
+			onlyA := []Instance{app.Instances[0], app.Instances[3]}
+			inst, done, _ := balancer.Pick(context.Background(), "app-name", onlyA...)
+			_, other, _ := balancer.Pick(context.Background(), "app-name", onlyA...)
+			defer other()
+
+			done()
+			done()
+			Expect(balancer.InFlight(inst)).To(Equal(1))
+		})
+
+		Context("when choosing the least outstanding instance", func() {
+			BeforeEach(func() {
+				balancer = NewBalancer(fakeClient, WithStrategy(LeastOutstanding()))
+			})
+
+			It("avoids busy instances", func() {
+				picked := map[string]bool{}
+				for i := 0; i < 3; i++ {
+					inst, _, err := balancer.Pick(context.Background(), "app-name")
+					Expect(err).ToNot(HaveOccurred())
+					picked[inst.ID] = true
+				}
+				Expect(picked).To(HaveLen(3))
+			})
+		})
+	})
+})
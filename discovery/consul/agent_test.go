@@ -0,0 +1,272 @@
+package consul_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type agentRequest struct {
+	Method string
+	Path   string
+	Query  map[string][]string
+	Token  string
+}
+
+type registration struct {
+	ID      string
+	Name    string
+	Tags    []string
+	Address string
+	Port    int
+	Meta    map[string]string
+	Check   struct {
+		CheckID                        string
+		TTL                            string
+		Status                         string
+		DeregisterCriticalServiceAfter string
+	}
+}
+
+// fakeAgent stands in for the HTTP API of a Consul agent, including blocking
+// queries.
+type fakeAgent struct {
+	mutex       sync.Mutex
+	requests    []agentRequest
+	services    map[string]registration
+	checks      map[string]string
+	outputs     map[string]string
+	maintenance map[string]bool
+	index       uint64
+	changed     chan struct{}
+}
+
+func newFakeAgent() *fakeAgent {
+	return &fakeAgent{
+		services:    map[string]registration{},
+		checks:      map[string]string{},
+		outputs:     map[string]string{},
+		maintenance: map[string]bool{},
+		index:       1,
+		changed:     make(chan struct{}),
+	}
+}
+
+func (a *fakeAgent) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	a.mutex.Lock()
+	a.requests = append(a.requests, agentRequest{req.Method, req.URL.Path, req.URL.Query(), req.Header.Get("X-Consul-Token")})
+	a.mutex.Unlock()
+
+	path := req.URL.Path
+	switch {
+	case req.Method == "PUT" && path == "/v1/agent/service/register":
+		var r registration
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.Register(r)
+
+	case req.Method == "PUT" && strings.HasPrefix(path, "/v1/agent/service/deregister/"):
+		if !a.deregister(strings.TrimPrefix(path, "/v1/agent/service/deregister/")) {
+			http.Error(w, "Unknown service ID", http.StatusNotFound)
+		}
+
+	case req.Method == "PUT" && strings.HasPrefix(path, "/v1/agent/check/update/"):
+		var update struct{ Status, Output string }
+		json.NewDecoder(req.Body).Decode(&update)
+		if !a.updateCheck(strings.TrimPrefix(path, "/v1/agent/check/update/"), update.Status, update.Output) {
+			http.Error(w, "Unknown check ID", http.StatusNotFound)
+		}
+
+	case req.Method == "PUT" && strings.HasPrefix(path, "/v1/agent/service/maintenance/"):
+		a.setMaintenance(strings.TrimPrefix(path, "/v1/agent/service/maintenance/"), req.URL.Query().Get("enable") == "true")
+
+	case req.Method == "GET" && strings.HasPrefix(path, "/v1/health/service/"):
+		a.health(w, req, strings.TrimPrefix(path, "/v1/health/service/"))
+
+	case req.Method == "GET" && path == "/v1/catalog/services":
+		a.catalog(w)
+
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (a *fakeAgent) Register(r registration) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.services[r.ID] = r
+	a.checks[r.Check.CheckID] = r.Check.Status
+	a.bump()
+}
+
+func (a *fakeAgent) deregister(id string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	r, ok := a.services[id]
+	if !ok {
+		return false
+	}
+
+	delete(a.services, id)
+	delete(a.checks, r.Check.CheckID)
+	a.bump()
+	return true
+}
+
+func (a *fakeAgent) updateCheck(id, status, output string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, ok := a.checks[id]; !ok {
+		return false
+	}
+
+	a.checks[id] = status
+	a.outputs[id] = output
+	a.bump()
+	return true
+}
+
+func (a *fakeAgent) setMaintenance(id string, enable bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.maintenance[id] = enable
+	a.bump()
+}
+
+// bump moves the index on and releases blocked queries, mutex must be held.
+func (a *fakeAgent) bump() {
+	a.index++
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+func (a *fakeAgent) health(w http.ResponseWriter, req *http.Request, name string) {
+	if index, err := strconv.ParseUint(req.URL.Query().Get("index"), 10, 64); err == nil {
+		wait, _ := time.ParseDuration(req.URL.Query().Get("wait"))
+
+		a.mutex.Lock()
+		current, changed := a.index, a.changed
+		a.mutex.Unlock()
+
+		if index >= current {
+			select {
+			case <-changed:
+			case <-time.After(wait):
+			}
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	entries := []map[string]interface{}{}
+	for _, id := range a.ids() {
+		r := a.services[id]
+		if r.Name != name {
+			continue
+		}
+
+		checks := []map[string]string{
+			{"CheckID": "serfHealth", "Status": "passing"},
+			{"CheckID": r.Check.CheckID, "Status": a.checks[r.Check.CheckID]},
+		}
+		if a.maintenance[id] {
+			checks = append(checks, map[string]string{"CheckID": "_service_maintenance:" + id, "Status": "critical"})
+		}
+
+		entries = append(entries, map[string]interface{}{
+			"Node": map[string]string{"Node": "node-1", "Address": "10.0.0.1"},
+			"Service": map[string]interface{}{
+				"ID":      r.ID,
+				"Service": r.Name,
+				"Tags":    r.Tags,
+				"Address": r.Address,
+				"Port":    r.Port,
+				"Meta":    r.Meta,
+			},
+			"Checks": checks,
+		})
+	}
+
+	w.Header().Set("X-Consul-Index", fmt.Sprint(a.index))
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (a *fakeAgent) catalog(w http.ResponseWriter) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	services := map[string][]string{"consul": {}}
+	for _, r := range a.services {
+		services[r.Name] = r.Tags
+	}
+
+	w.Header().Set("X-Consul-Index", fmt.Sprint(a.index))
+	json.NewEncoder(w).Encode(services)
+}
+
+func (a *fakeAgent) ids() []string {
+	ids := []string{}
+	for id := range a.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (a *fakeAgent) Requests() []agentRequest {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return append([]agentRequest{}, a.requests...)
+}
+
+// HealthQueries counts the blocking and non-blocking health queries.
+func (a *fakeAgent) HealthQueries() (blocking, nonBlocking int) {
+	for _, r := range a.Requests() {
+		if !strings.HasPrefix(r.Path, "/v1/health/service/") {
+			continue
+		}
+		if _, ok := r.Query["index"]; ok {
+			blocking++
+		} else {
+			nonBlocking++
+		}
+	}
+	return blocking, nonBlocking
+}
+
+func (a *fakeAgent) Service(id string) (registration, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	r, ok := a.services[id]
+	return r, ok
+}
+
+func (a *fakeAgent) Check(id string) string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.checks[id]
+}
+
+func (a *fakeAgent) Output(id string) string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.outputs[id]
+}
+
+func (a *fakeAgent) Maintenance(id string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.maintenance[id]
+}
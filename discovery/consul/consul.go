@@ -0,0 +1,503 @@
+package consul
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/st3v/cfkit/discovery"
+	"github.com/st3v/cfkit/env"
+)
+
+const (
+	appStdSecurePort = 443
+
+	// blockingQueryMargin allows for the response of a blocking query to
+	// arrive after the wait time is up.
+	blockingQueryMargin = time.Second
+
+	DefaultCheckTTL = 30 * time.Second
+
+	// DefaultWaitTime is how long a blocking query waits for changes.
+	DefaultWaitTime = 5 * time.Minute
+)
+
+type Option func(*Client)
+
+// WithToken sets the ACL token sent with every request.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+func WithDatacenter(datacenter string) Option {
+	return func(c *Client) {
+		c.datacenter = datacenter
+	}
+}
+
+// WithCheckTTL sets the TTL of the check registered along with the service.
+// Heartbeats pass the check three times per TTL.
+func WithCheckTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.checkTTL = ttl
+	}
+}
+
+// WithDeregisterCriticalAfter lets Consul remove instances whose check has
+// been failing for the given time, e.g. after the app crashed.
+func WithDeregisterCriticalAfter(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.deregisterCriticalAfter = timeout
+	}
+}
+
+// WithWaitTime sets how long blocking queries wait for changes.
+func WithWaitTime(wait time.Duration) Option {
+	return func(c *Client) {
+		c.waitTime = wait
+	}
+}
+
+func WithTags(tags ...string) Option {
+	return func(c *Client) {
+		c.tags = append(c.tags, tags...)
+	}
+}
+
+func WithMeta(meta map[string]string) Option {
+	return func(c *Client) {
+		for k, v := range meta {
+			c.meta[k] = v
+		}
+	}
+}
+
+// RegistrationMode controls which address is registered for an app instance.
+type RegistrationMode string
+
+const (
+	// RouteRegistration registers the first route of the app on port 443.
+	RouteRegistration RegistrationMode = "route"
+
+	// DirectRegistration registers the container address, for apps reached
+	// over the container network.
+	DirectRegistration RegistrationMode = "direct"
+)
+
+func WithRegistrationMode(mode RegistrationMode) Option {
+	return func(c *Client) {
+		c.registrationMode = mode
+	}
+}
+
+// WithInternalHostName registers the given host name instead of the container
+// IP in direct registration mode, e.g. app-name.apps.internal.
+func WithInternalHostName(host string) Option {
+	return func(c *Client) {
+		c.internalHostName = host
+	}
+}
+
+// Client registers apps with the local Consul agent and looks up healthy
+// instances of other services.
+type Client struct {
+	uri                     string
+	timeout                 time.Duration
+	token                   string
+	datacenter              string
+	checkTTL                time.Duration
+	deregisterCriticalAfter time.Duration
+	waitTime                time.Duration
+	tags                    []string
+	meta                    map[string]string
+	registrationMode        RegistrationMode
+	internalHostName        string
+	client                  *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex    sync.Mutex
+	statuses map[string]discovery.Status
+	watches  map[string]*watch
+}
+
+func NewClient(uri string, timeout time.Duration, opts ...Option) *Client {
+	c := &Client{
+		uri:              strings.TrimSuffix(uri, "/"),
+		timeout:          timeout,
+		checkTTL:         DefaultCheckTTL,
+		waitTime:         DefaultWaitTime,
+		meta:             map[string]string{},
+		registrationMode: RouteRegistration,
+		client:           &http.Client{},
+		statuses:         map[string]discovery.Status{},
+		watches:          map[string]*watch{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	return c
+}
+
+// Error describes a failed request to Consul. Code holds the HTTP status code
+// if Consul answered the request.
+type Error struct {
+	Code    int
+	Err     error
+	message string
+}
+
+func newError(message string, err error) *Error {
+	e := &Error{Err: err, message: message}
+	if statusErr, ok := err.(*StatusError); ok {
+		e.Code = statusErr.Code
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.message, e.Err)
+}
+
+// NotFound reports whether Consul does not know the requested service or
+// check. Agents before Consul 1.0 answer unknown checks with a server error.
+func (e *Error) NotFound() bool {
+	if e.Code == http.StatusNotFound {
+		return true
+	}
+
+	statusErr, ok := e.Err.(*StatusError)
+	return ok && (strings.Contains(statusErr.Body, "Unknown check") ||
+		strings.Contains(statusErr.Body, "does not have associated TTL"))
+}
+
+// StatusError is returned for requests Consul answered with an unexpected
+// HTTP status code.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Consul returned status %d: %s", e.Code, strings.TrimSpace(e.Body))
+}
+
+type agentService struct {
+	ID      string
+	Name    string
+	Tags    []string          `json:",omitempty"`
+	Address string            `json:",omitempty"`
+	Port    int               `json:",omitempty"`
+	Meta    map[string]string `json:",omitempty"`
+	Check   agentCheck
+}
+
+type agentCheck struct {
+	CheckID                        string
+	Name                           string
+	TTL                            string
+	Status                         string
+	DeregisterCriticalServiceAfter string `json:",omitempty"`
+}
+
+type checkUpdate struct {
+	Status string
+	Output string
+}
+
+func (c *Client) Register(app env.App) error {
+	return c.RegisterContext(context.Background(), app)
+}
+
+// RegisterContext registers the app along with a TTL check reflecting the
+// status of the instance.
+func (c *Client) RegisterContext(ctx context.Context, app env.App) error {
+	if c.registrationMode == DirectRegistration && app.Instance.InternalIP == "" {
+		return errors.New("Error registering app with Consul: CF_INSTANCE_INTERNAL_IP not set")
+	}
+	if c.registrationMode == DirectRegistration && app.Port == 0 {
+		return errors.New("Error registering app with Consul: port of the app unknown")
+	}
+
+	svc := c.agentService(app)
+	svc.Check.Status = checkStatus(c.status(app))
+
+	resp, err := c.do(ctx, "PUT", "/v1/agent/service/register", nil, svc)
+	if err != nil {
+		return newError("Error registering app with Consul", err)
+	}
+	discard(resp)
+
+	return nil
+}
+
+func (c *Client) agentService(app env.App) agentService {
+	id := serviceID(app)
+
+	svc := agentService{
+		ID:   id,
+		Name: app.Name,
+		Tags: c.tags,
+		Meta: map[string]string{},
+		Check: agentCheck{
+			CheckID: checkID(app),
+			Name:    fmt.Sprintf("Service '%s' check", app.Name),
+			TTL:     c.checkTTL.String(),
+		},
+	}
+
+	for k, v := range c.meta {
+		svc.Meta[k] = v
+	}
+	svc.Meta["instanceId"] = app.Instance.ID
+
+	if c.deregisterCriticalAfter > 0 {
+		svc.Check.DeregisterCriticalServiceAfter = c.deregisterCriticalAfter.String()
+	}
+
+	if c.registrationMode == DirectRegistration {
+		svc.Address = c.internalHostName
+		if svc.Address == "" {
+			svc.Address = app.Instance.InternalIP
+		}
+		svc.Port = app.Port
+		return svc
+	}
+
+	svc.Address = app.URI()
+	svc.Port = appStdSecurePort
+	svc.Meta["secure"] = "true"
+	return svc
+}
+
+func (c *Client) Deregister(app env.App) error {
+	return c.DeregisterContext(context.Background(), app)
+}
+
+func (c *Client) DeregisterContext(ctx context.Context, app env.App) error {
+	resp, err := c.do(ctx, "PUT", "/v1/agent/service/deregister/"+pathEscape(serviceID(app)), nil, nil)
+	if err != nil {
+		return newError("Error deregistering app with Consul", err)
+	}
+	discard(resp)
+
+	c.mutex.Lock()
+	delete(c.statuses, serviceID(app))
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// Heartbeat passes the TTL check, unless the instance reports a status other
+// than UP. Failures are reported as *Error.
+func (c *Client) Heartbeat(app env.App) error {
+	return c.HeartbeatContext(context.Background(), app)
+}
+
+func (c *Client) HeartbeatContext(ctx context.Context, app env.App) error {
+	if err := c.updateCheck(ctx, app, c.status(app)); err != nil {
+		return newError("Error sending heartbeat for app to Consul", err)
+	}
+	return nil
+}
+
+func (c *Client) SetStatus(app env.App, status discovery.Status) error {
+	return c.SetStatusContext(context.Background(), app, status)
+}
+
+// SetStatusContext updates the TTL check. OUT_OF_SERVICE puts the instance
+// into maintenance mode.
+func (c *Client) SetStatusContext(ctx context.Context, app env.App, status discovery.Status) error {
+	previous := c.status(app)
+
+	if status == discovery.StatusOutOfService || previous == discovery.StatusOutOfService {
+		if err := c.maintenance(ctx, app, status == discovery.StatusOutOfService); err != nil {
+			return fmt.Errorf("Error setting status %s with Consul: %s", status, err)
+		}
+	}
+
+	if err := c.updateCheck(ctx, app, status); err != nil {
+		return fmt.Errorf("Error setting status %s with Consul: %s", status, err)
+	}
+
+	c.mutex.Lock()
+	c.statuses[serviceID(app)] = status
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// status returns the last status set for the instance, STARTING if none.
+func (c *Client) status(app env.App) discovery.Status {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if status, ok := c.statuses[serviceID(app)]; ok {
+		return status
+	}
+	return discovery.StatusStarting
+}
+
+func (c *Client) updateCheck(ctx context.Context, app env.App, status discovery.Status) error {
+	update := checkUpdate{Status: checkStatus(status), Output: string(status)}
+	resp, err := c.do(ctx, "PUT", "/v1/agent/check/update/"+pathEscape(checkID(app)), nil, update)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+func (c *Client) maintenance(ctx context.Context, app env.App, enable bool) error {
+	query := url.Values{"enable": {fmt.Sprint(enable)}, "reason": {string(discovery.StatusOutOfService)}}
+	resp, err := c.do(ctx, "PUT", "/v1/agent/service/maintenance/"+pathEscape(serviceID(app)), query, nil)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+func (c *Client) HeartbeatInterval() time.Duration {
+	return c.checkTTL / 3
+}
+
+// Close stops watching the services looked up so far.
+func (c *Client) Close() {
+	c.cancel()
+}
+
+func (c *Client) URI() string {
+	return c.uri
+}
+
+func (c *Client) Timeout() time.Duration {
+	return c.timeout
+}
+
+func (c *Client) Datacenter() string {
+	return c.datacenter
+}
+
+func (c *Client) CheckTTL() time.Duration {
+	return c.checkTTL
+}
+
+func (c *Client) Tags() []string {
+	return c.tags
+}
+
+func (c *Client) RegistrationMode() RegistrationMode {
+	return c.registrationMode
+}
+
+// do sends a request to the agent and returns the response if it succeeded.
+// Requests with a blocking query get the wait time on top of the timeout,
+// plus the up to a sixteenth of it that Consul adds as jitter and a margin.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	timeout := c.timeout
+	if query.Get("index") != "" {
+		timeout += c.waitTime + c.waitTime/16 + blockingQueryMargin
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	uri := c.uri + path
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, uri, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := ctxhttp.Do(ctx, c.client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &StatusError{Code: resp.StatusCode, Body: string(data)}
+	}
+
+	// the response is read in full, no need to keep the timeout running
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	return resp, nil
+}
+
+func discard(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func serviceID(app env.App) string {
+	return fmt.Sprintf("%s-%s", app.Name, app.Instance.ID)
+}
+
+// pathEscape escapes s for use as a path segment, url.QueryEscape would turn
+// spaces into plus signs.
+func pathEscape(s string) string {
+	return (&url.URL{Path: s}).EscapedPath()
+}
+
+func checkID(app env.App) string {
+	return "service:" + serviceID(app)
+}
+
+func checkStatus(status discovery.Status) string {
+	if status == discovery.StatusUp {
+		return "passing"
+	}
+	return "critical"
+}
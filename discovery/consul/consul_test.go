@@ -0,0 +1,278 @@
+package consul_test
+
+import (
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	"github.com/st3v/cfkit/discovery"
+	. "github.com/st3v/cfkit/discovery/consul"
+	"github.com/st3v/cfkit/env"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		agent  *fakeAgent
+		server *httptest.Server
+		client *Client
+		app    env.App
+	)
+
+	const (
+		serviceID = "app-name-instance-id"
+		checkID   = "service:app-name-instance-id"
+	)
+
+	BeforeEach(func() {
+		agent = newFakeAgent()
+		server = httptest.NewServer(agent)
+
+		app = env.App{
+			Name:     "app-name",
+			URIs:     []string{"app-name.example.com"},
+			Port:     8080,
+			Instance: env.AppInstance{ID: "instance-id", InternalIP: "10.255.0.4"},
+		}
+
+		client = NewClient(server.URL, time.Second, WithToken("some-token"))
+	})
+
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+
+	Describe(".NewClient", func() {
+		It("applies the defaults", func() {
+			Expect(client.URI()).To(Equal(server.URL))
+			Expect(client.Timeout()).To(Equal(time.Second))
+			Expect(client.CheckTTL()).To(Equal(DefaultCheckTTL))
+			Expect(client.RegistrationMode()).To(Equal(RouteRegistration))
+			Expect(client.HeartbeatInterval()).To(Equal(DefaultCheckTTL / 3))
+		})
+	})
+
+	Describe(".Register", func() {
+		It("registers the route of the app with a TTL check", func() {
+			Expect(client.Register(app)).To(Succeed())
+
+			r, ok := agent.Service(serviceID)
+			Expect(ok).To(BeTrue())
+			Expect(r.Name).To(Equal("app-name"))
+			Expect(r.Address).To(Equal("app-name.example.com"))
+			Expect(r.Port).To(Equal(443))
+			Expect(r.Meta).To(Equal(map[string]string{"instanceId": "instance-id", "secure": "true"}))
+			Expect(r.Check.CheckID).To(Equal(checkID))
+			Expect(r.Check.TTL).To(Equal("30s"))
+			Expect(r.Check.DeregisterCriticalServiceAfter).To(BeEmpty())
+		})
+
+		It("keeps the instance out of rotation until its status is set", func() {
+			Expect(client.Register(app)).To(Succeed())
+			Expect(agent.Check(checkID)).To(Equal("critical"))
+		})
+
+		It("sends the token", func() {
+			Expect(client.Register(app)).To(Succeed())
+			Expect(agent.Requests()[0].Token).To(Equal("some-token"))
+		})
+
+		Context("when options are given", func() {
+			BeforeEach(func() {
+				client = NewClient(server.URL, time.Second,
+					WithTags("v1", "canary"),
+					WithMeta(map[string]string{"version": "1.2.3"}),
+					WithCheckTTL(9*time.Second),
+					WithDeregisterCriticalAfter(time.Minute),
+				)
+			})
+
+			It("registers them", func() {
+				Expect(client.Register(app)).To(Succeed())
+
+				r, _ := agent.Service(serviceID)
+				Expect(r.Tags).To(Equal([]string{"v1", "canary"}))
+				Expect(r.Meta).To(HaveKeyWithValue("version", "1.2.3"))
+				Expect(r.Check.TTL).To(Equal("9s"))
+				Expect(r.Check.DeregisterCriticalServiceAfter).To(Equal("1m0s"))
+				Expect(client.HeartbeatInterval()).To(Equal(3 * time.Second))
+			})
+		})
+
+		Context("in direct registration mode", func() {
+			BeforeEach(func() {
+				client = NewClient(server.URL, time.Second, WithRegistrationMode(DirectRegistration))
+			})
+
+			It("registers the container address", func() {
+				Expect(client.Register(app)).To(Succeed())
+
+				r, _ := agent.Service(serviceID)
+				Expect(r.Address).To(Equal("10.255.0.4"))
+				Expect(r.Port).To(Equal(8080))
+				Expect(r.Meta).ToNot(HaveKey("secure"))
+			})
+
+			Context("with an internal host name", func() {
+				BeforeEach(func() {
+					client = NewClient(server.URL, time.Second,
+						WithRegistrationMode(DirectRegistration),
+						WithInternalHostName("app-name.apps.internal"),
+					)
+				})
+
+				It("registers the host name", func() {
+					Expect(client.Register(app)).To(Succeed())
+
+					r, _ := agent.Service(serviceID)
+					Expect(r.Address).To(Equal("app-name.apps.internal"))
+				})
+			})
+
+			Context("when the internal IP is unknown", func() {
+				It("returns an error", func() {
+					app.Instance.InternalIP = ""
+					Expect(client.Register(app)).To(MatchError("Error registering app with Consul: CF_INSTANCE_INTERNAL_IP not set"))
+				})
+			})
+		})
+
+		Context("when the agent fails", func() {
+			BeforeEach(func() {
+				server.Close()
+			})
+
+			It("returns an error", func() {
+				err := client.Register(app)
+				Expect(err).To(MatchError(ContainSubstring("Error registering app with Consul")))
+			})
+		})
+	})
+
+	Describe(".SetStatus", func() {
+		BeforeEach(func() {
+			Expect(client.Register(app)).To(Succeed())
+		})
+
+		It("passes the check for UP", func() {
+			Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+			Expect(agent.Check(checkID)).To(Equal("passing"))
+		})
+
+		It("fails the check otherwise", func() {
+			Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+			Expect(client.SetStatus(app, discovery.StatusDown)).To(Succeed())
+			Expect(agent.Check(checkID)).To(Equal("critical"))
+			Expect(agent.Output(checkID)).To(Equal("DOWN"))
+		})
+
+		It("puts the instance into maintenance for OUT_OF_SERVICE", func() {
+			Expect(client.SetStatus(app, discovery.StatusOutOfService)).To(Succeed())
+			Expect(agent.Maintenance(serviceID)).To(BeTrue())
+
+			Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+			Expect(agent.Maintenance(serviceID)).To(BeFalse())
+			Expect(agent.Check(checkID)).To(Equal("passing"))
+		})
+
+		It("keeps the status when registering again", func() {
+			Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+			Expect(client.Register(app)).To(Succeed())
+			Expect(agent.Check(checkID)).To(Equal("passing"))
+		})
+	})
+
+	Describe(".Heartbeat", func() {
+		BeforeEach(func() {
+			Expect(client.Register(app)).To(Succeed())
+		})
+
+		It("passes the check of an instance that is UP", func() {
+			Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+			Expect(client.Heartbeat(app)).To(Succeed())
+			Expect(agent.Check(checkID)).To(Equal("passing"))
+		})
+
+		It("fails the check of an instance that is not UP", func() {
+			Expect(client.Heartbeat(app)).To(Succeed())
+			Expect(agent.Check(checkID)).To(Equal("critical"))
+			Expect(agent.Output(checkID)).To(Equal("STARTING"))
+		})
+
+		Context("when the agent does not know the check", func() {
+			BeforeEach(func() {
+				Expect(client.Deregister(app)).To(Succeed())
+			})
+
+			It("reports the instance as not registered", func() {
+				err := client.Heartbeat(app)
+				Expect(err).To(MatchError(ContainSubstring("Error sending heartbeat for app to Consul")))
+				Expect(discovery.IsNotRegistered(err)).To(BeTrue())
+			})
+		})
+
+		Context("when an older agent does not know the check", func() {
+			It("reports the instance as not registered", func() {
+				err := &Error{Code: 500, Err: &StatusError{Code: 500, Body: `CheckID "service:x" does not have associated TTL`}}
+				Expect(discovery.IsNotRegistered(err)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe(".Deregister", func() {
+		It("removes the service", func() {
+			Expect(client.Register(app)).To(Succeed())
+			Expect(client.Deregister(app)).To(Succeed())
+
+			_, ok := agent.Service(serviceID)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("forgets the status", func() {
+			Expect(client.Register(app)).To(Succeed())
+			Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+			Expect(client.Deregister(app)).To(Succeed())
+
+			Expect(client.Register(app)).To(Succeed())
+			Expect(agent.Check(checkID)).To(Equal("critical"))
+		})
+	})
+
+	Context("when the instance ID contains a space", func() {
+		BeforeEach(func() {
+			app.Instance.ID = "instance id"
+			Expect(client.Register(app)).To(Succeed())
+		})
+
+		It("escapes it in the path", func() {
+			Expect(client.SetStatus(app, discovery.StatusOutOfService)).To(Succeed())
+			Expect(agent.Maintenance("app-name-instance id")).To(BeTrue())
+
+			Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+			Expect(agent.Check("service:app-name-instance id")).To(Equal("passing"))
+
+			Expect(client.Deregister(app)).To(Succeed())
+			_, ok := agent.Service("app-name-instance id")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("with a Registrar", func() {
+		It("keeps the check passing and deregisters on stop", func() {
+			client = NewClient(server.URL, time.Second, WithCheckTTL(30*time.Millisecond))
+			registrar := discovery.NewRegistrar(client, app)
+			Expect(registrar.Start(context.Background())).To(Succeed())
+
+			Eventually(func() string {
+				return agent.Check(checkID)
+			}).Should(Equal("passing"))
+
+			Expect(registrar.Stop(context.Background())).To(Succeed())
+			_, ok := agent.Service(serviceID)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
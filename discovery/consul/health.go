@@ -0,0 +1,337 @@
+package consul
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/st3v/cfkit/discovery"
+)
+
+type healthEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		ID      string
+		Service string
+		Tags    []string
+		Address string
+		Port    int
+		Meta    map[string]string
+	}
+	Checks []struct {
+		CheckID string
+		Status  string
+	}
+}
+
+// watch keeps the instances of a service up to date using blocking queries.
+type watch struct {
+	mutex sync.RWMutex
+	app   discovery.Application
+	index uint64
+}
+
+func (w *watch) get() (discovery.Application, uint64) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.app, w.index
+}
+
+func (w *watch) set(app discovery.Application, index uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.app, w.index = app, index
+}
+
+func (c *Client) Apps() (map[string]discovery.Application, error) {
+	return c.AppsContext(context.Background())
+}
+
+// AppsContext looks up the instances of every service in the catalog. Unlike
+// App, it does not start watching the services.
+func (c *Client) AppsContext(ctx context.Context) (map[string]discovery.Application, error) {
+	resp, err := c.do(ctx, "GET", "/v1/catalog/services", c.query(), nil)
+	if err != nil {
+		return map[string]discovery.Application{}, fmt.Errorf("Error retrieving apps from Consul: %s", err)
+	}
+	defer resp.Body.Close()
+
+	services := map[string][]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&services); err != nil {
+		return map[string]discovery.Application{}, fmt.Errorf("Error retrieving apps from Consul: %s", err)
+	}
+
+	apps := map[string]discovery.Application{}
+	for name := range services {
+		app, err := c.cachedApp(ctx, name)
+		if err != nil {
+			return map[string]discovery.Application{}, fmt.Errorf("Error retrieving apps from Consul: %s", err)
+		}
+		if len(app.Instances) > 0 {
+			apps[name] = app
+		}
+	}
+
+	return apps, nil
+}
+
+func (c *Client) App(name string) (discovery.Application, error) {
+	return c.AppContext(context.Background(), name)
+}
+
+// AppContext looks up the instances of the named service and keeps watching
+// it for changes until the client is closed or the service is gone. Unknown
+// services are not watched. Instances failing their checks are returned as
+// DOWN rather than left out, as with Eureka, so that an app whose instances
+// are all down is not mistaken for an unknown host. The Balancer only picks
+// instances that are UP.
+func (c *Client) AppContext(ctx context.Context, name string) (discovery.Application, error) {
+	c.mutex.Lock()
+	w, ok := c.watches[name]
+	c.mutex.Unlock()
+
+	var app discovery.Application
+	if ok {
+		app, _ = w.get()
+	} else {
+		var (
+			index uint64
+			err   error
+		)
+		app, index, err = c.health(ctx, name, 0)
+		if err != nil {
+			return discovery.Application{}, newError(fmt.Sprintf("Error retrieving app '%s' from Consul", name), err)
+		}
+		if len(app.Instances) > 0 {
+			c.startWatch(name, app, index)
+		}
+	}
+
+	if len(app.Instances) == 0 {
+		return app, &Error{
+			Code:    http.StatusNotFound,
+			Err:     errors.New("not found"),
+			message: fmt.Sprintf("Error retrieving app '%s' from Consul", name),
+		}
+	}
+
+	return app, nil
+}
+
+// VIP returns the instances of the service with the given name, Consul does
+// not know virtual addresses.
+func (c *Client) VIP(vip string) ([]discovery.Instance, error) {
+	return c.VIPContext(context.Background(), vip)
+}
+
+func (c *Client) VIPContext(ctx context.Context, vip string) ([]discovery.Instance, error) {
+	app, err := c.AppContext(ctx, vip)
+	if err != nil {
+		return []discovery.Instance{}, err
+	}
+	return app.Instances, nil
+}
+
+// SecureVIP returns the instances of the service with the given name that
+// have been registered with a secure port.
+func (c *Client) SecureVIP(vip string) ([]discovery.Instance, error) {
+	return c.SecureVIPContext(context.Background(), vip)
+}
+
+func (c *Client) SecureVIPContext(ctx context.Context, vip string) ([]discovery.Instance, error) {
+	app, err := c.AppContext(ctx, vip)
+	if err != nil {
+		return []discovery.Instance{}, err
+	}
+
+	return app.Filter(func(inst discovery.Instance) bool {
+		return inst.SecurePortEnabled
+	}).Instances, nil
+}
+
+// cachedApp returns the instances of a watched service or looks them up.
+func (c *Client) cachedApp(ctx context.Context, name string) (discovery.Application, error) {
+	c.mutex.Lock()
+	w, ok := c.watches[name]
+	c.mutex.Unlock()
+
+	if ok {
+		app, _ := w.get()
+		return app, nil
+	}
+
+	app, _, err := c.health(ctx, name, 0)
+	return app, err
+}
+
+func (c *Client) startWatch(name string, app discovery.Application, index uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.watches[name]; ok || c.ctx.Err() != nil {
+		return
+	}
+
+	w := &watch{app: app, index: index}
+	c.watches[name] = w
+	go c.watch(name, w)
+}
+
+// watch keeps issuing blocking queries until the client is closed or the
+// service has no instances left, backing off whenever a query fails.
+func (c *Client) watch(name string, w *watch) {
+	b := discovery.NewBackOff(discovery.SystemClock)
+
+	for {
+		_, index := w.get()
+		app, next, err := c.health(c.ctx, name, index)
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Printf("Error watching app '%s' in Consul: %s\n", name, err)
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(b.NextBackOff()):
+			}
+			continue
+		}
+		b.Reset()
+
+		if len(app.Instances) == 0 {
+			c.mutex.Lock()
+			delete(c.watches, name)
+			c.mutex.Unlock()
+			return
+		}
+
+		// the index must only grow, start over if it went backwards
+		switch {
+		case next < index:
+			next = 0
+		case next == 0:
+			next = 1
+		}
+
+		w.set(app, next)
+	}
+}
+
+// health queries the instances of a service, blocking until the index
+// changes if one is given.
+func (c *Client) health(ctx context.Context, name string, index uint64) (discovery.Application, uint64, error) {
+	query := c.query()
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", c.waitTime.String())
+	}
+
+	resp, err := c.do(ctx, "GET", "/v1/health/service/"+pathEscape(name), query, nil)
+	if err != nil {
+		return discovery.Application{}, 0, err
+	}
+	defer resp.Body.Close()
+
+	var entries []healthEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return discovery.Application{}, 0, err
+	}
+
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	return application(name, entries), next, nil
+}
+
+func (c *Client) query() url.Values {
+	query := url.Values{}
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+	return query
+}
+
+func application(name string, entries []healthEntry) discovery.Application {
+	app := discovery.Application{Name: name, Instances: []discovery.Instance{}}
+	for _, e := range entries {
+		app.Instances = append(app.Instances, instance(e))
+	}
+	return app
+}
+
+func instance(e healthEntry) discovery.Instance {
+	inst := discovery.Instance{
+		ID:          e.Service.ID,
+		App:         e.Service.Service,
+		HostName:    e.Service.Address,
+		IPAddr:      e.Node.Address,
+		Port:        e.Service.Port,
+		PortEnabled: true,
+		VIPAddress:  e.Service.Service,
+		Status:      status(e),
+		Metadata:    metadata(e.Service.Tags, e.Service.Meta),
+	}
+
+	if inst.HostName == "" {
+		inst.HostName = e.Node.Address
+	}
+
+	inst.Zone = inst.Metadata["zone"]
+
+	if inst.Metadata["secure"] == "true" {
+		inst.SecurePort, inst.SecurePortEnabled = inst.Port, true
+		inst.SecureVIPAddress = inst.VIPAddress
+		inst.PortEnabled = false
+	}
+
+	return inst
+}
+
+// status is UP if all checks pass, OUT_OF_SERVICE in maintenance mode and
+// DOWN if any check is critical.
+func status(e healthEntry) discovery.Status {
+	status := discovery.StatusUp
+	for _, check := range e.Checks {
+		switch {
+		case strings.HasPrefix(check.CheckID, "_service_maintenance"),
+			strings.HasPrefix(check.CheckID, "_node_maintenance"):
+			return discovery.StatusOutOfService
+		case check.Status == "critical":
+			status = discovery.StatusDown
+		}
+	}
+	return status
+}
+
+// metadata merges tags, with key=value tags split, and service meta, which
+// takes precedence.
+func metadata(tags []string, meta map[string]string) map[string]string {
+	result := map[string]string{}
+
+	for _, tag := range tags {
+		parts := strings.SplitN(tag, "=", 2)
+		if len(parts) == 2 {
+			result[parts[0]] = parts[1]
+		} else {
+			result[tag] = ""
+		}
+	}
+
+	for k, v := range meta {
+		result[k] = v
+	}
+
+	return result
+}
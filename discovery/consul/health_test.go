@@ -0,0 +1,242 @@
+package consul_test
+
+import (
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	"github.com/st3v/cfkit/discovery"
+	. "github.com/st3v/cfkit/discovery/consul"
+)
+
+var _ = Describe("lookups", func() {
+	var (
+		agent  *fakeAgent
+		server *httptest.Server
+		client *Client
+	)
+
+	register := func(id, address string, port int, tags []string, meta map[string]string, status string) {
+		r := registration{ID: id, Name: "app-name", Tags: tags, Address: address, Port: port, Meta: meta}
+		r.Check.CheckID = "service:" + id
+		r.Check.Status = status
+		agent.Register(r)
+	}
+
+	BeforeEach(func() {
+		agent = newFakeAgent()
+		server = httptest.NewServer(agent)
+
+		register("a", "a.example.com", 443, []string{"v1", "team=blue"}, map[string]string{"secure": "true", "zone": "zone-1"}, "passing")
+		register("b", "", 8080, nil, map[string]string{"team": "red"}, "critical")
+
+		client = NewClient(server.URL, time.Second, WithWaitTime(100*time.Millisecond))
+	})
+
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+	})
+
+	Describe(".App", func() {
+		It("returns the instances of the service", func() {
+			result, err := client.App("app-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Name).To(Equal("app-name"))
+			Expect(result.Instances).To(Equal([]discovery.Instance{
+				{
+					ID:                "a",
+					App:               "app-name",
+					HostName:          "a.example.com",
+					IPAddr:            "10.0.0.1",
+					Port:              443,
+					SecurePort:        443,
+					SecurePortEnabled: true,
+					VIPAddress:        "app-name",
+					SecureVIPAddress:  "app-name",
+					Status:            discovery.StatusUp,
+					Zone:              "zone-1",
+					Metadata:          map[string]string{"v1": "", "team": "blue", "secure": "true", "zone": "zone-1"},
+				},
+				{
+					ID:          "b",
+					App:         "app-name",
+					HostName:    "10.0.0.1",
+					IPAddr:      "10.0.0.1",
+					Port:        8080,
+					PortEnabled: true,
+					VIPAddress:  "app-name",
+					Status:      discovery.StatusDown,
+					Metadata:    map[string]string{"team": "red"},
+				},
+			}))
+			Expect(result.Instances[0].BaseURL()).To(Equal("https://a.example.com"))
+		})
+
+		It("reports instances in maintenance as OUT_OF_SERVICE", func() {
+			agent.setMaintenance("a", true)
+			client = NewClient(server.URL, time.Second)
+
+			result, err := client.App("app-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Instances[0].Status).To(Equal(discovery.StatusOutOfService))
+		})
+
+		It("keeps watching the service with blocking queries", func() {
+			_, err := client.App("app-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			register("c", "c.example.com", 8080, nil, nil, "passing")
+			Eventually(func() int {
+				result, _ := client.App("app-name")
+				return len(result.Instances)
+			}).Should(Equal(3))
+
+			blocking, nonBlocking := agent.HealthQueries()
+			Expect(nonBlocking).To(Equal(1))
+			Expect(blocking).To(BeNumerically(">", 0))
+
+			for _, r := range agent.Requests() {
+				if _, ok := r.Query["index"]; ok {
+					Expect(r.Query["wait"]).To(Equal([]string{"100ms"}))
+				}
+			}
+		})
+
+		It("stops watching once the client is closed", func() {
+			client.App("app-name")
+			client.Close()
+
+			time.Sleep(50 * time.Millisecond)
+			count := len(agent.Requests())
+			Consistently(func() int {
+				return len(agent.Requests())
+			}, 300*time.Millisecond).Should(Equal(count))
+		})
+
+		It("stops watching the service once it is gone", func() {
+			_, err := client.App("app-name")
+			Expect(err).ToNot(HaveOccurred())
+
+			agent.deregister("a")
+			agent.deregister("b")
+
+			Eventually(func() int {
+				blocking, _ := agent.HealthQueries()
+				return blocking
+			}).Should(BeNumerically(">", 0))
+
+			time.Sleep(50 * time.Millisecond)
+			blocking, _ := agent.HealthQueries()
+			Consistently(func() int {
+				blocking, _ := agent.HealthQueries()
+				return blocking
+			}, 300*time.Millisecond).Should(Equal(blocking))
+
+			_, err = client.App("app-name")
+			Expect(discovery.IsUnknownApp(err)).To(BeTrue())
+		})
+
+		Context("when the service is unknown", func() {
+			It("reports the app as unknown", func() {
+				_, err := client.App("unknown")
+				Expect(err).To(MatchError("Error retrieving app 'unknown' from Consul: not found"))
+				Expect(discovery.IsUnknownApp(err)).To(BeTrue())
+			})
+
+			It("does not watch it", func() {
+				for i := 0; i < 3; i++ {
+					client.App("unknown")
+				}
+
+				Consistently(func() int {
+					blocking, _ := agent.HealthQueries()
+					return blocking
+				}, 300*time.Millisecond).Should(BeZero())
+
+				_, nonBlocking := agent.HealthQueries()
+				Expect(nonBlocking).To(Equal(3))
+			})
+		})
+
+		Context("when the name contains a space", func() {
+			BeforeEach(func() {
+				r := registration{ID: "c", Name: "app name", Port: 8080}
+				r.Check.CheckID = "service:c"
+				r.Check.Status = "passing"
+				agent.Register(r)
+			})
+
+			It("escapes it in the path", func() {
+				result, err := client.App("app name")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.Instances).To(HaveLen(1))
+				Expect(result.Instances[0].ID).To(Equal("c"))
+			})
+		})
+
+		Context("when the agent fails", func() {
+			BeforeEach(func() {
+				server.Close()
+			})
+
+			It("returns an error", func() {
+				_, err := client.App("app-name")
+				Expect(err).To(MatchError(ContainSubstring("Error retrieving app 'app-name' from Consul")))
+				Expect(discovery.IsUnknownApp(err)).To(BeFalse())
+			})
+		})
+
+		Context("when a datacenter is given", func() {
+			BeforeEach(func() {
+				client = NewClient(server.URL, time.Second, WithDatacenter("dc-2"))
+			})
+
+			It("queries the datacenter", func() {
+				client.App("app-name")
+				Expect(agent.Requests()[0].Query["dc"]).To(Equal([]string{"dc-2"}))
+			})
+		})
+	})
+
+	Describe(".Apps", func() {
+		It("returns the services with instances", func() {
+			result, err := client.Apps()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+			Expect(result["app-name"].Instances).To(HaveLen(2))
+		})
+	})
+
+	Describe(".VIP", func() {
+		It("returns the instances of the service", func() {
+			instances, err := client.VIP("app-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(HaveLen(2))
+		})
+	})
+
+	Describe(".SecureVIP", func() {
+		It("returns the instances with a secure port", func() {
+			instances, err := client.SecureVIP("app-name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(HaveLen(1))
+			Expect(instances[0].ID).To(Equal("a"))
+		})
+	})
+
+	Describe("balancing", func() {
+		It("picks healthy instances only", func() {
+			balancer := discovery.NewBalancer(client)
+			for i := 0; i < 3; i++ {
+				inst, done, err := balancer.Pick(context.Background(), "app-name")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(inst.ID).To(Equal("a"))
+				done()
+			}
+		})
+	})
+})
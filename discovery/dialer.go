@@ -0,0 +1,212 @@
+package discovery
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Default circuit breaking of a Dialer: an instance is left out for
+// DefaultCircuitOpenTimeout after DefaultCircuitThreshold consecutive failed
+// connection attempts.
+var (
+	DefaultCircuitThreshold   = 3
+	DefaultCircuitOpenTimeout = 30 * time.Second
+)
+
+type DialerOption func(*Dialer)
+
+// WithDialFunc sets the function connecting to the picked instances, net.Dial
+// by default.
+func WithDialFunc(dial func(network, address string) (net.Conn, error)) DialerOption {
+	return func(d *Dialer) {
+		d.dial = dial
+	}
+}
+
+// WithCircuitBreaker leaves out an instance for the given time after the given
+// number of consecutive failed connection attempts. Once the time is up, a
+// single failure opens the circuit again.
+func WithCircuitBreaker(threshold int, openTimeout time.Duration) DialerOption {
+	return func(d *Dialer) {
+		d.threshold = threshold
+		d.openTimeout = openTimeout
+	}
+}
+
+// Dialer connects to logical addresses of the form app-name:port, picking an
+// instance of the app through the balancer and connecting to the given port
+// on its host. Addresses of apps unknown to the discovery service are dialed
+// unchanged.
+type Dialer struct {
+	balancer    *Balancer
+	dial        func(network, address string) (net.Conn, error)
+	threshold   int
+	openTimeout time.Duration
+
+	mutex    sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+func NewDialer(balancer *Balancer, opts ...DialerOption) *Dialer {
+	d := &Dialer{
+		balancer:    balancer,
+		dial:        net.Dial,
+		threshold:   DefaultCircuitThreshold,
+		openTimeout: DefaultCircuitOpenTimeout,
+		circuits:    map[string]*circuit{},
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext fails over to another instance whenever it cannot connect to
+// the picked one, until it runs out of instances or ctx is done.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	name, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tried   []Instance
+		lastErr error
+	)
+
+	for {
+		skip := append(d.openCircuits(ctx, name), tried...)
+		inst, done, err := d.balancer.Pick(ctx, name, skip...)
+		switch {
+		case IsUnknownApp(err):
+			return d.dialContext(ctx, network, address)
+		case err != nil && lastErr != nil:
+			return nil, lastErr
+		case err != nil:
+			return nil, err
+		}
+
+		conn, err := d.dialContext(ctx, network, net.JoinHostPort(inst.HostName, port))
+		if err == nil {
+			d.succeeded(inst)
+			return &releasingConn{Conn: conn, release: done}, nil
+		}
+
+		done()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		d.failed(inst)
+		tried = append(tried, inst)
+		lastErr = err
+	}
+}
+
+// CircuitOpen reports whether the instance is currently left out after failed
+// connection attempts.
+func (d *Dialer) CircuitOpen(inst Instance) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.open(instanceKey(inst), time.Now())
+}
+
+func (d *Dialer) open(key string, now time.Time) bool {
+	c, ok := d.circuits[key]
+	return ok && now.Before(c.openUntil)
+}
+
+// openCircuits returns the instances of the app that are left out.
+func (d *Dialer) openCircuits(ctx context.Context, name string) []Instance {
+	app, err := d.balancer.lookup(ctx, name)
+	if err != nil {
+		return nil
+	}
+
+	now := time.Now()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var open []Instance
+	for _, inst := range app.Instances {
+		if d.open(instanceKey(inst), now) {
+			open = append(open, inst)
+		}
+	}
+	return open
+}
+
+func (d *Dialer) succeeded(inst Instance) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.circuits, instanceKey(inst))
+}
+
+func (d *Dialer) failed(inst Instance) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	key := instanceKey(inst)
+	c, ok := d.circuits[key]
+	if !ok {
+		c = &circuit{}
+		d.circuits[key] = c
+	}
+
+	c.failures++
+	if c.failures >= d.threshold {
+		c.openUntil = time.Now().Add(d.openTimeout)
+	}
+}
+
+// dialContext gives up waiting for the connection once ctx is done.
+func (d *Dialer) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		conn, err := d.dial(network, address)
+		results <- result{conn, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-results; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// releasingConn ends the pick of the instance once the connection is closed.
+type releasingConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *releasingConn) Close() error {
+	err := c.Conn.Close()
+	c.release()
+	return err
+}
@@ -0,0 +1,284 @@
+package discovery_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	. "github.com/st3v/cfkit/discovery"
+	"github.com/st3v/cfkit/discovery/fake"
+)
+
+// fakeNetwork connects to any address unless told otherwise.
+type fakeNetwork struct {
+	mutex   sync.Mutex
+	dialed  []string
+	failing map[string]bool
+	hang    chan struct{}
+}
+
+func (n *fakeNetwork) Dial(network, address string) (net.Conn, error) {
+	n.mutex.Lock()
+	n.dialed = append(n.dialed, address)
+	failing, hang := n.failing[address], n.hang
+	n.mutex.Unlock()
+
+	if hang != nil {
+		<-hang
+	}
+
+	if failing {
+		return nil, errors.New("connection refused")
+	}
+
+	conn, _ := net.Pipe()
+	return conn, nil
+}
+
+func (n *fakeNetwork) Dialed() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return append([]string{}, n.dialed...)
+}
+
+var _ = Describe("Dialer", func() {
+	var (
+		fakeClient *fake.Client
+		network    *fakeNetwork
+		balancer   *Balancer
+		dialer     *Dialer
+		app        Application
+	)
+
+	dial := func() net.Conn {
+		conn, err := dialer.Dial("tcp", "app-name:8080")
+		Expect(err).ToNot(HaveOccurred())
+		return conn
+	}
+
+	BeforeEach(func() {
+		app = Application{Name: "app-name", Instances: []Instance{
+			{ID: "a", App: "app-name", HostName: "host-a", Status: StatusUp},
+			{ID: "b", App: "app-name", HostName: "host-b", Status: StatusUp},
+		}}
+
+		fakeClient = new(fake.Client)
+		fakeClient.AppContextReturns(app, nil)
+
+		network = &fakeNetwork{failing: map[string]bool{}}
+		balancer = NewBalancer(fakeClient)
+		dialer = NewDialer(balancer, WithDialFunc(network.Dial))
+	})
+
+	It("connects to the given port of the app's instances", func() {
+		dial().Close()
+		dial().Close()
+		Expect(network.Dialed()).To(Equal([]string{"host-a:8080", "host-b:8080"}))
+
+		_, name := fakeClient.AppContextArgsForCall(0)
+		Expect(name).To(Equal("app-name"))
+	})
+
+	It("releases the instance once the connection is closed", func() {
+		conn := dial()
+		Expect(balancer.InFlight(app.Instances[0])).To(Equal(1))
+
+		conn.Close()
+		Expect(balancer.InFlight(app.Instances[0])).To(BeZero())
+	})
+
+	It("rejects addresses without a port", func() {
+		_, err := dialer.Dial("tcp", "app-name")
+		Expect(err).To(HaveOccurred())
+		Expect(network.Dialed()).To(BeEmpty())
+	})
+
+	Context("when the app is unknown", func() {
+		BeforeEach(func() {
+			fakeClient.AppContextReturns(Application{}, notFoundError(true))
+		})
+
+		It("dials the address unchanged", func() {
+			dial().Close()
+			Expect(network.Dialed()).To(Equal([]string{"app-name:8080"}))
+		})
+	})
+
+	Context("when the lookup fails", func() {
+		BeforeEach(func() {
+			fakeClient.AppContextReturns(Application{}, errors.New("some-error"))
+		})
+
+		It("returns the error", func() {
+			_, err := dialer.Dial("tcp", "app-name:8080")
+			Expect(err).To(MatchError("some-error"))
+		})
+	})
+
+	Context("when an instance cannot be reached", func() {
+		BeforeEach(func() {
+			network.failing["host-a:8080"] = true
+		})
+
+		It("fails over to another instance", func() {
+			dial().Close()
+			Expect(network.Dialed()).To(Equal([]string{"host-a:8080", "host-b:8080"}))
+		})
+
+		It("does not count the failed attempt as in flight", func() {
+			defer dial().Close()
+			Expect(balancer.InFlight(app.Instances[0])).To(BeZero())
+		})
+
+		Context("and neither can the others", func() {
+			BeforeEach(func() {
+				network.failing["host-b:8080"] = true
+			})
+
+			It("returns the last error", func() {
+				_, err := dialer.Dial("tcp", "app-name:8080")
+				Expect(err).To(MatchError("connection refused"))
+				Expect(network.Dialed()).To(HaveLen(2))
+			})
+		})
+	})
+
+	Describe("circuit breaking", func() {
+		BeforeEach(func() {
+			network.failing["host-a:8080"] = true
+
+			// always try host-a first
+			balancer = NewBalancer(fakeClient, WithStrategy(func(candidates []Instance, _ func(Instance) int) Instance {
+				return candidates[0]
+			}))
+			dialer = NewDialer(balancer, WithDialFunc(network.Dial), WithCircuitBreaker(2, 50*time.Millisecond))
+		})
+
+		It("leaves out an instance after repeated failures", func() {
+			dial().Close()
+			Expect(dialer.CircuitOpen(app.Instances[0])).To(BeFalse())
+
+			dial().Close()
+			Expect(dialer.CircuitOpen(app.Instances[0])).To(BeTrue())
+
+			dial().Close()
+			Expect(network.Dialed()).To(Equal([]string{
+				"host-a:8080", "host-b:8080",
+				"host-a:8080", "host-b:8080",
+				"host-b:8080",
+			}))
+		})
+
+		It("tries the instance again after a while", func() {
+			dial().Close()
+			dial().Close()
+
+			delete(network.failing, "host-a:8080")
+			Eventually(func() bool {
+				return dialer.CircuitOpen(app.Instances[0])
+			}).Should(BeFalse())
+
+			dial().Close()
+			dialed := network.Dialed()
+			Expect(dialed[len(dialed)-1]).To(Equal("host-a:8080"))
+		})
+
+		It("reopens the circuit upon the next failure", func() {
+			dial().Close()
+			dial().Close()
+			Eventually(func() bool {
+				return dialer.CircuitOpen(app.Instances[0])
+			}).Should(BeFalse())
+
+			dial().Close()
+			Expect(dialer.CircuitOpen(app.Instances[0])).To(BeTrue())
+		})
+
+		It("closes the circuit after a success", func() {
+			dial().Close()
+			delete(network.failing, "host-a:8080")
+			dial().Close()
+
+			network.failing["host-a:8080"] = true
+			dial().Close()
+			Expect(dialer.CircuitOpen(app.Instances[0])).To(BeFalse())
+		})
+
+		Context("when every circuit is open", func() {
+			BeforeEach(func() {
+				network.failing["host-b:8080"] = true
+				dialer.Dial("tcp", "app-name:8080")
+				dialer.Dial("tcp", "app-name:8080")
+			})
+
+			It("returns an error without dialing", func() {
+				dialed := len(network.Dialed())
+				_, err := dialer.Dial("tcp", "app-name:8080")
+				Expect(err).To(MatchError("No instance of app 'app-name' available"))
+				Expect(network.Dialed()).To(HaveLen(dialed))
+			})
+		})
+	})
+
+	Context("when the context is done", func() {
+		BeforeEach(func() {
+			network.hang = make(chan struct{})
+		})
+
+		AfterEach(func() {
+			close(network.hang)
+		})
+
+		It("gives up connecting", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			_, err := dialer.DialContext(ctx, "tcp", "app-name:8080")
+			Expect(err).To(Equal(context.DeadlineExceeded))
+			Expect(network.Dialed()).To(HaveLen(1))
+			Expect(dialer.CircuitOpen(app.Instances[0])).To(BeFalse())
+		})
+	})
+
+	Context("when used by an http.Transport", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Write([]byte("hello"))
+			}))
+
+			u, _ := url.Parse(server.URL)
+			host, _, _ := net.SplitHostPort(u.Host)
+			app.Instances[0].HostName = host
+			fakeClient.AppContextReturns(Application{Name: "app-name", Instances: app.Instances[:1]}, nil)
+
+			dialer = NewDialer(balancer)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("connects to the app", func() {
+			_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+			client := &http.Client{Transport: &http.Transport{Dial: dialer.Dial}}
+
+			resp, err := client.Get("http://app-name:" + port + "/")
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			Expect(string(body)).To(Equal("hello"))
+		})
+	})
+})
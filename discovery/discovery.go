@@ -1,130 +1,259 @@
 package discovery
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
+	"math/rand"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
 
 	"github.com/st3v/cfkit/env"
-	"github.com/st3v/cfkit/service"
 )
 
 var (
-	retryTimeout                          = 10 * time.Second
-	clientProvider func() (Client, error) = eurekaProvider
-	appProvider                           = env.Application
-	exit                                  = os.Exit
-	cancel         context.CancelFunc
-)
+	appProvider = env.Application
 
-func eurekaProvider() (Client, error) {
-	return service.Eureka()
-}
+	defaultMutex     sync.Mutex
+	defaultRegistrar *Registrar
+)
 
 type Client interface {
 	Register(app env.App) error
 	Deregister(app env.App) error
 	Heartbeat(app env.App) error
+	SetStatus(app env.App, status Status) error
 	HeartbeatInterval() time.Duration
-	Apps() (map[string][]string, error)
-	App(name string) ([]string, error)
+	Apps() (map[string]Application, error)
+	App(name string) (Application, error)
+	VIP(vip string) ([]Instance, error)
+	SecureVIP(vip string) ([]Instance, error)
+
+	// context-aware variants, abandoning the request once the context is done
+	RegisterContext(ctx context.Context, app env.App) error
+	DeregisterContext(ctx context.Context, app env.App) error
+	HeartbeatContext(ctx context.Context, app env.App) error
+	SetStatusContext(ctx context.Context, app env.App, status Status) error
+	AppsContext(ctx context.Context) (map[string]Application, error)
+	AppContext(ctx context.Context, name string) (Application, error)
+	VIPContext(ctx context.Context, vip string) ([]Instance, error)
+	SecureVIPContext(ctx context.Context, vip string) ([]Instance, error)
 }
 
+// IsNotRegistered reports whether a Heartbeat error signals that the registry
+// does not know the instance, e.g. after a restart or an eviction.
+func IsNotRegistered(err error) bool {
+	e, ok := err.(notFound)
+	return ok && e.NotFound()
+}
+
+// IsUnknownApp reports whether an App lookup failed because the registry does
+// not know the app.
+func IsUnknownApp(err error) bool {
+	e, ok := err.(notFound)
+	return ok && e.NotFound()
+}
+
+type notFound interface {
+	NotFound() bool
+}
+
+type Status string
+
+const (
+	StatusUp           Status = "UP"
+	StatusDown         Status = "DOWN"
+	StatusStarting     Status = "STARTING"
+	StatusOutOfService Status = "OUT_OF_SERVICE"
+	StatusUnknown      Status = "UNKNOWN"
+)
+
 type Application struct {
 	Name      string
 	Instances []Instance
 }
 
+func (a Application) HostNames() []string {
+	hostNames := make([]string, len(a.Instances))
+	for i, inst := range a.Instances {
+		hostNames[i] = inst.HostName
+	}
+	return hostNames
+}
+
+// Filter returns a copy of the application with only those instances that
+// pass all given filters.
+func (a Application) Filter(filters ...InstanceFilter) Application {
+	result := Application{Name: a.Name, Instances: []Instance{}}
+	for _, inst := range a.Instances {
+		if accepts(inst, filters) {
+			result.Instances = append(result.Instances, inst)
+		}
+	}
+	return result
+}
+
+func accepts(inst Instance, filters []InstanceFilter) bool {
+	for _, keep := range filters {
+		if !keep(inst) {
+			return false
+		}
+	}
+	return true
+}
+
+// PreferZone returns a copy of the application with only the instances in the
+// given zone, provided one of them is UP. Otherwise all instances are kept.
+func (a Application) PreferZone(zone string) Application {
+	local := a.Filter(func(inst Instance) bool {
+		return inst.Zone == zone
+	})
+
+	for _, inst := range local.Instances {
+		if inst.Status == StatusUp {
+			return local
+		}
+	}
+
+	return a
+}
+
+type InstanceFilter func(Instance) bool
+
+// MetadataFilter keeps instances whose metadata contains all given values.
+func MetadataFilter(selector map[string]string) InstanceFilter {
+	return func(inst Instance) bool {
+		for k, v := range selector {
+			if value, ok := inst.Metadata[k]; !ok || value != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
 type Instance struct {
-	ID  string
-	URI string
+	ID                string
+	App               string
+	HostName          string
+	IPAddr            string
+	Port              int
+	PortEnabled       bool
+	SecurePort        int
+	SecurePortEnabled bool
+	VIPAddress        string
+	SecureVIPAddress  string
+	Status            Status
+	Zone              string
+	Metadata          map[string]string
 }
 
-func Disable() {
-	if cancel != nil {
-		cancel()
-		cancel = nil
+// BaseURL prefers the secure port if it is enabled and omits standard ports.
+func (i Instance) BaseURL() string {
+	scheme, port, stdPort := "http", i.Port, 80
+	if i.SecurePortEnabled {
+		scheme, port, stdPort = "https", i.SecurePort, 443
+	}
+
+	if port == 0 || port == stdPort {
+		return fmt.Sprintf("%s://%s", scheme, i.HostName)
 	}
+
+	return fmt.Sprintf("%s://%s:%d", scheme, i.HostName, port)
 }
 
-func Enable() {
-	if cancel != nil {
-		return
+// AppHostNames returns the host names of all instances of the given app.
+//
+// Deprecated: use Client.App instead.
+func AppHostNames(client Client, name string) ([]string, error) {
+	app, err := client.App(name)
+	if err != nil {
+		return []string{}, err
 	}
+	return app.HostNames(), nil
+}
 
-	client, err := clientProvider()
+// AppsHostNames returns the host names of all instances by app name.
+//
+// Deprecated: use Client.Apps instead.
+func AppsHostNames(client Client) (map[string][]string, error) {
+	apps, err := client.Apps()
 	if err != nil {
-		log.Printf("Error getting discovery service: %s\n", err)
-		exit(1)
+		return map[string][]string{}, err
+	}
+
+	result := map[string][]string{}
+	for name, app := range apps {
+		result[name] = app.HostNames()
+	}
+
+	return result, nil
+}
+
+// Disable stops the default registrar started by Enable.
+func Disable() {
+	if r := takeDefault(); r != nil {
+		if err := r.Stop(context.Background()); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// Enable registers the app described by the environment with the given
+// client, using a default Registrar. service.Discovery returns the client of
+// the registry bound to the app. Enable does not handle signals, see Shutdown
+// for deregistering the app on exit.
+func Enable(client Client, opts ...Option) error {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	if defaultRegistrar != nil {
+		return nil
+	}
+
+	if client == nil {
+		return errors.New("Error getting discovery service: no client given")
 	}
 
 	app, err := appProvider()
 	if err != nil {
-		log.Printf("Error getting app from env: %s\n", err)
-		exit(1)
-	}
-
-	var ctx context.Context
-	ctx, cancel = context.WithCancel(context.Background())
-	deregisterOnShutdown(client, app, ctx, cancel, exit)
-	registerAndKeepAlive(client, app, ctx)
-}
-
-func registerAndKeepAlive(client Client, app env.App, ctx context.Context) {
-	go func(retryTimeout time.Duration) {
-		// initial interval
-		interval := 10 * time.Millisecond
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(interval):
-				// subsequent interval
-				interval = retryTimeout
-
-				if err := client.Register(app); err != nil {
-					log.Println(err.Error())
-					continue
-				}
-				keepAlive(client, app, retryTimeout, ctx)
-			}
-		}
-	}(retryTimeout)
-}
-
-func keepAlive(client Client, app env.App, retryTimeout time.Duration, ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(client.HeartbeatInterval()):
-			if err := client.Heartbeat(app); err != nil {
-				log.Println(err.Error())
-				time.Sleep(retryTimeout)
-				return
-			}
-		}
+		return fmt.Errorf("Error getting app from env: %s", err)
 	}
+
+	r := NewRegistrar(client, app, opts...)
+	if err := r.Start(context.Background()); err != nil {
+		return err
+	}
+
+	defaultRegistrar = r
+	return nil
 }
 
-func deregisterOnShutdown(client Client, app env.App, ctx context.Context, cancel context.CancelFunc, exit func(int)) {
-	sigChan := make(chan os.Signal, 1)
+// takeDefault hands over the default registrar, allowing Enable to start a
+// new one.
+func takeDefault() *Registrar {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	r := defaultRegistrar
+	defaultRegistrar = nil
+	return r
+}
 
-	signal.Reset(syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM)
+func currentRegistrar() *Registrar {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultRegistrar
+}
 
-	go func() {
-		select {
-		case <-sigChan:
-			cancel()
-			client.Deregister(app)
-		case <-ctx.Done():
-			client.Deregister(app)
-		}
-		exit(1)
-	}()
+// jitter spreads heartbeats over the last tenth of the interval so that a
+// large fleet does not renew its leases in lockstep.
+func jitter(interval time.Duration) time.Duration {
+	spread := int64(interval / 10)
+	if spread <= 0 {
+		return interval
+	}
+	return interval - time.Duration(rand.Int63n(spread))
 }
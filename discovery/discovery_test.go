@@ -1,46 +1,33 @@
-package discovery
+package discovery_test
 
 import (
 	"errors"
-	"io"
-	"log"
 	"os"
-	"sync/atomic"
+	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/cenkalti/backoff"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	"github.com/onsi/gomega/gbytes"
+	. "github.com/st3v/cfkit/discovery"
 	"github.com/st3v/cfkit/discovery/fake"
 	"github.com/st3v/cfkit/env"
 )
 
 var _ = Describe("discovery", func() {
 	var (
-		fakeClient         *fake.Client
-		origClientProvider = clientProvider
-		expectedApp        env.App
-		exitCode           int32
+		fakeClient  *fake.Client
+		expectedApp env.App
 	)
 
 	BeforeEach(func() {
 		os.Setenv("VCAP_APPLICATION", vcapApplication)
 
-		// override os.Exit in tests
-		atomic.StoreInt32(&exitCode, 0)
-		exit = func(i int) {
-			atomic.AddInt32(&exitCode, 1)
-		}
-
 		fakeClient = new(fake.Client)
 		fakeClient.HeartbeatIntervalReturns(10 * time.Millisecond)
 
-		clientProvider = func() (Client, error) {
-			return fakeClient, nil
-		}
-
 		var err error
 		expectedApp, err = env.Application()
 		Expect(err).ToNot(HaveOccurred())
@@ -48,243 +35,364 @@ var _ = Describe("discovery", func() {
 
 	AfterEach(func() {
 		Disable()
-		clientProvider = origClientProvider
 		os.Unsetenv("VCAP_APPLICATION")
 	})
 
 	Describe(".Enable", func() {
 		It("registers the app with eureka", func() {
-			Enable()
-			Eventually(fakeClient.RegisterCallCount).Should(Equal(1))
-			Expect(fakeClient.RegisterArgsForCall(0)).To(Equal(expectedApp))
+			Enable(fakeClient)
+			Eventually(fakeClient.RegisterContextCallCount).Should(Equal(1))
+			_, registered := fakeClient.RegisterContextArgsForCall(0)
+			Expect(registered).To(Equal(expectedApp))
 		})
 
 		It("sends regular heartbeats", func() {
-			Enable()
-			Eventually(fakeClient.HeartbeatCallCount).Should(BeNumerically(">=", 10))
-			for i := 0; i < fakeClient.HeartbeatCallCount(); i++ {
-				Expect(fakeClient.HeartbeatArgsForCall(i)).To(Equal(expectedApp))
+			Enable(fakeClient)
+			Eventually(fakeClient.HeartbeatContextCallCount).Should(BeNumerically(">=", 10))
+			for i := 0; i < fakeClient.HeartbeatContextCallCount(); i++ {
+				_, app := fakeClient.HeartbeatContextArgsForCall(i)
+				Expect(app).To(Equal(expectedApp))
 			}
 		})
 
-		It("deregisters the app upon receiving SIGINT", func() {
-			Enable()
-			Consistently(fakeClient.DeregisterCallCount).Should(Equal(0))
-			syscall.Kill(syscall.Getpid(), syscall.SIGINT)
-			Eventually(fakeClient.DeregisterCallCount).Should(Equal(1))
-			Expect(atomic.LoadInt32(&exitCode)).ToNot(BeZero())
+		It("returns no error", func() {
+			Expect(Enable(fakeClient)).To(Succeed())
 		})
 
-		It("deregisters the app upon receiving SIGTERM", func() {
-			Enable()
-			Consistently(fakeClient.DeregisterCallCount).Should(Equal(0))
-			syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
-			Eventually(fakeClient.DeregisterCallCount).Should(Equal(1))
-			Expect(atomic.LoadInt32(&exitCode)).ToNot(BeZero())
-		})
+		It("leaves signals to the app", func() {
+			Expect(Enable(fakeClient)).To(Succeed())
+			Eventually(fakeClient.RegisterContextCallCount).Should(Equal(1))
+
+			sigChan := make(chan os.Signal, 1)
+			signal.Notify(sigChan, syscall.SIGHUP)
+			defer signal.Stop(sigChan)
 
-		It("deregisters the app upon receiving SIGHUP", func() {
-			Enable()
-			Consistently(fakeClient.DeregisterCallCount).Should(Equal(0))
 			syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
-			Eventually(fakeClient.DeregisterCallCount).Should(Equal(1))
-			Expect(atomic.LoadInt32(&exitCode)).ToNot(BeZero())
+			Eventually(sigChan).Should(Receive())
+			Consistently(fakeClient.DeregisterContextCallCount).Should(Equal(0))
 		})
 
 		Context("when it is called multiple times without having been disabled", func() {
-			var clientProviderCalled bool
-
 			BeforeEach(func() {
-				Enable()
-
-				clientProviderCalled = false
-
-				clientProvider = func() (Client, error) {
-					clientProviderCalled = true
-					return fakeClient, nil
-				}
+				Enable(fakeClient)
 			})
 
 			It("does not do anything", func() {
-				Enable()
-				Expect(clientProviderCalled).To(BeFalse())
+				other := new(fake.Client)
+				Expect(Enable(other)).To(Succeed())
+				Consistently(other.RegisterContextCallCount).Should(BeZero())
 			})
 		})
 
 		Context("when registering the app fails", func() {
 			BeforeEach(func() {
-				retryTimeout = 10 * time.Millisecond
-				fakeClient.RegisterReturns(errors.New("some-error"))
+				fakeClient.RegisterContextReturns(errors.New("some-error"))
 			})
 
 			It("keeps retrying", func() {
-				Enable()
-				Eventually(fakeClient.RegisterCallCount).Should(BeNumerically(">=", 10))
+				Enable(fakeClient, WithBackOff(backoff.NewConstantBackOff(10*time.Millisecond)))
+				Eventually(fakeClient.RegisterContextCallCount).Should(BeNumerically(">=", 10))
 			})
 
 			It("does not send heartbeats", func() {
-				Enable()
-				Consistently(fakeClient.HeartbeatCallCount).Should(Equal(0))
+				Enable(fakeClient, WithBackOff(backoff.NewConstantBackOff(10*time.Millisecond)))
+				Consistently(fakeClient.HeartbeatContextCallCount).Should(Equal(0))
+			})
+
+			It("gives up once the policy says so", func() {
+				Enable(fakeClient, WithBackOff(&backoff.StopBackOff{}))
+				Eventually(fakeClient.RegisterContextCallCount).Should(Equal(1))
+				Consistently(fakeClient.RegisterContextCallCount).Should(Equal(1))
 			})
 		})
 
 		Context("when sending the heartbeat fails", func() {
 			BeforeEach(func() {
-				retryTimeout = 10 * time.Millisecond
-				fakeClient.HeartbeatReturns(errors.New("some-error"))
+				fakeClient.HeartbeatContextReturns(errors.New("some-error"))
 			})
 
 			It("reregisters the app", func() {
-				Enable()
-				Eventually(fakeClient.RegisterCallCount).Should(BeNumerically(">=", 10))
+				Enable(fakeClient, WithBackOff(backoff.NewConstantBackOff(10*time.Millisecond)))
+				Eventually(fakeClient.RegisterContextCallCount).Should(BeNumerically(">=", 10))
 			})
 
 			It("keeps retrying", func() {
-				Enable()
-				Eventually(fakeClient.HeartbeatCallCount).Should(BeNumerically(">=", 10))
+				Enable(fakeClient, WithBackOff(backoff.NewConstantBackOff(10*time.Millisecond)))
+				Eventually(fakeClient.HeartbeatContextCallCount).Should(BeNumerically(">=", 10))
 			})
 		})
 
-		Context("when getting the eureka client fails", func() {
-			var (
-				expectedErr = errors.New("some-error")
-				buf         io.ReadWriter
-			)
-
+		Context("when the registry does not know the instance", func() {
 			BeforeEach(func() {
-				buf = gbytes.NewBuffer()
-				log.SetOutput(io.MultiWriter(buf, GinkgoWriter))
+				fakeClient.HeartbeatContextReturns(notFoundError(true))
+			})
 
-				clientProvider = func() (Client, error) {
-					return fakeClient, expectedErr
-				}
+			It("reregisters the app right away", func() {
+				Enable(fakeClient, WithBackOff(backoff.NewConstantBackOff(time.Hour)))
+				Eventually(fakeClient.RegisterContextCallCount).Should(BeNumerically(">=", 3))
 			})
+		})
 
-			AfterEach(func() {
-				log.SetOutput(GinkgoWriter)
+		Context("when the heartbeat fails in transport", func() {
+			BeforeEach(func() {
+				fakeClient.HeartbeatContextReturns(errors.New("connection refused"))
 			})
 
-			It("exits with a non-zero exit code", func() {
-				Enable()
-				Expect(atomic.LoadInt32(&exitCode)).ToNot(BeZero())
+			It("waits before reregistering the app", func() {
+				Enable(fakeClient, WithBackOff(backoff.NewConstantBackOff(time.Hour)))
+				Eventually(fakeClient.HeartbeatContextCallCount).Should(Equal(1))
+				Consistently(fakeClient.RegisterContextCallCount).Should(Equal(1))
 			})
+		})
 
-			It("logs the error", func() {
-				Enable()
-				Expect(buf).To(gbytes.Say(expectedErr.Error()))
+		Context("when no client is given", func() {
+			It("returns an error", func() {
+				Expect(Enable(nil)).To(MatchError("Error getting discovery service: no client given"))
+				Expect(Default()).To(BeNil())
 			})
 		})
 
 		Context("when getting the app from env fails", func() {
 			var (
-				origAppProvider = appProvider
+				origAppProvider = *AppProvider
 				expectedErr     = errors.New("some-error")
-				buf             io.ReadWriter
 			)
 
 			BeforeEach(func() {
-				buf = gbytes.NewBuffer()
-				log.SetOutput(io.MultiWriter(buf, GinkgoWriter))
-
-				appProvider = func() (env.App, error) {
+				*AppProvider = func() (env.App, error) {
 					return env.App{}, expectedErr
 				}
 			})
 
 			AfterEach(func() {
-				appProvider = origAppProvider
-				log.SetOutput(GinkgoWriter)
+				*AppProvider = origAppProvider
 			})
 
-			It("exits with a non-zero exit code", func() {
-				Enable()
-				Expect(atomic.LoadInt32(&exitCode)).ToNot(BeZero())
+			It("returns the error", func() {
+				Expect(Enable(fakeClient)).To(MatchError("Error getting app from env: some-error"))
 			})
 
-			It("logs the error", func() {
-				Enable()
-				Expect(buf).To(gbytes.Say(expectedErr.Error()))
+			It("does not register the app", func() {
+				Enable(fakeClient)
+				Consistently(fakeClient.RegisterContextCallCount).Should(BeZero())
 			})
 		})
 	})
 
 	Describe(".Disable", func() {
 		BeforeEach(func() {
-			Enable()
+			Enable(fakeClient)
 		})
 
 		It("deregisters the app", func() {
-			Consistently(fakeClient.DeregisterCallCount).Should(Equal(0))
+			Consistently(fakeClient.DeregisterContextCallCount).Should(Equal(0))
 			Disable()
-			Eventually(fakeClient.DeregisterCallCount).Should(Equal(1))
+			Eventually(fakeClient.DeregisterContextCallCount).Should(Equal(1))
 		})
 
 		It("stops registering the app", func() {
 			Disable()
-			callCount := fakeClient.RegisterCallCount()
-			Consistently(fakeClient.RegisterCallCount).Should(Equal(callCount))
+			callCount := fakeClient.RegisterContextCallCount()
+			Consistently(fakeClient.RegisterContextCallCount).Should(Equal(callCount))
 		})
 
 		It("stops sending heartbeats for the app", func() {
 			Disable()
-			callCount := fakeClient.HeartbeatCallCount()
-			Consistently(fakeClient.HeartbeatCallCount).Should(Equal(callCount))
-		})
-
-		It("cancels the internal context", func() {
-			cancelCalled := false
-			cancel = func() {
-				cancelCalled = true
-			}
-			Disable()
-			Expect(cancelCalled).To(BeTrue())
+			callCount := fakeClient.HeartbeatContextCallCount()
+			Consistently(fakeClient.HeartbeatContextCallCount).Should(Equal(callCount))
 		})
 
-		It("sets cancel to nil", func() {
-			Expect(cancel).ToNot(BeNil())
+		It("stops the default registrar", func() {
+			r := Default()
+			Expect(r.Running()).To(BeTrue())
 			Disable()
-			Expect(cancel).To(BeNil())
+			Expect(r.Running()).To(BeFalse())
+			Expect(Default()).To(BeNil())
 		})
 
 		Context("when it is called multiple times", func() {
 			BeforeEach(func() {
 				Disable()
-				Eventually(fakeClient.DeregisterCallCount).Should(Equal(1))
+				Eventually(fakeClient.DeregisterContextCallCount).Should(Equal(1))
 			})
 
 			It("does not do anything", func() {
 				Disable()
-				Consistently(fakeClient.DeregisterCallCount).Should(Equal(1))
+				Consistently(fakeClient.DeregisterContextCallCount).Should(Equal(1))
 			})
 		})
 	})
 })
 
-var _ = Describe("clientProvider", func() {
-	Context("when a Eureka service exists", func() {
-		BeforeEach(func() {
-			os.Setenv("VCAP_SERVICES", vcapServices)
+var _ = Describe("heartbeat jitter", func() {
+	It("stays within the last tenth of the interval", func() {
+		for i := 0; i < 100; i++ {
+			d := Jitter(30 * time.Second)
+			Expect(d).To(BeNumerically("<=", 30*time.Second))
+			Expect(d).To(BeNumerically(">", 27*time.Second))
+		}
+	})
+
+	It("spreads the heartbeats", func() {
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 100; i++ {
+			seen[Jitter(30*time.Second)] = true
+		}
+		Expect(len(seen)).To(BeNumerically(">", 1))
+	})
+
+	It("keeps tiny intervals", func() {
+		Expect(Jitter(5 * time.Nanosecond)).To(Equal(5 * time.Nanosecond))
+	})
+})
+
+var _ = Describe(".IsNotRegistered", func() {
+	It("is true for errors reporting the instance as not found", func() {
+		Expect(IsNotRegistered(notFoundError(true))).To(BeTrue())
+	})
+
+	It("is false for other errors", func() {
+		Expect(IsNotRegistered(notFoundError(false))).To(BeFalse())
+		Expect(IsNotRegistered(errors.New("some-error"))).To(BeFalse())
+		Expect(IsNotRegistered(nil)).To(BeFalse())
+	})
+})
+
+var _ = Describe(".IsUnknownApp", func() {
+	It("is true for errors reporting the app as not found", func() {
+		Expect(IsUnknownApp(notFoundError(true))).To(BeTrue())
+	})
+
+	It("is false for other errors", func() {
+		Expect(IsUnknownApp(notFoundError(false))).To(BeFalse())
+		Expect(IsUnknownApp(errors.New("some-error"))).To(BeFalse())
+		Expect(IsUnknownApp(nil)).To(BeFalse())
+	})
+})
+
+type notFoundError bool
+
+func (e notFoundError) Error() string {
+	return "not found"
+}
+
+func (e notFoundError) NotFound() bool {
+	return bool(e)
+}
+
+var _ = Describe("Instance", func() {
+	Describe(".BaseURL", func() {
+		It("uses http and omits the standard port", func() {
+			i := Instance{HostName: "host", Port: 80, PortEnabled: true}
+			Expect(i.BaseURL()).To(Equal("http://host"))
 		})
 
-		AfterEach(func() {
-			os.Unsetenv("VCAP_SERVICES")
+		It("includes non-standard ports", func() {
+			i := Instance{HostName: "host", Port: 8080, PortEnabled: true}
+			Expect(i.BaseURL()).To(Equal("http://host:8080"))
 		})
 
-		It("returns a client", func() {
-			c, _ := clientProvider()
-			Expect(c).ToNot(BeNil())
+		It("prefers the secure port when enabled", func() {
+			i := Instance{HostName: "host", Port: 80, PortEnabled: true, SecurePort: 8443, SecurePortEnabled: true}
+			Expect(i.BaseURL()).To(Equal("https://host:8443"))
 		})
+	})
+})
 
-		It("returns no error", func() {
-			_, err := clientProvider()
-			Expect(err).ToNot(HaveOccurred())
+var _ = Describe("Application", func() {
+	var app = Application{
+		Name: "foo",
+		Instances: []Instance{
+			{ID: "1", Metadata: map[string]string{"version": "1", "zone": "a"}},
+			{ID: "2", Metadata: map[string]string{"version": "2", "zone": "a"}},
+			{ID: "3"},
+		},
+	}
+
+	Describe(".Filter", func() {
+		It("keeps instances matching all metadata values", func() {
+			filtered := app.Filter(MetadataFilter(map[string]string{"version": "2", "zone": "a"}))
+			Expect(filtered.Name).To(Equal("foo"))
+			Expect(filtered.Instances).To(HaveLen(1))
+			Expect(filtered.Instances[0].ID).To(Equal("2"))
+		})
+
+		It("combines filters", func() {
+			filtered := app.Filter(
+				MetadataFilter(map[string]string{"zone": "a"}),
+				func(i Instance) bool { return i.ID != "1" },
+			)
+			Expect(filtered.Instances).To(HaveLen(1))
+			Expect(filtered.Instances[0].ID).To(Equal("2"))
+		})
+
+		It("returns no instances if nothing matches", func() {
+			filtered := app.Filter(MetadataFilter(map[string]string{"version": "3"}))
+			Expect(filtered.Instances).To(BeEmpty())
 		})
 	})
 
-	Context("when a Eureka service does not exist", func() {
-		It("returns no error", func() {
-			_, err := clientProvider()
-			Expect(err).To(HaveOccurred())
+	Describe(".PreferZone", func() {
+		var zoned = Application{
+			Name: "foo",
+			Instances: []Instance{
+				{ID: "1", Zone: "a", Status: StatusUp},
+				{ID: "2", Zone: "a", Status: StatusDown},
+				{ID: "3", Zone: "b", Status: StatusUp},
+				{ID: "4", Zone: "c", Status: StatusDown},
+			},
+		}
+
+		It("keeps the instances in the given zone", func() {
+			preferred := zoned.PreferZone("a")
+			Expect(preferred.Name).To(Equal("foo"))
+			Expect(preferred.Instances).To(Equal(zoned.Instances[:2]))
+		})
+
+		It("falls back to all instances if none in the zone is UP", func() {
+			Expect(zoned.PreferZone("c")).To(Equal(zoned))
+		})
+
+		It("falls back to all instances if the zone is unknown", func() {
+			Expect(zoned.PreferZone("d")).To(Equal(zoned))
+		})
+	})
+})
+
+var _ = Describe("deprecated host name lookups", func() {
+	var fakeClient *fake.Client
+
+	BeforeEach(func() {
+		fakeClient = new(fake.Client)
+		app := Application{
+			Name:      "foo",
+			Instances: []Instance{{HostName: "host-1"}, {HostName: "host-2"}},
+		}
+		fakeClient.AppReturns(app, nil)
+		fakeClient.AppsReturns(map[string]Application{"foo": app}, nil)
+	})
+
+	It("returns the host names of an app", func() {
+		hostNames, err := AppHostNames(fakeClient, "foo")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hostNames).To(Equal([]string{"host-1", "host-2"}))
+		Expect(fakeClient.AppArgsForCall(0)).To(Equal("foo"))
+	})
+
+	It("returns the host names of all apps", func() {
+		apps, err := AppsHostNames(fakeClient)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(apps).To(Equal(map[string][]string{"foo": {"host-1", "host-2"}}))
+	})
+
+	Context("when the lookup fails", func() {
+		BeforeEach(func() {
+			fakeClient.AppReturns(Application{}, errors.New("some-error"))
+		})
+
+		It("returns the error", func() {
+			_, err := AppHostNames(fakeClient, "foo")
+			Expect(err).To(MatchError("some-error"))
 		})
 	})
 })
@@ -318,22 +426,3 @@ var vcapApplication = `
 	"state_timestamp": 987654321
  }
 `
-
-var vcapServices = `
-{
-  "user-provided": [
-   {
-    "credentials": {
-     "uris": [
-      "eureka-one-a.cfapps.io",
-      "eureka-one-b.cfapps.io",
-      "eureka-one-c.cfapps.io"
-     ]
-    },
-    "label": "user-provided",
-    "name": "eureka",
-    "syslog_drain_url": "",
-    "tags": []
-   }
-  ]
-}`
@@ -0,0 +1,366 @@
+package eureka
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/st3v/cfkit/discovery"
+)
+
+// WatchBuffer is the number of events buffered per watcher. Events for a
+// watcher that falls further behind are dropped.
+var WatchBuffer = 64
+
+type EventType string
+
+const (
+	InstanceAdded   EventType = "ADDED"
+	InstanceChanged EventType = "MODIFIED"
+	InstanceRemoved EventType = "DELETED"
+)
+
+type Event struct {
+	Type     EventType
+	Instance discovery.Instance
+}
+
+type registrySource interface {
+	fetchRegistry(ctx context.Context) (*registry, error)
+	fetchRegistryDelta(ctx context.Context) (*registry, error)
+}
+
+// Cache keeps a local copy of the Eureka registry. It fetches the full
+// registry on start and applies deltas every poll interval, falling back to a
+// full fetch whenever the result disagrees with the hashcode sent by Eureka.
+type Cache struct {
+	source   registrySource
+	interval time.Duration
+
+	mutex    sync.RWMutex
+	apps     map[string]map[string]discovery.Instance
+	watchers map[string][]chan Event
+	running  bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// DefaultCacheInterval is used when the client was given no poll interval.
+var DefaultCacheInterval = 30 * time.Second
+
+func newCache(source registrySource, interval time.Duration) *Cache {
+	if interval <= 0 {
+		interval = DefaultCacheInterval
+	}
+
+	return &Cache{
+		source:   source,
+		interval: interval,
+		apps:     map[string]map[string]discovery.Instance{},
+		watchers: map[string][]chan Event{},
+	}
+}
+
+// Start fetches the full registry and keeps refreshing it until Stop is
+// called.
+func (c *Cache) Start() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.running {
+		return nil
+	}
+
+	r, err := c.source.fetchRegistry(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error fetching registry from Eureka: %s", err)
+	}
+	c.replace(r)
+
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(context.Background())
+
+	c.running = true
+	c.done = make(chan struct{})
+	go c.poll(ctx, c.done)
+
+	return nil
+}
+
+// Stop ends the refreshing, abandoning a fetch in flight, and closes all
+// watch channels.
+func (c *Cache) Stop() {
+	c.mutex.Lock()
+	if !c.running {
+		c.mutex.Unlock()
+		return
+	}
+	c.running = false
+	c.cancel()
+	done := c.done
+	c.mutex.Unlock()
+
+	<-done
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for app, watchers := range c.watchers {
+		for _, w := range watchers {
+			close(w)
+		}
+		delete(c.watchers, app)
+	}
+}
+
+func (c *Cache) Running() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.running
+}
+
+func (c *Cache) App(name string) (discovery.Application, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	instances, ok := c.apps[strings.ToUpper(name)]
+	if !ok {
+		return discovery.Application{}, &Error{
+			Code:    http.StatusNotFound,
+			Err:     errors.New("not found"),
+			message: fmt.Sprintf("Error retrieving app '%s' from cache", name),
+		}
+	}
+
+	return cachedApplication(strings.ToUpper(name), instances), nil
+}
+
+func (c *Cache) Apps() map[string]discovery.Application {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	result := map[string]discovery.Application{}
+	for name, instances := range c.apps {
+		result[name] = cachedApplication(name, instances)
+	}
+	return result
+}
+
+func (c *Cache) VIP(vip string) []discovery.Instance {
+	return c.instances(func(inst discovery.Instance) bool {
+		return hasVIP(inst.VIPAddress, vip)
+	})
+}
+
+func (c *Cache) SecureVIP(vip string) []discovery.Instance {
+	return c.instances(func(inst discovery.Instance) bool {
+		return hasVIP(inst.SecureVIPAddress, vip)
+	})
+}
+
+func (c *Cache) instances(keep discovery.InstanceFilter) []discovery.Instance {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	names := make([]string, 0, len(c.apps))
+	for name := range c.apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []discovery.Instance{}
+	for _, name := range names {
+		app := cachedApplication(name, c.apps[name]).Filter(keep)
+		result = append(result, app.Instances...)
+	}
+	return result
+}
+
+// hasVIP matches Eureka's comma separated VIP lists.
+func hasVIP(vips, vip string) bool {
+	for _, v := range strings.Split(vips, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), vip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch returns a channel emitting changes to the instances of the given app.
+// The channel is closed when the cache is stopped.
+func (c *Cache) Watch(app string) (<-chan Event, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.running {
+		return nil, errors.New("Error watching app: registry cache not running")
+	}
+
+	name := strings.ToUpper(app)
+	events := make(chan Event, WatchBuffer)
+	c.watchers[name] = append(c.watchers[name], events)
+
+	return events, nil
+}
+
+func (c *Cache) poll(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.interval):
+			if err := c.refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+}
+
+func (c *Cache) refresh(ctx context.Context) error {
+	delta, err := c.source.fetchRegistryDelta(ctx)
+	if err != nil {
+		return fmt.Errorf("Error fetching registry delta from Eureka: %s", err)
+	}
+
+	c.mutex.Lock()
+	c.apply(delta)
+	consistent := c.hashcode() == delta.hashcode
+	c.mutex.Unlock()
+
+	if consistent {
+		return nil
+	}
+
+	r, err := c.source.fetchRegistry(ctx)
+	if err != nil {
+		return fmt.Errorf("Error fetching registry from Eureka: %s", err)
+	}
+
+	c.mutex.Lock()
+	c.replace(r)
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// apply merges a delta into the cache. Eureka keeps changes in the delta for
+// a while, so applying the same change twice must not emit another event.
+func (c *Cache) apply(delta *registry) {
+	for _, ri := range delta.instances {
+		if ri.action == string(InstanceRemoved) {
+			c.remove(ri.instance)
+			continue
+		}
+		c.put(ri.instance)
+	}
+}
+
+// replace swaps the cache content for a full registry and emits events for
+// everything that changed.
+func (c *Cache) replace(r *registry) {
+	fetched := map[string]map[string]bool{}
+	for _, ri := range r.instances {
+		c.put(ri.instance)
+
+		app := strings.ToUpper(ri.instance.App)
+		if fetched[app] == nil {
+			fetched[app] = map[string]bool{}
+		}
+		fetched[app][ri.instance.ID] = true
+	}
+
+	for _, instances := range c.apps {
+		for id, inst := range instances {
+			if !fetched[strings.ToUpper(inst.App)][id] {
+				c.remove(inst)
+			}
+		}
+	}
+}
+
+func (c *Cache) put(inst discovery.Instance) {
+	app := strings.ToUpper(inst.App)
+	if c.apps[app] == nil {
+		c.apps[app] = map[string]discovery.Instance{}
+	}
+
+	old, exists := c.apps[app][inst.ID]
+	c.apps[app][inst.ID] = inst
+
+	switch {
+	case !exists:
+		c.emit(app, Event{Type: InstanceAdded, Instance: inst})
+	case !reflect.DeepEqual(old, inst):
+		c.emit(app, Event{Type: InstanceChanged, Instance: inst})
+	}
+}
+
+func (c *Cache) remove(inst discovery.Instance) {
+	app := strings.ToUpper(inst.App)
+
+	old, exists := c.apps[app][inst.ID]
+	if !exists {
+		return
+	}
+
+	delete(c.apps[app], inst.ID)
+	if len(c.apps[app]) == 0 {
+		delete(c.apps, app)
+	}
+
+	c.emit(app, Event{Type: InstanceRemoved, Instance: old})
+}
+
+func (c *Cache) emit(app string, event Event) {
+	for _, w := range c.watchers[app] {
+		select {
+		case w <- event:
+		default:
+			log.Printf("Dropping %s event for app '%s', watcher is falling behind\n", event.Type, app)
+		}
+	}
+}
+
+// hashcode mimics Eureka's apps__hashcode, e.g. DOWN_1_UP_3_.
+func (c *Cache) hashcode() string {
+	counts := map[string]int{}
+	for _, instances := range c.apps {
+		for _, inst := range instances {
+			counts[string(inst.Status)]++
+		}
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	hashcode := ""
+	for _, status := range statuses {
+		hashcode += fmt.Sprintf("%s_%d_", status, counts[status])
+	}
+	return hashcode
+}
+
+func cachedApplication(name string, instances map[string]discovery.Instance) discovery.Application {
+	ids := make([]string, 0, len(instances))
+	for id := range instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	app := discovery.Application{Name: name, Instances: make([]discovery.Instance, len(ids))}
+	for i, id := range ids {
+		app.Instances[i] = instances[id]
+	}
+	return app
+}
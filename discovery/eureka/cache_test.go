@@ -0,0 +1,290 @@
+package eureka
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/st3v/cfkit/discovery"
+	"golang.org/x/net/context"
+)
+
+type stubSource struct {
+	mutex      sync.Mutex
+	full       *registry
+	fullErr    error
+	fullCalls  int
+	deltas     []*registry
+	deltaCalls int
+}
+
+func (s *stubSource) fetchRegistry(context.Context) (*registry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fullCalls++
+	return s.full, s.fullErr
+}
+
+// fetchRegistryDelta returns the queued deltas in order and repeats the
+// last one, like Eureka does for a while.
+func (s *stubSource) fetchRegistryDelta(context.Context) (*registry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deltaCalls++
+	delta := s.deltas[0]
+	if len(s.deltas) > 1 {
+		s.deltas = s.deltas[1:]
+	}
+	return delta, nil
+}
+
+func (s *stubSource) setFull(r *registry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.full = r
+}
+
+func (s *stubSource) fullCallCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.fullCalls
+}
+
+func (s *stubSource) deltaCallCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.deltaCalls
+}
+
+func cachedInstance(app, id string, status discovery.Status) discovery.Instance {
+	return discovery.Instance{ID: id, App: app, HostName: id + ".host", Status: status}
+}
+
+func registryOf(hashcode, action string, instances ...discovery.Instance) *registry {
+	r := &registry{hashcode: hashcode}
+	for _, inst := range instances {
+		r.instances = append(r.instances, registryInstance{action: action, instance: inst})
+	}
+	return r
+}
+
+var _ = Describe("Cache", func() {
+	var (
+		source *stubSource
+		cache  *Cache
+
+		a1 = cachedInstance("APP-A", "a1", discovery.StatusUp)
+		a2 = cachedInstance("APP-A", "a2", discovery.StatusUp)
+		b1 = cachedInstance("APP-B", "b1", discovery.StatusDown)
+
+		emptyDelta = registryOf("DOWN_1_UP_2_", "")
+	)
+
+	BeforeEach(func() {
+		source = &stubSource{
+			full:   registryOf("DOWN_1_UP_2_", "", a1, a2, b1),
+			deltas: []*registry{emptyDelta},
+		}
+		cache = newCache(source, 5*time.Millisecond)
+	})
+
+	AfterEach(func() {
+		cache.Stop()
+	})
+
+	Describe(".Start", func() {
+		It("fetches the full registry", func() {
+			Expect(cache.Start()).To(Succeed())
+			Expect(source.fullCallCount()).To(Equal(1))
+			Expect(cache.Running()).To(BeTrue())
+		})
+
+		It("serves lookups from memory", func() {
+			Expect(cache.Start()).To(Succeed())
+
+			app, err := cache.App("app-a")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(app.Name).To(Equal("APP-A"))
+			Expect(app.Instances).To(Equal([]discovery.Instance{a1, a2}))
+
+			apps := cache.Apps()
+			Expect(apps).To(HaveLen(2))
+			Expect(apps["APP-B"].Instances).To(Equal([]discovery.Instance{b1}))
+		})
+
+		It("fetches deltas on the poll interval", func() {
+			Expect(cache.Start()).To(Succeed())
+			Eventually(source.deltaCallCount).Should(BeNumerically(">=", 3))
+			Expect(source.fullCallCount()).To(Equal(1))
+		})
+
+		Context("when there is no poll interval", func() {
+			BeforeEach(func() {
+				cache = newCache(source, 0)
+			})
+
+			It("falls back to the default interval", func() {
+				Expect(cache.Start()).To(Succeed())
+				Consistently(source.deltaCallCount).Should(BeZero())
+			})
+		})
+
+		Context("when the full fetch fails", func() {
+			BeforeEach(func() {
+				source.fullErr = errors.New("some-error")
+			})
+
+			It("returns the error", func() {
+				err := cache.Start()
+				Expect(err).To(MatchError("Error fetching registry from Eureka: some-error"))
+				Expect(cache.Running()).To(BeFalse())
+			})
+		})
+	})
+
+	Describe(".App", func() {
+		It("returns an error for unknown apps", func() {
+			Expect(cache.Start()).To(Succeed())
+			_, err := cache.App("unknown")
+			Expect(err).To(MatchError("Error retrieving app 'unknown' from cache: not found"))
+			Expect(discovery.IsUnknownApp(err)).To(BeTrue())
+		})
+	})
+
+	Describe(".VIP", func() {
+		BeforeEach(func() {
+			withVIP := func(inst discovery.Instance, vip, svip string) discovery.Instance {
+				inst.VIPAddress, inst.SecureVIPAddress = vip, svip
+				return inst
+			}
+
+			source.full = registryOf("DOWN_1_UP_2_", "",
+				withVIP(a1, "shared,a", "secure-a"),
+				withVIP(a2, "a", ""),
+				withVIP(b1, "shared", "secure-b"),
+			)
+		})
+
+		It("returns the instances of all apps sharing the VIP", func() {
+			Expect(cache.Start()).To(Succeed())
+
+			instances := cache.VIP("shared")
+			Expect(instances).To(HaveLen(2))
+			Expect(instances[0].ID).To(Equal("a1"))
+			Expect(instances[1].ID).To(Equal("b1"))
+		})
+
+		It("looks up secure VIPs", func() {
+			Expect(cache.Start()).To(Succeed())
+
+			instances := cache.SecureVIP("secure-b")
+			Expect(instances).To(HaveLen(1))
+			Expect(instances[0].ID).To(Equal("b1"))
+		})
+
+		It("returns no instances for unknown VIPs", func() {
+			Expect(cache.Start()).To(Succeed())
+			Expect(cache.VIP("unknown")).To(BeEmpty())
+		})
+	})
+
+	Describe(".Watch", func() {
+		var events <-chan Event
+
+		JustBeforeEach(func() {
+			Expect(cache.Start()).To(Succeed())
+
+			var err error
+			events, err = cache.Watch("app-a")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when instances are added, changed and removed", func() {
+			var (
+				a3        = cachedInstance("APP-A", "a3", discovery.StatusUp)
+				a1Down    = cachedInstance("APP-A", "a1", discovery.StatusDown)
+				delta     = &registry{hashcode: "DOWN_2_UP_1_"}
+				remaining = []discovery.Instance{a1Down, a3}
+			)
+
+			BeforeEach(func() {
+				delta.instances = []registryInstance{
+					{action: "ADDED", instance: a3},
+					{action: "MODIFIED", instance: a1Down},
+					{action: "DELETED", instance: a2},
+				}
+				source.deltas = []*registry{delta}
+			})
+
+			It("emits the corresponding events", func() {
+				Eventually(events).Should(Receive(Equal(Event{Type: InstanceAdded, Instance: a3})))
+				Eventually(events).Should(Receive(Equal(Event{Type: InstanceChanged, Instance: a1Down})))
+				Eventually(events).Should(Receive(Equal(Event{Type: InstanceRemoved, Instance: a2})))
+			})
+
+			It("emits every change only once", func() {
+				Eventually(source.deltaCallCount).Should(BeNumerically(">=", 3))
+				Expect(events).To(HaveLen(3))
+			})
+
+			It("updates the lookups", func() {
+				Eventually(func() []discovery.Instance {
+					app, _ := cache.App("APP-A")
+					return app.Instances
+				}).Should(Equal(remaining))
+				Expect(source.fullCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the hashcode does not match after applying the delta", func() {
+			var a4 = cachedInstance("APP-A", "a4", discovery.StatusUp)
+
+			BeforeEach(func() {
+				source.deltas = []*registry{registryOf("DOWN_1_UP_3_", "")}
+			})
+
+			JustBeforeEach(func() {
+				source.setFull(registryOf("DOWN_1_UP_3_", "", a1, a2, a4, b1))
+			})
+
+			It("falls back to a full fetch", func() {
+				Eventually(source.fullCallCount).Should(Equal(2))
+				Eventually(events).Should(Receive(Equal(Event{Type: InstanceAdded, Instance: a4})))
+			})
+
+			It("removes instances missing from the full registry", func() {
+				source.setFull(registryOf("DOWN_1_UP_3_", "", a1, a4, b1))
+				Eventually(events).Should(Receive(Equal(Event{Type: InstanceRemoved, Instance: a2})))
+			})
+		})
+
+		Context("when another app changes", func() {
+			var b2 = cachedInstance("APP-B", "b2", discovery.StatusUp)
+
+			BeforeEach(func() {
+				source.deltas = []*registry{registryOf("DOWN_1_UP_3_", "ADDED", b2)}
+			})
+
+			It("does not emit events for the watched app", func() {
+				Eventually(func() int {
+					return len(cache.Apps()["APP-B"].Instances)
+				}).Should(Equal(2))
+				Consistently(events).ShouldNot(Receive())
+			})
+		})
+
+		It("closes the channel when the cache stops", func() {
+			cache.Stop()
+			Eventually(events).Should(BeClosed())
+		})
+	})
+
+	Context("when the cache is not running", func() {
+		It("cannot be watched", func() {
+			_, err := cache.Watch("app-a")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
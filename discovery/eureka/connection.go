@@ -0,0 +1,445 @@
+package eureka
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hudl/fargo"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/st3v/cfkit/discovery"
+)
+
+// StatusError is returned for requests Eureka answered with an unexpected
+// HTTP status code.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Eureka returned status %d", e.Code)
+}
+
+// connection talks to Eureka using fargo's types, but not its
+// EurekaConnection, which picks a random peer for every request and hides the
+// status code of failed requests, leaving no way to retry on another peer.
+// Unlike fargo it exposes status codes, supports delta fetches, fails over
+// between peers and authenticates with the credentials given in the peer URIs.
+type connection struct {
+	peers  *peers
+	client *http.Client
+}
+
+func newConnection(c *Client) Connection {
+	return &connection{
+		peers:  c.peers,
+		client: &http.Client{Timeout: c.timeout, Transport: transport(c)},
+	}
+}
+
+// transport returns nil, i.e. http.DefaultTransport, unless the client has
+// been configured otherwise.
+func transport(c *Client) http.RoundTripper {
+	if c.transport != nil {
+		return c.transport
+	}
+
+	if c.tlsConfig == nil && len(c.certificates) == 0 {
+		return nil
+	}
+
+	config := &tls.Config{}
+	if c.tlsConfig != nil {
+		config = copyTLSConfig(c.tlsConfig)
+	}
+	config.Certificates = append(config.Certificates, c.certificates...)
+
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     config,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// copyTLSConfig copies the client side settings, leaving the caller's config
+// untouched when adding certificates.
+func copyTLSConfig(config *tls.Config) *tls.Config {
+	return &tls.Config{
+		Rand:               config.Rand,
+		Time:               config.Time,
+		Certificates:       append([]tls.Certificate{}, config.Certificates...),
+		RootCAs:            config.RootCAs,
+		NextProtos:         config.NextProtos,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		CipherSuites:       config.CipherSuites,
+		ClientSessionCache: config.ClientSessionCache,
+		MinVersion:         config.MinVersion,
+		MaxVersion:         config.MaxVersion,
+		CurvePreferences:   config.CurvePreferences,
+	}
+}
+
+func (c *connection) RegisterInstance(ins *fargo.Instance) error {
+	return c.RegisterInstanceContext(context.Background(), ins)
+}
+
+func (c *connection) RegisterInstanceContext(ctx context.Context, ins *fargo.Instance) error {
+	body, err := json.Marshal(&fargo.RegisterInstanceJson{Instance: ins})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, "POST", body, "apps", ins.App)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+func (c *connection) DeregisterInstance(ins *fargo.Instance) error {
+	return c.DeregisterInstanceContext(context.Background(), ins)
+}
+
+func (c *connection) DeregisterInstanceContext(ctx context.Context, ins *fargo.Instance) error {
+	resp, err := c.do(ctx, "DELETE", nil, "apps", ins.App, ins.Id())
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+func (c *connection) HeartBeatInstance(ins *fargo.Instance) error {
+	return c.HeartBeatInstanceContext(context.Background(), ins)
+}
+
+func (c *connection) HeartBeatInstanceContext(ctx context.Context, ins *fargo.Instance) error {
+	resp, err := c.do(ctx, "PUT", nil, "apps", ins.App, ins.Id())
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+func (c *connection) AddMetadataString(ins *fargo.Instance, key, value string) error {
+	return c.AddMetadataStringContext(context.Background(), ins, key, value)
+}
+
+func (c *connection) AddMetadataStringContext(ctx context.Context, ins *fargo.Instance, key, value string) error {
+	query := url.Values{key: []string{value}}.Encode()
+
+	resp, err := c.do(ctx, "PUT", nil, "apps", ins.App, ins.Id(), "metadata?"+query)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+
+	ins.SetMetadataString(key, value)
+	return nil
+}
+
+func (c *connection) UpdateInstanceStatus(ins *fargo.Instance, status fargo.StatusType) error {
+	return c.UpdateInstanceStatusContext(context.Background(), ins, status)
+}
+
+func (c *connection) UpdateInstanceStatusContext(ctx context.Context, ins *fargo.Instance, status fargo.StatusType) error {
+	query := url.Values{"value": []string{string(status)}}.Encode()
+
+	resp, err := c.do(ctx, "PUT", nil, "apps", ins.App, ins.Id(), "status?"+query)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+func (c *connection) GetApp(name string) (*fargo.Application, error) {
+	return c.GetAppContext(context.Background(), name)
+}
+
+func (c *connection) GetAppContext(ctx context.Context, name string) (*fargo.Application, error) {
+	resp, err := c.do(ctx, "GET", nil, "apps", name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var r fargo.GetAppResponseJson
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	return &r.Application, nil
+}
+
+func (c *connection) GetApps() (map[string]*fargo.Application, error) {
+	return c.GetAppsContext(context.Background())
+}
+
+func (c *connection) GetAppsContext(ctx context.Context) (map[string]*fargo.Application, error) {
+	return c.getApps(ctx, "apps")
+}
+
+func (c *connection) getApps(ctx context.Context, path ...string) (map[string]*fargo.Application, error) {
+	resp, err := c.do(ctx, "GET", nil, path...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// fargo.GetAppsResponse expects versions__delta to be a number, but
+	// Eureka sends a string
+	var r registryJSON
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	rawApps, err := jsonList(r.Applications.Application)
+	if err != nil {
+		return nil, err
+	}
+
+	apps := map[string]*fargo.Application{}
+	for _, rawApp := range rawApps {
+		var app fargo.Application
+		if err := json.Unmarshal(rawApp, &app); err != nil {
+			return nil, err
+		}
+		apps[app.Name] = &app
+	}
+
+	return apps, nil
+}
+
+func (c *connection) GetVIP(vip string) ([]*fargo.Instance, error) {
+	return c.GetVIPContext(context.Background(), vip)
+}
+
+func (c *connection) GetVIPContext(ctx context.Context, vip string) ([]*fargo.Instance, error) {
+	return c.getInstances(ctx, "vips", vip)
+}
+
+func (c *connection) GetSVIP(vip string) ([]*fargo.Instance, error) {
+	return c.GetSVIPContext(context.Background(), vip)
+}
+
+func (c *connection) GetSVIPContext(ctx context.Context, vip string) ([]*fargo.Instance, error) {
+	return c.getInstances(ctx, "svips", vip)
+}
+
+func (c *connection) getInstances(ctx context.Context, path ...string) ([]*fargo.Instance, error) {
+	apps, err := c.getApps(ctx, path...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	instances := []*fargo.Instance{}
+	for _, name := range names {
+		instances = append(instances, apps[name].Instances...)
+	}
+
+	return instances, nil
+}
+
+// registry is the content of Eureka's /apps or /apps/delta resources.
+type registry struct {
+	hashcode  string
+	instances []registryInstance
+}
+
+// registryInstance carries the action reported by /apps/delta, i.e. ADDED,
+// MODIFIED or DELETED. It is empty for full fetches.
+type registryInstance struct {
+	action   string
+	instance discovery.Instance
+}
+
+func (c *connection) fetchRegistry(ctx context.Context) (*registry, error) {
+	return c.getRegistry(ctx, "apps")
+}
+
+func (c *connection) fetchRegistryDelta(ctx context.Context) (*registry, error) {
+	return c.getRegistry(ctx, "apps", "delta")
+}
+
+type registryJSON struct {
+	Applications struct {
+		Hashcode    string          `json:"apps__hashcode"`
+		Application json.RawMessage `json:"application"`
+	} `json:"applications"`
+}
+
+type applicationJSON struct {
+	Name     string          `json:"name"`
+	Instance json.RawMessage `json:"instance"`
+}
+
+type actionJSON struct {
+	ActionType string `json:"actionType"`
+}
+
+func (c *connection) getRegistry(ctx context.Context, path ...string) (*registry, error) {
+	resp, err := c.do(ctx, "GET", nil, path...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var r registryJSON
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	apps, err := jsonList(r.Applications.Application)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &registry{hashcode: r.Applications.Hashcode}
+	for _, rawApp := range apps {
+		var app applicationJSON
+		if err := json.Unmarshal(rawApp, &app); err != nil {
+			return nil, err
+		}
+
+		instances, err := jsonList(app.Instance)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rawInst := range instances {
+			inst, err := registryInstanceFromJSON(rawInst)
+			if err != nil {
+				return nil, err
+			}
+			result.instances = append(result.instances, inst)
+		}
+	}
+
+	return result, nil
+}
+
+func registryInstanceFromJSON(raw json.RawMessage) (registryInstance, error) {
+	var inst fargo.Instance
+	if err := json.Unmarshal(raw, &inst); err != nil {
+		return registryInstance{}, err
+	}
+
+	// fargo.Instance decodes itself and drops the action type
+	var action actionJSON
+	if err := json.Unmarshal(raw, &action); err != nil {
+		return registryInstance{}, err
+	}
+
+	return registryInstance{action: action.ActionType, instance: instance(&inst)}, nil
+}
+
+// jsonList handles Eureka encoding single element lists as plain objects.
+func jsonList(raw json.RawMessage) ([]json.RawMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	if raw[0] != '[' {
+		return []json.RawMessage{raw}, nil
+	}
+
+	var list []json.RawMessage
+	err := json.Unmarshal(raw, &list)
+	return list, err
+}
+
+// do sends a request to the given path, trying one peer after the other
+// until one of them answers or ctx is done. Peers failing in transport or
+// with a server error are quarantined. The body of a successful response must
+// be closed by the caller.
+func (c *connection) do(ctx context.Context, method string, body []byte, path ...string) (*http.Response, error) {
+	var lastErr error
+	for _, peer := range c.peers.ordered() {
+		resp, err := c.send(ctx, peer.uri, method, body, path)
+		if ctx.Err() != nil {
+			// the caller gave up, which says nothing about the peer
+			if err == nil {
+				discard(resp)
+			}
+			return nil, ctx.Err()
+		}
+
+		if err != nil {
+			c.peers.failed(peer, err)
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			discard(resp)
+			lastErr = &StatusError{Code: resp.StatusCode}
+			c.peers.failed(peer, lastErr)
+			continue
+		}
+
+		c.peers.succeeded(peer)
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			discard(resp)
+			return nil, &StatusError{Code: resp.StatusCode}
+		}
+
+		return resp, nil
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("No Eureka peers configured")
+	}
+
+	return nil, lastErr
+}
+
+func (c *connection) send(ctx context.Context, uri, method string, body []byte, path []string) (*http.Response, error) {
+	target := strings.Join(append([]string{strings.TrimRight(uri, "/")}, path...), "/")
+
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the credentials out of the URL and thereby out of error messages
+	if user := req.URL.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+		req.URL.User = nil
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return ctxhttp.Do(ctx, c.client, req)
+}
+
+func discard(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
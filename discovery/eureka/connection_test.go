@@ -0,0 +1,617 @@
+package eureka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/hudl/fargo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/st3v/cfkit/discovery"
+	"golang.org/x/net/context"
+)
+
+type recordedRequest struct {
+	*http.Request
+	body string
+}
+
+type recordingTransport struct {
+	count int
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+var _ = Describe("connection", func() {
+	var (
+		server   *httptest.Server
+		conn     Connection
+		status   int
+		body     string
+		requests chan recordedRequest
+
+		instance = &fargo.Instance{App: "APP", HostName: "app-host"}
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		body = ""
+		requests = make(chan recordedRequest, 10)
+
+		s, b, r := &status, &body, requests
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			reqBody, _ := ioutil.ReadAll(req.Body)
+			r <- recordedRequest{req, string(reqBody)}
+			w.WriteHeader(*s)
+			w.Write([]byte(*b))
+		}))
+
+		conn = newConnection(NewClient([]string{server.URL + "/eureka"}, 80, time.Second, time.Second))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe(".RegisterInstance", func() {
+		BeforeEach(func() {
+			status = http.StatusNoContent
+		})
+
+		It("posts the instance as JSON", func() {
+			Expect(conn.RegisterInstance(instance)).To(Succeed())
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.Method).To(Equal("POST"))
+			Expect(req.URL.Path).To(Equal("/eureka/apps/APP"))
+			Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(req.body).To(ContainSubstring(`"hostName":"app-host"`))
+		})
+	})
+
+	Describe(".DeregisterInstance", func() {
+		It("deletes the instance", func() {
+			Expect(conn.DeregisterInstance(instance)).To(Succeed())
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.Method).To(Equal("DELETE"))
+			Expect(req.URL.Path).To(Equal("/eureka/apps/APP/app-host"))
+		})
+	})
+
+	Describe(".UpdateInstanceStatus", func() {
+		It("puts the status", func() {
+			Expect(conn.UpdateInstanceStatus(instance, fargo.OUTOFSERVICE)).To(Succeed())
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.Method).To(Equal("PUT"))
+			Expect(req.URL.Path).To(Equal("/eureka/apps/APP/app-host/status"))
+			Expect(req.URL.Query().Get("value")).To(Equal("OUT_OF_SERVICE"))
+		})
+	})
+
+	Describe(".AddMetadataString", func() {
+		It("puts the metadata", func() {
+			ins := &fargo.Instance{App: "APP", HostName: "app-host"}
+			Expect(conn.AddMetadataString(ins, "some key", "some value")).To(Succeed())
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.Method).To(Equal("PUT"))
+			Expect(req.URL.Path).To(Equal("/eureka/apps/APP/app-host/metadata"))
+			Expect(req.URL.Query().Get("some key")).To(Equal("some value"))
+		})
+	})
+
+	Describe(".GetApp", func() {
+		BeforeEach(func() {
+			body = `{"application": {"name": "APP-B", "instance": {"hostName": "b.host", "app": "APP-B"}}}`
+		})
+
+		It("returns the app", func() {
+			app, err := conn.GetApp("app-b")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(app.Name).To(Equal("APP-B"))
+			Expect(app.Instances).To(HaveLen(1))
+			Expect(app.Instances[0].HostName).To(Equal("b.host"))
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.URL.Path).To(Equal("/eureka/apps/app-b"))
+		})
+
+		Context("when the app does not exist", func() {
+			BeforeEach(func() {
+				status = http.StatusNotFound
+			})
+
+			It("returns a status error", func() {
+				_, err := conn.GetApp("app-b")
+				Expect(err).To(Equal(&StatusError{Code: http.StatusNotFound}))
+			})
+		})
+	})
+
+	Describe(".GetApps", func() {
+		BeforeEach(func() {
+			body = registryResponse
+		})
+
+		It("returns the apps by name", func() {
+			apps, err := conn.GetApps()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(apps).To(HaveLen(2))
+			Expect(apps["APP-A"].Instances).To(HaveLen(2))
+			Expect(apps["APP-B"].Instances).To(HaveLen(1))
+		})
+	})
+
+	Describe(".GetVIP", func() {
+		BeforeEach(func() {
+			body = registryResponse
+		})
+
+		It("gets the instances registered under the VIP", func() {
+			instances, err := conn.GetVIP("my-vip")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(HaveLen(3))
+			Expect(instances[0].App).To(Equal("APP-A"))
+			Expect(instances[2].App).To(Equal("APP-B"))
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.URL.Path).To(Equal("/eureka/vips/my-vip"))
+		})
+	})
+
+	Describe(".GetSVIP", func() {
+		BeforeEach(func() {
+			body = registryResponse
+		})
+
+		It("gets the instances registered under the secure VIP", func() {
+			instances, err := conn.GetSVIP("my-svip")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(HaveLen(3))
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.URL.Path).To(Equal("/eureka/svips/my-svip"))
+		})
+	})
+
+	Describe("failover", func() {
+		var (
+			failing *httptest.Server
+			c       *Client
+		)
+
+		BeforeEach(func() {
+			failing = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+
+			c = NewClient([]string{failing.URL + "/eureka", server.URL + "/eureka"}, 80, time.Second, time.Second)
+			conn = c.conn
+		})
+
+		AfterEach(func() {
+			failing.Close()
+		})
+
+		It("retries the request on the next peer", func() {
+			Expect(conn.HeartBeatInstance(instance)).To(Succeed())
+			Eventually(requests).Should(Receive())
+		})
+
+		It("quarantines the failing peer", func() {
+			conn.HeartBeatInstance(instance)
+
+			peers := c.Peers()
+			Expect(peers[0].Healthy).To(BeFalse())
+			Expect(peers[0].Failures).To(Equal(1))
+			Expect(peers[0].LastError).To(Equal(&StatusError{Code: http.StatusServiceUnavailable}))
+			Expect(peers[1].Healthy).To(BeTrue())
+		})
+
+		It("skips the quarantined peer", func() {
+			conn.HeartBeatInstance(instance)
+			conn.HeartBeatInstance(instance)
+			Expect(c.Peers()[0].Failures).To(Equal(1))
+		})
+
+		Context("when the request fails on the other peer too", func() {
+			BeforeEach(func() {
+				status = http.StatusBadGateway
+			})
+
+			It("returns the last error", func() {
+				err := conn.HeartBeatInstance(instance)
+				Expect(err).To(Equal(&StatusError{Code: http.StatusBadGateway}))
+			})
+		})
+
+		Context("when a peer answers with a client error", func() {
+			BeforeEach(func() {
+				status = http.StatusNotFound
+				failing.Close()
+				c = NewClient([]string{server.URL + "/eureka", failing.URL + "/eureka"}, 80, time.Second, time.Second)
+				conn = c.conn
+			})
+
+			It("does not fail over", func() {
+				err := conn.HeartBeatInstance(instance)
+				Expect(err).To(Equal(&StatusError{Code: http.StatusNotFound}))
+				Expect(c.Peers()[1].Failures).To(BeZero())
+			})
+		})
+	})
+
+	Describe("cancellation", func() {
+		var (
+			slow    *httptest.Server
+			release chan struct{}
+			c       *Client
+		)
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			r := release
+			slow = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				<-r
+			}))
+
+			c = NewClient([]string{slow.URL + "/eureka", server.URL + "/eureka"}, 80, time.Minute, time.Second)
+			conn = c.conn
+		})
+
+		AfterEach(func() {
+			close(release)
+			slow.Close()
+		})
+
+		It("abandons the request once the context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := conn.HeartBeatInstanceContext(ctx, instance)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+
+		It("does not try the other peers", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			conn.HeartBeatInstanceContext(ctx, instance)
+			Consistently(requests).ShouldNot(Receive())
+		})
+
+		It("does not quarantine the peer", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			conn.HeartBeatInstanceContext(ctx, instance)
+			Expect(c.Peers()[0].Healthy).To(BeTrue())
+			Expect(c.Peers()[0].Failures).To(BeZero())
+		})
+	})
+
+	Describe("authentication", func() {
+		var c *Client
+
+		BeforeEach(func() {
+			uri := strings.Replace(server.URL, "http://", "http://some-user:some-password@", 1)
+			c = NewClient([]string{uri + "/eureka"}, 80, time.Second, time.Second)
+			conn = c.conn
+		})
+
+		It("sends the credentials of the peer URI as basic auth", func() {
+			Expect(conn.HeartBeatInstance(instance)).To(Succeed())
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			user, password, ok := req.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(user).To(Equal("some-user"))
+			Expect(password).To(Equal("some-password"))
+		})
+
+		It("does not report the password", func() {
+			Expect(c.Peers()[0].URI).To(Equal(strings.Replace(server.URL, "http://", "http://some-user:xxxxx@", 1) + "/eureka"))
+		})
+
+		Context("when the peer cannot be reached", func() {
+			BeforeEach(func() {
+				server.Close()
+			})
+
+			It("does not leak the password in the error", func() {
+				err := conn.HeartBeatInstance(instance)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).ToNot(ContainSubstring("some-password"))
+			})
+		})
+	})
+
+	Describe("transport", func() {
+		It("uses the default transport", func() {
+			c := NewClient([]string{server.URL}, 80, time.Second, time.Second)
+			Expect(c.conn.(*connection).client.Transport).To(BeNil())
+		})
+
+		Context("when a transport is given", func() {
+			var transport *recordingTransport
+
+			BeforeEach(func() {
+				transport = &recordingTransport{}
+				conn = NewClient([]string{server.URL + "/eureka"}, 80, time.Second, time.Second,
+					WithTransport(transport),
+				).conn
+			})
+
+			It("sends the requests through it", func() {
+				Expect(conn.HeartBeatInstance(instance)).To(Succeed())
+				Expect(transport.count).To(Equal(1))
+			})
+		})
+
+		Context("when a client certificate is given", func() {
+			It("adds it to the TLS config", func() {
+				cert := tls.Certificate{Certificate: [][]byte{[]byte("some-cert")}}
+				c := NewClient([]string{server.URL}, 80, time.Second, time.Second,
+					WithTLSConfig(&tls.Config{ServerName: "eureka"}),
+					WithClientCertificate(cert),
+				)
+
+				config := c.conn.(*connection).client.Transport.(*http.Transport).TLSClientConfig
+				Expect(config.ServerName).To(Equal("eureka"))
+				Expect(config.Certificates).To(Equal([]tls.Certificate{cert}))
+			})
+
+			It("leaves the given TLS config untouched", func() {
+				shared := &tls.Config{ServerName: "eureka"}
+				for i := 0; i < 2; i++ {
+					NewClient([]string{server.URL}, 80, time.Second, time.Second,
+						WithTLSConfig(shared),
+						WithClientCertificate(tls.Certificate{Certificate: [][]byte{[]byte("some-cert")}}),
+					)
+				}
+
+				Expect(shared.Certificates).To(BeEmpty())
+			})
+		})
+
+		Context("when Eureka uses a private CA", func() {
+			var tlsServer *httptest.Server
+
+			BeforeEach(func() {
+				tlsServer = httptest.NewTLSServer(server.Config.Handler)
+			})
+
+			AfterEach(func() {
+				tlsServer.Close()
+			})
+
+			It("fails without the CA", func() {
+				conn = NewClient([]string{tlsServer.URL + "/eureka"}, 80, time.Second, time.Second).conn
+				Expect(conn.HeartBeatInstance(instance)).ToNot(Succeed())
+			})
+
+			It("succeeds with the CA", func() {
+				ca, err := x509.ParseCertificate(tlsServer.TLS.Certificates[0].Certificate[0])
+				Expect(err).ToNot(HaveOccurred())
+
+				pool := x509.NewCertPool()
+				pool.AddCert(ca)
+
+				conn = NewClient([]string{tlsServer.URL + "/eureka"}, 80, time.Second, time.Second,
+					WithTLSConfig(&tls.Config{RootCAs: pool}),
+				).conn
+				Expect(conn.HeartBeatInstance(instance)).To(Succeed())
+			})
+		})
+	})
+
+	Context("when no peers are configured", func() {
+		BeforeEach(func() {
+			conn = newConnection(NewClient([]string{}, 80, time.Second, time.Second))
+		})
+
+		It("returns an error", func() {
+			Expect(conn.HeartBeatInstance(instance)).To(MatchError(errors.New("No Eureka peers configured")))
+		})
+	})
+
+	Describe(".HeartBeatInstance", func() {
+		It("sends a PUT for the instance", func() {
+			Expect(conn.HeartBeatInstance(instance)).To(Succeed())
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.Method).To(Equal("PUT"))
+			Expect(req.URL.Path).To(Equal("/eureka/apps/APP/app-host"))
+		})
+
+		Context("when Eureka does not know the instance", func() {
+			BeforeEach(func() {
+				status = http.StatusNotFound
+			})
+
+			It("returns a status error", func() {
+				err := conn.HeartBeatInstance(instance)
+				Expect(err).To(Equal(&StatusError{Code: http.StatusNotFound}))
+			})
+		})
+
+		Context("when Eureka fails", func() {
+			BeforeEach(func() {
+				status = http.StatusInternalServerError
+			})
+
+			It("returns a status error", func() {
+				err := conn.HeartBeatInstance(instance)
+				Expect(err).To(Equal(&StatusError{Code: http.StatusInternalServerError}))
+			})
+		})
+
+		Context("when Eureka cannot be reached", func() {
+			BeforeEach(func() {
+				server.Close()
+			})
+
+			It("returns the transport error", func() {
+				err := conn.HeartBeatInstance(instance)
+				Expect(err).To(HaveOccurred())
+				_, ok := err.(*StatusError)
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+
+	Describe(".fetchRegistry", func() {
+		BeforeEach(func() {
+			body = registryResponse
+		})
+
+		It("gets the apps", func() {
+			_, err := conn.(*connection).fetchRegistry(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.Method).To(Equal("GET"))
+			Expect(req.URL.Path).To(Equal("/eureka/apps"))
+			Expect(req.Header.Get("Accept")).To(Equal("application/json"))
+		})
+
+		It("returns the instances and the hashcode", func() {
+			r, err := conn.(*connection).fetchRegistry(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.hashcode).To(Equal("DOWN_1_UP_2_"))
+			Expect(r.instances).To(HaveLen(3))
+
+			Expect(r.instances[0].instance.ID).To(Equal("a1"))
+			Expect(r.instances[0].instance.App).To(Equal("APP-A"))
+			Expect(r.instances[0].instance.Port).To(Equal(8080))
+			Expect(r.instances[1].instance.ID).To(Equal("a2"))
+			Expect(r.instances[2].instance.ID).To(Equal("b1"))
+			Expect(r.instances[2].instance.Status).To(Equal(discovery.StatusDown))
+		})
+
+		Context("when Eureka fails", func() {
+			BeforeEach(func() {
+				status = http.StatusServiceUnavailable
+			})
+
+			It("returns a status error", func() {
+				_, err := conn.(*connection).fetchRegistry(context.Background())
+				Expect(err).To(Equal(&StatusError{Code: http.StatusServiceUnavailable}))
+			})
+		})
+
+		Context("when the response is invalid", func() {
+			BeforeEach(func() {
+				body = "{"
+			})
+
+			It("returns an error", func() {
+				_, err := conn.(*connection).fetchRegistry(context.Background())
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe(".fetchRegistryDelta", func() {
+		BeforeEach(func() {
+			body = deltaResponse
+		})
+
+		It("gets the delta", func() {
+			_, err := conn.(*connection).fetchRegistryDelta(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+
+			var req recordedRequest
+			Eventually(requests).Should(Receive(&req))
+			Expect(req.URL.Path).To(Equal("/eureka/apps/delta"))
+		})
+
+		It("returns the action of every instance", func() {
+			r, err := conn.(*connection).fetchRegistryDelta(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.hashcode).To(Equal("UP_1_"))
+			Expect(r.instances).To(HaveLen(1))
+			Expect(r.instances[0].action).To(Equal("DELETED"))
+			Expect(r.instances[0].instance.ID).To(Equal("a2"))
+		})
+	})
+})
+
+var registryResponse = `{
+	"applications": {
+		"versions__delta": "1",
+		"apps__hashcode": "DOWN_1_UP_2_",
+		"application": [
+			{
+				"name": "APP-A",
+				"instance": [
+					{
+						"hostName": "a.host",
+						"app": "APP-A",
+						"status": "UP",
+						"port": {"$": "8080", "@enabled": "true"},
+						"metadata": {"instanceId": "a1"}
+					},
+					{
+						"hostName": "a.host",
+						"app": "APP-A",
+						"status": "UP",
+						"port": {"$": "8080", "@enabled": "true"},
+						"metadata": {"instanceId": "a2"}
+					}
+				]
+			},
+			{
+				"name": "APP-B",
+				"instance": {
+					"hostName": "b.host",
+					"app": "APP-B",
+					"status": "DOWN",
+					"port": {"$": "80", "@enabled": "true"},
+					"metadata": {"instanceId": "b1"}
+				}
+			}
+		]
+	}
+}`
+
+var deltaResponse = `{
+	"applications": {
+		"versions__delta": "2",
+		"apps__hashcode": "UP_1_",
+		"application": {
+			"name": "APP-A",
+			"instance": {
+				"hostName": "a.host",
+				"app": "APP-A",
+				"status": "UP",
+				"port": {"$": "8080", "@enabled": "true"},
+				"metadata": {"instanceId": "a2"},
+				"actionType": "DELETED"
+			}
+		}
+	}
+}`
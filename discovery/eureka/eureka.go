@@ -1,12 +1,20 @@
 package eureka
 
 import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hudl/fargo"
+	"golang.org/x/net/context"
+
+	"github.com/st3v/cfkit/discovery"
 	"github.com/st3v/cfkit/env"
 )
 
@@ -14,140 +22,664 @@ const (
 	appStdPort       = 80
 	appStdSecurePort = 443
 
-	defaultHeartbeatInterval = 30 * time.Second
+	DefaultLeaseRenewalInterval = 30 * time.Second
+	DefaultLeaseDuration        = 90 * time.Second
+)
+
+// Default paths of the pages Spring tooling looks for, e.g. Spring Boot Admin.
+var (
+	DefaultHomePagePath    = "/"
+	DefaultStatusPagePath  = "/info"
+	DefaultHealthCheckPath = "/health"
+)
+
+var connProvider = newConnection
+
+type Option func(*Client)
+
+// MetadataFunc computes instance metadata at registration time.
+type MetadataFunc func(app env.App) map[string]string
+
+func WithMetadata(metadata map[string]string) Option {
+	return WithMetadataFunc(func(env.App) map[string]string {
+		return metadata
+	})
+}
+
+func WithMetadataFunc(fn MetadataFunc) Option {
+	return func(c *Client) {
+		c.metadata = append(c.metadata, fn)
+	}
+}
+
+// WithLeaseRenewalInterval sets how often the instance renews its lease.
+func WithLeaseRenewalInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.leaseRenewalInterval = interval
+	}
+}
+
+// WithLeaseDuration sets how long Eureka keeps the instance without a renewal
+// before evicting it. It should be a multiple of the renewal interval.
+func WithLeaseDuration(duration time.Duration) Option {
+	return func(c *Client) {
+		c.leaseDuration = duration
+	}
+}
+
+// WithHomePagePath sets the path of the home page URL registered with Eureka.
+func WithHomePagePath(path string) Option {
+	return func(c *Client) {
+		c.homePagePath = path
+	}
+}
+
+// WithStatusPagePath sets the path of the status page URL registered with
+// Eureka.
+func WithStatusPagePath(path string) Option {
+	return func(c *Client) {
+		c.statusPagePath = path
+	}
+}
+
+// WithHealthCheckPath sets the path of the health check URL registered with
+// Eureka.
+func WithHealthCheckPath(path string) Option {
+	return func(c *Client) {
+		c.healthCheckPath = path
+	}
+}
+
+type RegistrationMode string
+
+const (
+	// RouteRegistration registers the route of the app, so other apps reach it
+	// through the router.
+	RouteRegistration RegistrationMode = "route"
+
+	// DirectRegistration registers the container address and port of the app
+	// for container-to-container networking.
+	DirectRegistration RegistrationMode = "direct"
 )
 
-var connProvider = fargoConn
+// WithRegistrationMode chooses between registering the route of the app and
+// its container address. Defaults to RouteRegistration.
+func WithRegistrationMode(mode RegistrationMode) Option {
+	return func(c *Client) {
+		c.registrationMode = mode
+	}
+}
+
+// WithInternalHostName registers the given host name, e.g.
+// app.apps.internal, instead of the container IP in direct mode.
+func WithInternalHostName(host string) Option {
+	return func(c *Client) {
+		c.internalHostName = host
+	}
+}
+
+// WithHTTPRoute registers the route of the app as plain HTTP. The secure port
+// is disabled and the page URLs use http instead of https.
+func WithHTTPRoute() Option {
+	return func(c *Client) {
+		c.httpRoute = true
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to Eureka, e.g. to
+// trust a private CA.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// WithClientCertificate authenticates with the given certificate when
+// connecting to Eureka. It is added to the TLS configuration.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(c *Client) {
+		c.certificates = append(c.certificates, cert)
+	}
+}
+
+// WithTransport sends all requests to Eureka through the given RoundTripper.
+// It takes precedence over the TLS options.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithZone sets the zone of the instance. Eureka peers in the same zone are
+// preferred.
+func WithZone(zone string) Option {
+	return func(c *Client) {
+		c.zone = zone
+	}
+}
+
+// WithVIPAddress registers the instance under the given VIP instead of the
+// route of the app.
+func WithVIPAddress(vip string) Option {
+	return func(c *Client) {
+		c.vipAddress = vip
+	}
+}
+
+// WithSecureVIPAddress registers the instance under the given secure VIP
+// instead of the route of the app.
+func WithSecureVIPAddress(vip string) Option {
+	return func(c *Client) {
+		c.secureVIPAddress = vip
+	}
+}
+
+// WithRegion sets the region of the instance.
+func WithRegion(region string) Option {
+	return func(c *Client) {
+		c.region = region
+	}
+}
+
+// WithPreferSameZone makes lookups return only the instances in the zone of
+// the instance, as long as one of them is UP.
+func WithPreferSameZone() Option {
+	return func(c *Client) {
+		c.preferSameZone = true
+	}
+}
+
+// WithZonePeers assigns a zone to the given Eureka peers, adding those not
+// passed to NewClient.
+func WithZonePeers(zone string, uris ...string) Option {
+	return func(c *Client) {
+		for _, uri := range uris {
+			if _, known := c.peerZones[uri]; !known && !contains(c.uris, uri) {
+				c.uris = append(c.uris, uri)
+			}
+			c.peerZones[uri] = zone
+		}
+	}
+}
 
-func NewClient(uris []string, port int, timeout, pollInterval time.Duration) *Client {
-	return &Client{
-		conn:              connProvider(uris, port, timeout, pollInterval),
-		uris:              uris,
-		port:              port,
-		timeout:           timeout,
-		pollInterval:      pollInterval,
-		heartbeatInterval: defaultHeartbeatInterval,
+// WithQuarantine sets how long a failing Eureka peer is skipped.
+func WithQuarantine(quarantine time.Duration) Option {
+	return func(c *Client) {
+		c.quarantine = quarantine
 	}
 }
 
+// WithAppMetadata publishes the space, org and instance index of the app.
+func WithAppMetadata() Option {
+	return WithMetadataFunc(AppMetadata)
+}
+
+func AppMetadata(app env.App) map[string]string {
+	return map[string]string{
+		"cfAppGuid":       app.ID,
+		"cfInstanceIndex": strconv.Itoa(app.Instance.Index),
+		"cfSpaceGuid":     app.Space.ID,
+		"cfSpaceName":     app.Space.Name,
+		"cfOrgGuid":       app.Organization.ID,
+		"cfOrgName":       app.Organization.Name,
+	}
+}
+
+// NewClient returns a client for the Eureka peers at the given URIs. The port
+// is ignored, peers are addressed by their URIs alone, and only kept for
+// compatibility.
+func NewClient(uris []string, port int, timeout, pollInterval time.Duration, opts ...Option) *Client {
+	c := &Client{
+		uris:                 append([]string{}, uris...),
+		port:                 port,
+		timeout:              timeout,
+		pollInterval:         pollInterval,
+		leaseRenewalInterval: DefaultLeaseRenewalInterval,
+		leaseDuration:        DefaultLeaseDuration,
+		statuses:             map[string]discovery.Status{},
+		metadataOverrides:    map[string]map[string]string{},
+		peerZones:            map[string]string{},
+		quarantine:           DefaultQuarantine,
+		homePagePath:         DefaultHomePagePath,
+		statusPagePath:       DefaultStatusPagePath,
+		healthCheckPath:      DefaultHealthCheckPath,
+		registrationMode:     RouteRegistration,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.peers = newPeers(c.uris, c.peerZones, c.zone, c.quarantine)
+	c.conn = connProvider(c)
+
+	return c
+}
+
 type Client struct {
-	conn              FargoConnection
-	uris              []string
-	port              int
-	timeout           time.Duration
-	pollInterval      time.Duration
-	heartbeatInterval time.Duration
+	conn                 Connection
+	uris                 []string
+	port                 int
+	timeout              time.Duration
+	pollInterval         time.Duration
+	leaseRenewalInterval time.Duration
+	leaseDuration        time.Duration
+	metadata             []MetadataFunc
+	mutex                sync.Mutex
+	statuses             map[string]discovery.Status
+	metadataOverrides    map[string]map[string]string
+	cacheMutex           sync.Mutex
+	cache                *Cache
+	vipAddress           string
+	secureVIPAddress     string
+	zone                 string
+	region               string
+	preferSameZone       bool
+	peerZones            map[string]string
+	quarantine           time.Duration
+	peers                *peers
+	homePagePath         string
+	statusPagePath       string
+	healthCheckPath      string
+	httpRoute            bool
+	registrationMode     RegistrationMode
+	internalHostName     string
+	tlsConfig            *tls.Config
+	certificates         []tls.Certificate
+	transport            http.RoundTripper
 }
 
-type FargoConnection interface {
+// Connection sends requests to the Eureka peers, using fargo's types for
+// instances and applications.
+type Connection interface {
 	RegisterInstance(*fargo.Instance) error
 	DeregisterInstance(*fargo.Instance) error
 	HeartBeatInstance(*fargo.Instance) error
 	GetApp(string) (*fargo.Application, error)
 	GetApps() (map[string]*fargo.Application, error)
+	AddMetadataString(*fargo.Instance, string, string) error
+	UpdateInstanceStatus(*fargo.Instance, fargo.StatusType) error
+	GetVIP(string) ([]*fargo.Instance, error)
+	GetSVIP(string) ([]*fargo.Instance, error)
+
+	// context-aware variants, abandoning the request once the context is done
+	RegisterInstanceContext(context.Context, *fargo.Instance) error
+	DeregisterInstanceContext(context.Context, *fargo.Instance) error
+	HeartBeatInstanceContext(context.Context, *fargo.Instance) error
+	GetAppContext(context.Context, string) (*fargo.Application, error)
+	GetAppsContext(context.Context) (map[string]*fargo.Application, error)
+	AddMetadataStringContext(context.Context, *fargo.Instance, string, string) error
+	UpdateInstanceStatusContext(context.Context, *fargo.Instance, fargo.StatusType) error
+	GetVIPContext(context.Context, string) ([]*fargo.Instance, error)
+	GetSVIPContext(context.Context, string) ([]*fargo.Instance, error)
+}
+
+// Deprecated: FargoConnection has been renamed to Connection.
+type FargoConnection interface {
+	Connection
 }
 
-func fargoConn(uris []string, port int, timeout, pollInterval time.Duration) FargoConnection {
-	return &fargo.EurekaConnection{
-		ServiceUrls:  uris,
-		ServicePort:  port,
-		PollInterval: pollInterval,
-		Timeout:      timeout,
-		UseJson:      true,
+// Error describes a failed request to Eureka. Code holds the HTTP status code
+// returned by Eureka and is 0 if the request did not get a response.
+type Error struct {
+	Code    int
+	Err     error
+	message string
+}
+
+func newError(message string, err error) *Error {
+	e := &Error{Err: err, message: message}
+	if statusErr, ok := err.(*StatusError); ok {
+		e.Code = statusErr.Code
 	}
+	return e
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.message, e.Err)
+}
+
+// NotFound reports whether Eureka does not know the requested instance or
+// app, e.g. because it has been restarted or has evicted the instance.
+func (e *Error) NotFound() bool {
+	return e.Code == http.StatusNotFound
+}
+
+// Temporary reports whether the request failed in transport or with a server
+// error and might succeed if retried.
+func (e *Error) Temporary() bool {
+	return e.Code == 0 || e.Code >= http.StatusInternalServerError
 }
 
-func fargoInstance(app env.App) *fargo.Instance {
-	return &fargo.Instance{
-		HostName:         app.URI(),
-		PortJ:            fargo.Port{strconv.Itoa(appStdPort), "true"},
-		SecurePortJ:      fargo.Port{strconv.Itoa(appStdSecurePort), "true"},
+// fargoInstance describes how the instance is reachable, either through the
+// route of the app or directly on the container network.
+func (c *Client) fargoInstance(app env.App) *fargo.Instance {
+	host := c.hostName(app)
+
+	ins := &fargo.Instance{
+		HostName:         host,
+		PortJ:            fargo.Port{Number: strconv.Itoa(appStdPort), Enabled: "true"},
+		SecurePortJ:      fargo.Port{Number: strconv.Itoa(appStdSecurePort), Enabled: "true"},
 		App:              strings.ToUpper(app.Name),
 		IPAddr:           app.Instance.Addr,
 		VipAddress:       app.URI(),
-		Status:           fargo.UP,
+		SecureVipAddress: app.URI(),
+		Status:           fargo.STARTING,
 		Overriddenstatus: fargo.UNKNOWN,
 		DataCenterInfo:   fargo.DataCenterInfo{Name: fargo.MyOwn},
 		Metadata:         fargo.InstanceMetadata{Raw: []byte(fmt.Sprintf(`{"instanceId": "%s"}`, app.Instance.ID))},
 		UniqueID: func(i fargo.Instance) string {
-			return fmt.Sprintf("%s:%s", app.URI(), app.Instance.ID)
+			return fmt.Sprintf("%s:%s", host, app.Instance.ID)
 		},
 	}
+
+	switch {
+	case c.registrationMode == DirectRegistration:
+		ins.IPAddr = app.Instance.InternalIP
+		ins.PortJ.Number = strconv.Itoa(app.Port)
+		ins.SecurePortJ.Enabled = "false"
+	case c.httpRoute:
+		ins.SecurePortJ.Enabled = "false"
+	}
+
+	return ins
+}
+
+func (c *Client) hostName(app env.App) string {
+	if c.registrationMode != DirectRegistration {
+		return app.URI()
+	}
+	if c.internalHostName != "" {
+		return c.internalHostName
+	}
+	return app.Instance.InternalIP
 }
 
 func (c *Client) Register(app env.App) error {
-	instance := fargoInstance(app)
-	err := c.conn.RegisterInstance(instance)
+	return c.RegisterContext(context.Background(), app)
+}
+
+func (c *Client) RegisterContext(ctx context.Context, app env.App) error {
+	if c.registrationMode == DirectRegistration && app.Instance.InternalIP == "" {
+		return errors.New("Error registering app with Eureka: CF_INSTANCE_INTERNAL_IP not set")
+	}
+	if c.registrationMode == DirectRegistration && app.Port == 0 {
+		return errors.New("Error registering app with Eureka: port of the app unknown")
+	}
+
+	instance := c.fargoInstance(app)
+
+	metadata, err := c.registrationMetadata(app, instance)
 	if err != nil {
 		return fmt.Errorf("Error registering app with Eureka: %s", err)
 	}
+	instance.Metadata = fargo.InstanceMetadata{Raw: metadata}
+	instance.Status = fargo.StatusType(c.status(app))
+	if c.vipAddress != "" {
+		instance.VipAddress = c.vipAddress
+	}
+	if c.secureVIPAddress != "" {
+		instance.SecureVipAddress = c.secureVIPAddress
+	}
+	instance.LeaseInfo = fargo.LeaseInfo{
+		RenewalIntervalInSecs: seconds(c.leaseRenewalInterval),
+		DurationInSecs:        seconds(c.leaseDuration),
+	}
+	c.setPageURLs(instance)
 
-	// assume all apps use the same renewal interval
-	if instance.LeaseInfo.RenewalIntervalInSecs > 0 {
-		c.heartbeatInterval = time.Duration(instance.LeaseInfo.RenewalIntervalInSecs) * time.Second
+	err = c.conn.RegisterInstanceContext(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("Error registering app with Eureka: %s", err)
 	}
 
 	return nil
 }
 
+// setPageURLs points the home, status and health check URLs at the address the
+// instance is registered with, preferring the secure port.
+func (c *Client) setPageURLs(ins *fargo.Instance) {
+	base := instance(ins).BaseURL()
+	ins.HomePageUrl = base + c.homePagePath
+	ins.StatusPageUrl = base + c.statusPagePath
+	ins.HealthCheckUrl = base + c.healthCheckPath
+}
+
 func (c *Client) Deregister(app env.App) error {
-	err := c.conn.DeregisterInstance(fargoInstance(app))
+	return c.DeregisterContext(context.Background(), app)
+}
+
+func (c *Client) DeregisterContext(ctx context.Context, app env.App) error {
+	err := c.conn.DeregisterInstanceContext(ctx, c.fargoInstance(app))
 	if err != nil {
 		return fmt.Errorf("Error deregistering app with Eureka: %s", err)
 	}
 	return nil
 }
 
+// Heartbeat renews the lease of the instance. Failures are reported as *Error.
 func (c *Client) Heartbeat(app env.App) error {
-	err := c.conn.HeartBeatInstance(fargoInstance(app))
+	return c.HeartbeatContext(context.Background(), app)
+}
+
+func (c *Client) HeartbeatContext(ctx context.Context, app env.App) error {
+	err := c.conn.HeartBeatInstanceContext(ctx, c.fargoInstance(app))
 	if err != nil {
-		return fmt.Errorf("Error sending heartbeat for app to Eureka: %s", err)
+		return newError("Error sending heartbeat for app to Eureka", err)
 	}
 	return nil
 }
 
-func (c *Client) HeartbeatInterval() time.Duration {
-	return c.heartbeatInterval
+// SetStatus updates the status of a registered instance. The status is also
+// used for any subsequent registration of the same instance.
+func (c *Client) SetStatus(app env.App, status discovery.Status) error {
+	return c.SetStatusContext(context.Background(), app, status)
 }
 
-func (c *Client) Apps() (map[string][]string, error) {
-	apps, err := c.conn.GetApps()
+func (c *Client) SetStatusContext(ctx context.Context, app env.App, status discovery.Status) error {
+	err := c.conn.UpdateInstanceStatusContext(ctx, c.fargoInstance(app), fargo.StatusType(status))
 	if err != nil {
-		return map[string][]string{}, fmt.Errorf("Error retrieving apps from Eureka: %s", err)
+		return fmt.Errorf("Error setting status %s with Eureka: %s", status, err)
 	}
 
-	result := map[string][]string{}
-	for _, app := range apps {
-		uris := make([]string, len(app.Instances))
+	c.mutex.Lock()
+	c.statuses[c.statusKey(app)] = status
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// statusKey tells apart the logical apps registered by one process.
+func (c *Client) statusKey(app env.App) string {
+	ins := c.fargoInstance(app)
+	return ins.App + "/" + ins.Id()
+}
+
+// instances register as STARTING until told otherwise
+func (c *Client) status(app env.App) discovery.Status {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if status, ok := c.statuses[c.statusKey(app)]; ok {
+		return status
+	}
+	return discovery.StatusStarting
+}
+
+// UpdateMetadata changes a single metadata value of the registered instance
+// without re-registering it. The value is kept for later registrations.
+func (c *Client) UpdateMetadata(app env.App, key, value string) error {
+	return c.UpdateMetadataContext(context.Background(), app, key, value)
+}
+
+func (c *Client) UpdateMetadataContext(ctx context.Context, app env.App, key, value string) error {
+	err := c.conn.AddMetadataStringContext(ctx, c.fargoInstance(app), key, value)
+	if err != nil {
+		return fmt.Errorf("Error updating metadata '%s' with Eureka: %s", key, err)
+	}
 
-		for i, inst := range app.Instances {
-			uris[i] = inst.HostName
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	overrides, ok := c.metadataOverrides[c.statusKey(app)]
+	if !ok {
+		overrides = map[string]string{}
+		c.metadataOverrides[c.statusKey(app)] = overrides
+	}
+	overrides[key] = value
+
+	return nil
+}
+
+// HeartbeatInterval is the lease renewal interval the instances are
+// registered with.
+func (c *Client) HeartbeatInterval() time.Duration {
+	return c.leaseRenewalInterval
+}
+
+// Cache starts a local copy of the registry, refreshed every poll interval,
+// and returns it. From then on App and Apps are served from memory until the
+// cache is stopped.
+func (c *Client) Cache() (*Cache, error) {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	if c.cache == nil {
+		source, ok := c.conn.(registrySource)
+		if !ok {
+			return nil, errors.New("Error creating registry cache: connection cannot fetch the registry")
 		}
+		c.cache = newCache(source, c.pollInterval)
+	}
 
-		result[app.Name] = uris
+	if err := c.cache.Start(); err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return c.cache, nil
+}
+
+// runningCache returns the registry cache if it is in use, nil otherwise.
+func (c *Client) runningCache() *Cache {
+	c.cacheMutex.Lock()
+	cache := c.cache
+	c.cacheMutex.Unlock()
+
+	if cache != nil && cache.Running() {
+		return cache
+	}
+	return nil
 }
 
-func (c *Client) App(name string) ([]string, error) {
-	app, err := c.conn.GetApp(name)
+func (c *Client) Apps() (map[string]discovery.Application, error) {
+	return c.AppsContext(context.Background())
+}
+
+func (c *Client) AppsContext(ctx context.Context) (map[string]discovery.Application, error) {
+	apps, err := c.apps(ctx)
 	if err != nil {
-		return []string{}, fmt.Errorf("Error retrieving app '%s' from Eureka: %s", name, err)
+		return apps, err
 	}
 
-	result := make([]string, len(app.Instances))
-	for i, inst := range app.Instances {
-		result[i] = inst.HostName
+	for name, app := range apps {
+		apps[name] = c.preferZone(app)
+	}
+
+	return apps, nil
+}
+
+func (c *Client) apps(ctx context.Context) (map[string]discovery.Application, error) {
+	if cache := c.runningCache(); cache != nil {
+		return cache.Apps(), nil
+	}
+
+	apps, err := c.conn.GetAppsContext(ctx)
+	if err != nil {
+		return map[string]discovery.Application{}, fmt.Errorf("Error retrieving apps from Eureka: %s", err)
+	}
+
+	result := map[string]discovery.Application{}
+	for _, app := range apps {
+		result[app.Name] = application(app)
 	}
 
 	return result, nil
 }
 
+func (c *Client) App(name string) (discovery.Application, error) {
+	return c.AppContext(context.Background(), name)
+}
+
+func (c *Client) AppContext(ctx context.Context, name string) (discovery.Application, error) {
+	app, err := c.app(ctx, name)
+	if err != nil {
+		return app, err
+	}
+
+	return c.preferZone(app), nil
+}
+
+func (c *Client) app(ctx context.Context, name string) (discovery.Application, error) {
+	if cache := c.runningCache(); cache != nil {
+		return cache.App(name)
+	}
+
+	app, err := c.conn.GetAppContext(ctx, name)
+	if err != nil {
+		return discovery.Application{}, newError(fmt.Sprintf("Error retrieving app '%s' from Eureka", name), err)
+	}
+
+	return application(app), nil
+}
+
+// VIP returns the instances of all apps registered under the given VIP.
+func (c *Client) VIP(vip string) ([]discovery.Instance, error) {
+	return c.VIPContext(context.Background(), vip)
+}
+
+func (c *Client) VIPContext(ctx context.Context, vip string) ([]discovery.Instance, error) {
+	if cache := c.runningCache(); cache != nil {
+		return cache.VIP(vip), nil
+	}
+
+	instances, err := c.conn.GetVIPContext(ctx, vip)
+	if err != nil {
+		return []discovery.Instance{}, fmt.Errorf("Error retrieving VIP '%s' from Eureka: %s", vip, err)
+	}
+
+	return instanceList(instances), nil
+}
+
+// SecureVIP returns the instances of all apps registered under the given
+// secure VIP.
+func (c *Client) SecureVIP(vip string) ([]discovery.Instance, error) {
+	return c.SecureVIPContext(context.Background(), vip)
+}
+
+func (c *Client) SecureVIPContext(ctx context.Context, vip string) ([]discovery.Instance, error) {
+	if cache := c.runningCache(); cache != nil {
+		return cache.SecureVIP(vip), nil
+	}
+
+	instances, err := c.conn.GetSVIPContext(ctx, vip)
+	if err != nil {
+		return []discovery.Instance{}, fmt.Errorf("Error retrieving secure VIP '%s' from Eureka: %s", vip, err)
+	}
+
+	return instanceList(instances), nil
+}
+
+func (c *Client) preferZone(app discovery.Application) discovery.Application {
+	if !c.preferSameZone || c.zone == "" {
+		return app
+	}
+	return app.PreferZone(c.zone)
+}
+
 func (c *Client) URIs() []string {
 	return c.uris
 }
 
+// Deprecated: Port returns the port given to NewClient, which is not used.
 func (c *Client) Port() int {
 	return c.port
 }
@@ -159,3 +691,166 @@ func (c *Client) Timeout() time.Duration {
 func (c *Client) PollInterval() time.Duration {
 	return c.pollInterval
 }
+
+func (c *Client) Zone() string {
+	return c.zone
+}
+
+func (c *Client) Region() string {
+	return c.region
+}
+
+func (c *Client) PreferSameZone() bool {
+	return c.preferSameZone
+}
+
+// Peers reports the health of the Eureka peers in configured order.
+func (c *Client) Peers() []PeerHealth {
+	return c.peers.health()
+}
+
+func (c *Client) LeaseRenewalInterval() time.Duration {
+	return c.leaseRenewalInterval
+}
+
+func (c *Client) LeaseDuration() time.Duration {
+	return c.leaseDuration
+}
+
+func (c *Client) HomePagePath() string {
+	return c.homePagePath
+}
+
+func (c *Client) StatusPagePath() string {
+	return c.statusPagePath
+}
+
+func (c *Client) HealthCheckPath() string {
+	return c.healthCheckPath
+}
+
+func (c *Client) HTTPRoute() bool {
+	return c.httpRoute
+}
+
+func (c *Client) RegistrationMode() RegistrationMode {
+	return c.registrationMode
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func seconds(d time.Duration) int32 {
+	return int32(d / time.Second)
+}
+
+// registrationMetadata merges the configured metadata in order, starting with
+// the zone and the management port of the instance and ending with the values
+// updated at runtime. The instance id cannot be overridden since Eureka uses
+// it to identify the instance.
+func (c *Client) registrationMetadata(app env.App, ins *fargo.Instance) ([]byte, error) {
+	managementPort := ins.PortJ.Number
+	if ins.SecurePortJ.Enabled == "true" {
+		managementPort = ins.SecurePortJ.Number
+	}
+
+	metadata := map[string]string{
+		"management.port": managementPort,
+	}
+	if c.zone != "" {
+		metadata["zone"] = c.zone
+	}
+
+	for _, fn := range c.metadata {
+		for k, v := range fn(app) {
+			metadata[k] = v
+		}
+	}
+
+	c.mutex.Lock()
+	for k, v := range c.metadataOverrides[c.statusKey(app)] {
+		metadata[k] = v
+	}
+	c.mutex.Unlock()
+
+	metadata["instanceId"] = app.Instance.ID
+
+	return json.Marshal(metadata)
+}
+
+func application(app *fargo.Application) discovery.Application {
+	result := discovery.Application{
+		Name:      app.Name,
+		Instances: make([]discovery.Instance, len(app.Instances)),
+	}
+
+	for i, inst := range app.Instances {
+		result.Instances[i] = instance(inst)
+	}
+
+	return result
+}
+
+func instanceList(instances []*fargo.Instance) []discovery.Instance {
+	result := make([]discovery.Instance, len(instances))
+	for i, inst := range instances {
+		result[i] = instance(inst)
+	}
+	return result
+}
+
+func instance(inst *fargo.Instance) discovery.Instance {
+	metadata := instanceMetadata(inst.Metadata)
+
+	id := metadata["instanceId"]
+	if id == "" {
+		id = inst.Id()
+	}
+
+	zone := metadata["zone"]
+	if zone == "" {
+		zone = inst.DataCenterInfo.Metadata.AvailabilityZone
+	}
+
+	return discovery.Instance{
+		ID:                id,
+		App:               inst.App,
+		HostName:          inst.HostName,
+		IPAddr:            inst.IPAddr,
+		Port:              port(inst.Port, inst.PortJ),
+		PortEnabled:       inst.PortJ.Enabled == "true",
+		SecurePort:        port(inst.SecurePort, inst.SecurePortJ),
+		SecurePortEnabled: inst.SecurePortJ.Enabled == "true",
+		VIPAddress:        inst.VipAddress,
+		SecureVIPAddress:  inst.SecureVipAddress,
+		Status:            discovery.Status(inst.Status),
+		Zone:              zone,
+		Metadata:          metadata,
+	}
+}
+
+func port(number int, portJ fargo.Port) int {
+	if number != 0 {
+		return number
+	}
+	n, _ := strconv.Atoi(portJ.Number)
+	return n
+}
+
+func instanceMetadata(metadata fargo.InstanceMetadata) map[string]string {
+	// fargo only parses the raw JSON or XML metadata on first access
+	metadata.GetString("")
+
+	result := map[string]string{}
+	for k, v := range metadata.GetMap() {
+		result[k] = fmt.Sprint(v)
+	}
+
+	return result
+}
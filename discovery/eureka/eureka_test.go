@@ -1,18 +1,31 @@
 package eureka
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/hudl/fargo"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/st3v/cfkit/discovery"
 	"github.com/st3v/cfkit/discovery/eureka/fake"
 	"github.com/st3v/cfkit/env"
 )
 
+type cachingConn struct {
+	*fake.Connection
+	*stubSource
+}
+
+func registeredInstance(conn *fake.Connection, i int) *fargo.Instance {
+	_, instance := conn.RegisterInstanceContextArgsForCall(i)
+	return instance
+}
+
 var _ = Describe("eureka", func() {
 	var (
 		expectedURIs         = []string{"uri1", "uri2", "uri3"}
@@ -22,17 +35,51 @@ var _ = Describe("eureka", func() {
 	)
 
 	Describe(".NewClient", func() {
-		It("returns a client with a correctly initialized fargo connection", func() {
+		It("returns a client with a correctly initialized connection", func() {
 			c := NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval)
 
-			conn, ok := c.conn.(*fargo.EurekaConnection)
+			conn, ok := c.conn.(*connection)
 			Expect(ok).To(BeTrue())
 
-			Expect(conn.ServiceUrls).To(HaveLen(len(expectedURIs)))
-			Expect(conn.ServiceUrls).To(ConsistOf(expectedURIs))
-			Expect(conn.ServicePort).To(Equal(expectedPort))
-			Expect(conn.Timeout).To(Equal(expectedTimeout))
-			Expect(conn.PollInterval).To(Equal(expectedPollInterval))
+			Expect(conn.client.Timeout).To(Equal(expectedTimeout))
+			Expect(conn.peers).To(Equal(c.peers))
+		})
+
+		It("tries the peers in the given order", func() {
+			c := NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval)
+
+			peers := c.Peers()
+			Expect(peers).To(HaveLen(3))
+			for i, peer := range peers {
+				Expect(peer.URI).To(Equal(expectedURIs[i]))
+				Expect(peer.Healthy).To(BeTrue())
+			}
+		})
+
+		Context("when zone options are given", func() {
+			It("knows its region", func() {
+				c := NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval, WithRegion("us-east"))
+				Expect(c.Region()).To(Equal("us-east"))
+			})
+
+			It("assigns zones to the peers", func() {
+				c := NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+					WithZone("zone-b"),
+					WithZonePeers("zone-a", "uri1"),
+					WithZonePeers("zone-b", "uri2", "uri4"),
+				)
+
+				Expect(c.Zone()).To(Equal("zone-b"))
+				Expect(c.PreferSameZone()).To(BeFalse())
+				Expect(c.URIs()).To(Equal([]string{"uri1", "uri2", "uri3", "uri4"}))
+
+				peers := c.Peers()
+				Expect(peers).To(HaveLen(4))
+				Expect(peers[0].Zone).To(Equal("zone-a"))
+				Expect(peers[1].Zone).To(Equal("zone-b"))
+				Expect(peers[2].Zone).To(BeEmpty())
+				Expect(peers[3].Zone).To(Equal("zone-b"))
+			})
 		})
 	})
 
@@ -40,8 +87,8 @@ var _ = Describe("eureka", func() {
 		var (
 			client *Client
 
-			fakeConn         *fake.FargoConnection
-			origConnProvider = fargoConn
+			fakeConn         *fake.Connection
+			origConnProvider = newConnection
 
 			fargoApp  *fargo.Application
 			fargoApps map[string]*fargo.Application
@@ -68,7 +115,8 @@ var _ = Describe("eureka", func() {
 				Expect(i.App).To(Equal(strings.ToUpper(app.Name)))
 				Expect(i.IPAddr).To(Equal(app.Instance.Addr))
 				Expect(i.VipAddress).To(Equal(app.URI()))
-				Expect(i.Status).To(Equal(fargo.UP))
+				Expect(i.SecureVipAddress).To(Equal(app.URI()))
+				Expect(i.Status).To(Equal(fargo.STARTING))
 				Expect(i.Overriddenstatus).To(Equal(fargo.UNKNOWN))
 				Expect(i.DataCenterInfo.Name).To(Equal(fargo.MyOwn))
 				Expect(i.UniqueID).ToNot(BeNil())
@@ -77,25 +125,35 @@ var _ = Describe("eureka", func() {
 		)
 
 		BeforeEach(func() {
-			fakeConn = new(fake.FargoConnection)
+			fakeConn = new(fake.Connection)
 
 			fargoApp = &fargo.Application{
 				Name: app.Name,
 				Instances: []*fargo.Instance{{
-					UniqueID: func(fargo.Instance) string {
-						return app.Instance.ID
+					HostName:    app.URI(),
+					App:         strings.ToUpper(app.Name),
+					IPAddr:      "10.0.0.1",
+					Status:      fargo.UP,
+					Port:        80,
+					PortJ:       fargo.Port{Number: "80", Enabled: "false"},
+					SecurePort:  443,
+					SecurePortJ: fargo.Port{Number: "443", Enabled: "true"},
+					DataCenterInfo: fargo.DataCenterInfo{
+						Metadata: fargo.AmazonMetadataType{AvailabilityZone: "zone-a"},
+					},
+					Metadata: fargo.InstanceMetadata{
+						Raw: []byte(`{"instanceId": "app-instance_id", "version": "1.2.3"}`),
 					},
-					HostName: app.URI(),
 				}},
 			}
-			fakeConn.GetAppReturns(fargoApp, nil)
+			fakeConn.GetAppContextReturns(fargoApp, nil)
 
 			fargoApps = map[string]*fargo.Application{
 				fargoApp.Name: fargoApp,
 			}
-			fakeConn.GetAppsReturns(fargoApps, nil)
+			fakeConn.GetAppsContextReturns(fargoApps, nil)
 
-			connProvider = func(uris []string, port int, timeout, pollInterval time.Duration) FargoConnection {
+			connProvider = func(*Client) Connection {
 				return fakeConn
 			}
 
@@ -109,15 +167,15 @@ var _ = Describe("eureka", func() {
 		Describe(".Register", func() {
 			It("calls conn.RegisterInstance with the correct instance", func() {
 				client.Register(app)
-				Expect(fakeConn.RegisterInstanceCallCount()).To(Equal(1))
-				assertInstance(fakeConn.RegisterInstanceArgsForCall(0))
+				Expect(fakeConn.RegisterInstanceContextCallCount()).To(Equal(1))
+				assertInstance(registeredInstance(fakeConn, 0))
 			})
 
 			Context("when conn.RegisterInstance returns an error", func() {
 				var expectedErr = errors.New("some-error")
 
 				BeforeEach(func() {
-					fakeConn.RegisterInstanceReturns(expectedErr)
+					fakeConn.RegisterInstanceContextReturns(expectedErr)
 				})
 
 				It("returns the error", func() {
@@ -129,18 +187,195 @@ var _ = Describe("eureka", func() {
 			})
 		})
 
+		Describe(".Register with metadata", func() {
+			var registeredMetadata = func() map[string]interface{} {
+				Expect(fakeConn.RegisterInstanceContextCallCount()).To(Equal(1))
+				raw := registeredInstance(fakeConn, 0).Metadata.Raw
+
+				var metadata map[string]interface{}
+				Expect(json.Unmarshal(raw, &metadata)).To(Succeed())
+				return metadata
+			}
+
+			It("registers the instance id by default", func() {
+				Expect(client.Register(app)).To(Succeed())
+				Expect(registeredMetadata()).To(Equal(map[string]interface{}{
+					"instanceId":      app.Instance.ID,
+					"management.port": "443",
+				}))
+			})
+
+			Context("when the zone is known", func() {
+				BeforeEach(func() {
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+						WithZone("zone-a"),
+						WithMetadata(map[string]string{"version": "1.0.0"}),
+					)
+				})
+
+				It("registers the zone", func() {
+					Expect(client.Register(app)).To(Succeed())
+					Expect(registeredMetadata()).To(Equal(map[string]interface{}{
+						"instanceId":      app.Instance.ID,
+						"management.port": "443",
+						"zone":            "zone-a",
+						"version":         "1.0.0",
+					}))
+				})
+			})
+
+			Context("when metadata options are given", func() {
+				var version string
+
+				BeforeEach(func() {
+					version = "1.0.0"
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+						WithMetadata(map[string]string{"version": "0.9.0", "git": "abc123"}),
+						WithAppMetadata(),
+						WithMetadataFunc(func(a env.App) map[string]string {
+							return map[string]string{"version": version, "name": a.Name}
+						}),
+					)
+				})
+
+				It("merges them in order", func() {
+					version = "1.1.0"
+					Expect(client.Register(app)).To(Succeed())
+					Expect(registeredMetadata()).To(Equal(map[string]interface{}{
+						"instanceId":      app.Instance.ID,
+						"management.port": "443",
+						"version":         "1.1.0",
+						"git":             "abc123",
+						"name":            app.Name,
+						"cfAppGuid":       app.ID,
+						"cfInstanceIndex": "99",
+						"cfSpaceGuid":     "",
+						"cfSpaceName":     "",
+						"cfOrgGuid":       "",
+						"cfOrgName":       "",
+					}))
+				})
+			})
+
+			Context("when an option tries to override the instance id", func() {
+				BeforeEach(func() {
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+						WithMetadata(map[string]string{"instanceId": "other"}),
+					)
+				})
+
+				It("keeps the instance id", func() {
+					Expect(client.Register(app)).To(Succeed())
+					Expect(registeredMetadata()["instanceId"]).To(Equal(app.Instance.ID))
+				})
+			})
+		})
+
+		Describe(".SetStatus", func() {
+			It("calls conn.UpdateInstanceStatus for the instance", func() {
+				Expect(client.SetStatus(app, discovery.StatusOutOfService)).To(Succeed())
+				Expect(fakeConn.UpdateInstanceStatusContextCallCount()).To(Equal(1))
+
+				_, instance, status := fakeConn.UpdateInstanceStatusContextArgsForCall(0)
+				assertInstance(instance)
+				Expect(status).To(Equal(fargo.OUTOFSERVICE))
+			})
+
+			It("uses the status for subsequent registrations", func() {
+				Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+				Expect(client.Register(app)).To(Succeed())
+				Expect(registeredInstance(fakeConn, 0).Status).To(Equal(fargo.UP))
+			})
+
+			It("keeps the status per app", func() {
+				other := app
+				other.Name = "other-app"
+
+				Expect(client.SetStatus(app, discovery.StatusUp)).To(Succeed())
+				Expect(client.Register(other)).To(Succeed())
+				Expect(registeredInstance(fakeConn, 0).Status).To(Equal(fargo.STARTING))
+			})
+
+			Context("when conn.UpdateInstanceStatus returns an error", func() {
+				BeforeEach(func() {
+					fakeConn.UpdateInstanceStatusContextReturns(errors.New("some-error"))
+				})
+
+				It("returns the error", func() {
+					err := client.SetStatus(app, discovery.StatusDown)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Error setting status DOWN"))
+					Expect(err.Error()).To(ContainSubstring("some-error"))
+				})
+
+				It("does not remember the status", func() {
+					client.SetStatus(app, discovery.StatusDown)
+					Expect(client.Register(app)).To(Succeed())
+					Expect(registeredInstance(fakeConn, 0).Status).To(Equal(fargo.STARTING))
+				})
+			})
+		})
+
+		Describe(".UpdateMetadata", func() {
+			It("calls conn.AddMetadataString for the instance", func() {
+				Expect(client.UpdateMetadata(app, "version", "2.0.0")).To(Succeed())
+				Expect(fakeConn.AddMetadataStringContextCallCount()).To(Equal(1))
+
+				_, instance, key, value := fakeConn.AddMetadataStringContextArgsForCall(0)
+				assertInstance(instance)
+				Expect(key).To(Equal("version"))
+				Expect(value).To(Equal("2.0.0"))
+			})
+
+			Context("when conn.AddMetadataString returns an error", func() {
+				BeforeEach(func() {
+					fakeConn.AddMetadataStringContextReturns(errors.New("some-error"))
+				})
+
+				It("returns the error", func() {
+					err := client.UpdateMetadata(app, "version", "2.0.0")
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Error updating metadata 'version'"))
+					Expect(err.Error()).To(ContainSubstring("some-error"))
+				})
+
+				It("does not keep the value", func() {
+					client.UpdateMetadata(app, "version", "2.0.0")
+					Expect(client.Register(app)).To(Succeed())
+
+					var metadata map[string]string
+					Expect(json.Unmarshal(registeredInstance(fakeConn, 0).Metadata.Raw, &metadata)).To(Succeed())
+					Expect(metadata).ToNot(HaveKey("version"))
+				})
+			})
+
+			It("keeps the value when registering again", func() {
+				client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+					WithMetadata(map[string]string{"version": "1.0.0"}),
+				)
+
+				Expect(client.UpdateMetadata(app, "version", "2.0.0")).To(Succeed())
+				Expect(client.Register(app)).To(Succeed())
+
+				var metadata map[string]string
+				Expect(json.Unmarshal(registeredInstance(fakeConn, 0).Metadata.Raw, &metadata)).To(Succeed())
+				Expect(metadata).To(HaveKeyWithValue("version", "2.0.0"))
+			})
+		})
+
 		Describe(".Deregister", func() {
 			It("calls conn.DeregisterInstance with the correct instance", func() {
 				client.Deregister(app)
-				Expect(fakeConn.DeregisterInstanceCallCount()).To(Equal(1))
-				assertInstance(fakeConn.DeregisterInstanceArgsForCall(0))
+				Expect(fakeConn.DeregisterInstanceContextCallCount()).To(Equal(1))
+				_, instance := fakeConn.DeregisterInstanceContextArgsForCall(0)
+				assertInstance(instance)
 			})
 
 			Context("when conn.RegisterInstance returns an error", func() {
 				var expectedErr = errors.New("some-error")
 
 				BeforeEach(func() {
-					fakeConn.DeregisterInstanceReturns(expectedErr)
+					fakeConn.DeregisterInstanceContextReturns(expectedErr)
 				})
 
 				It("returns the error", func() {
@@ -155,15 +390,16 @@ var _ = Describe("eureka", func() {
 		Describe(".Heartbeat", func() {
 			It("calls conn.HeartBeatInstance with the correct instance", func() {
 				client.Heartbeat(app)
-				Expect(fakeConn.HeartBeatInstanceCallCount()).To(Equal(1))
-				assertInstance(fakeConn.HeartBeatInstanceArgsForCall(0))
+				Expect(fakeConn.HeartBeatInstanceContextCallCount()).To(Equal(1))
+				_, instance := fakeConn.HeartBeatInstanceContextArgsForCall(0)
+				assertInstance(instance)
 			})
 
 			Context("when conn.HeartBeatInstance returns an error", func() {
 				var expectedErr = errors.New("some-error")
 
 				BeforeEach(func() {
-					fakeConn.HeartBeatInstanceReturns(expectedErr)
+					fakeConn.HeartBeatInstanceContextReturns(expectedErr)
 				})
 
 				It("returns the error", func() {
@@ -172,6 +408,293 @@ var _ = Describe("eureka", func() {
 					Expect(err.Error()).To(ContainSubstring("Error sending heartbeat for app"))
 					Expect(err.Error()).To(ContainSubstring(expectedErr.Error()))
 				})
+
+				It("reports a transport failure", func() {
+					err, ok := client.Heartbeat(app).(*Error)
+					Expect(ok).To(BeTrue())
+					Expect(err.Code).To(BeZero())
+					Expect(err.Temporary()).To(BeTrue())
+					Expect(err.NotFound()).To(BeFalse())
+					Expect(discovery.IsNotRegistered(err)).To(BeFalse())
+				})
+			})
+
+			Context("when Eureka does not know the instance", func() {
+				BeforeEach(func() {
+					fakeConn.HeartBeatInstanceContextReturns(&StatusError{Code: 404})
+				})
+
+				It("reports the instance as not found", func() {
+					err, ok := client.Heartbeat(app).(*Error)
+					Expect(ok).To(BeTrue())
+					Expect(err.Code).To(Equal(404))
+					Expect(err.NotFound()).To(BeTrue())
+					Expect(err.Temporary()).To(BeFalse())
+					Expect(discovery.IsNotRegistered(err)).To(BeTrue())
+				})
+			})
+
+			Context("when Eureka fails with a server error", func() {
+				BeforeEach(func() {
+					fakeConn.HeartBeatInstanceContextReturns(&StatusError{Code: 503})
+				})
+
+				It("reports a temporary failure", func() {
+					err, ok := client.Heartbeat(app).(*Error)
+					Expect(ok).To(BeTrue())
+					Expect(err.Code).To(Equal(503))
+					Expect(err.Error()).To(ContainSubstring("Eureka returned status 503"))
+					Expect(err.Temporary()).To(BeTrue())
+					Expect(err.NotFound()).To(BeFalse())
+				})
+			})
+		})
+
+		Describe(".Register with page URLs", func() {
+			It("registers the default pages on the secure route", func() {
+				Expect(client.Register(app)).To(Succeed())
+				instance := registeredInstance(fakeConn, 0)
+				Expect(instance.HomePageUrl).To(Equal("https://app-uri-1/"))
+				Expect(instance.StatusPageUrl).To(Equal("https://app-uri-1/info"))
+				Expect(instance.HealthCheckUrl).To(Equal("https://app-uri-1/health"))
+			})
+
+			Context("when paths are configured", func() {
+				BeforeEach(func() {
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+						WithHomePagePath("/home"),
+						WithStatusPagePath("/actuator/info"),
+						WithHealthCheckPath("/actuator/health"),
+					)
+				})
+
+				It("registers the configured pages", func() {
+					Expect(client.Register(app)).To(Succeed())
+					instance := registeredInstance(fakeConn, 0)
+					Expect(instance.HomePageUrl).To(Equal("https://app-uri-1/home"))
+					Expect(instance.StatusPageUrl).To(Equal("https://app-uri-1/actuator/info"))
+					Expect(instance.HealthCheckUrl).To(Equal("https://app-uri-1/actuator/health"))
+				})
+
+				It("exposes the paths", func() {
+					Expect(client.HomePagePath()).To(Equal("/home"))
+					Expect(client.StatusPagePath()).To(Equal("/actuator/info"))
+					Expect(client.HealthCheckPath()).To(Equal("/actuator/health"))
+				})
+			})
+
+			Context("when the route is plain HTTP", func() {
+				BeforeEach(func() {
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+						WithHTTPRoute(),
+					)
+				})
+
+				It("disables the secure port", func() {
+					Expect(client.Register(app)).To(Succeed())
+					Expect(registeredInstance(fakeConn, 0).SecurePortJ.Enabled).To(Equal("false"))
+				})
+
+				It("registers http pages", func() {
+					Expect(client.Register(app)).To(Succeed())
+					instance := registeredInstance(fakeConn, 0)
+					Expect(instance.HomePageUrl).To(Equal("http://app-uri-1/"))
+					Expect(instance.StatusPageUrl).To(Equal("http://app-uri-1/info"))
+					Expect(instance.HealthCheckUrl).To(Equal("http://app-uri-1/health"))
+				})
+
+				It("registers the plain management port", func() {
+					Expect(client.Register(app)).To(Succeed())
+					raw := registeredInstance(fakeConn, 0).Metadata.Raw
+					Expect(string(raw)).To(ContainSubstring(`"management.port":"80"`))
+				})
+			})
+		})
+
+		Describe(".Register in direct mode", func() {
+			var directApp env.App
+
+			BeforeEach(func() {
+				directApp = app
+				directApp.Port = 8080
+				directApp.Instance.InternalIP = "10.255.0.4"
+
+				client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+					WithRegistrationMode(DirectRegistration),
+				)
+			})
+
+			It("registers the container address", func() {
+				Expect(client.Register(directApp)).To(Succeed())
+				instance := registeredInstance(fakeConn, 0)
+				Expect(instance.HostName).To(Equal("10.255.0.4"))
+				Expect(instance.IPAddr).To(Equal("10.255.0.4"))
+				Expect(instance.PortJ).To(Equal(fargo.Port{Number: "8080", Enabled: "true"}))
+				Expect(instance.SecurePortJ.Enabled).To(Equal("false"))
+			})
+
+			It("registers a unique instance id", func() {
+				Expect(client.Register(directApp)).To(Succeed())
+				instance := registeredInstance(fakeConn, 0)
+				Expect(instance.Id()).To(Equal("10.255.0.4:" + app.Instance.ID))
+			})
+
+			It("registers pages and management port on the container port", func() {
+				Expect(client.Register(directApp)).To(Succeed())
+				instance := registeredInstance(fakeConn, 0)
+				Expect(instance.HealthCheckUrl).To(Equal("http://10.255.0.4:8080/health"))
+				Expect(string(instance.Metadata.Raw)).To(ContainSubstring(`"management.port":"8080"`))
+			})
+
+			It("addresses the same instance in subsequent calls", func() {
+				Expect(client.Register(directApp)).To(Succeed())
+				Expect(client.Heartbeat(directApp)).To(Succeed())
+				_, instance := fakeConn.HeartBeatInstanceContextArgsForCall(0)
+				Expect(instance.Id()).To(Equal(registeredInstance(fakeConn, 0).Id()))
+			})
+
+			It("exposes the mode", func() {
+				Expect(client.RegistrationMode()).To(Equal(DirectRegistration))
+			})
+
+			Context("when an internal host name is given", func() {
+				BeforeEach(func() {
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+						WithRegistrationMode(DirectRegistration),
+						WithInternalHostName("app-name.apps.internal"),
+					)
+				})
+
+				It("registers the host name", func() {
+					Expect(client.Register(directApp)).To(Succeed())
+					instance := registeredInstance(fakeConn, 0)
+					Expect(instance.HostName).To(Equal("app-name.apps.internal"))
+					Expect(instance.IPAddr).To(Equal("10.255.0.4"))
+					Expect(instance.Id()).To(Equal("app-name.apps.internal:" + app.Instance.ID))
+				})
+			})
+
+			Context("when the internal IP is unknown", func() {
+				It("returns an error", func() {
+					err := client.Register(app)
+					Expect(err).To(MatchError("Error registering app with Eureka: CF_INSTANCE_INTERNAL_IP not set"))
+					Expect(fakeConn.RegisterInstanceContextCallCount()).To(BeZero())
+				})
+			})
+
+			Context("when the port is unknown", func() {
+				It("returns an error", func() {
+					directApp.Port = 0
+					err := client.Register(directApp)
+					Expect(err).To(MatchError("Error registering app with Eureka: port of the app unknown"))
+					Expect(fakeConn.RegisterInstanceContextCallCount()).To(BeZero())
+				})
+			})
+		})
+
+		Describe(".Register with VIP addresses", func() {
+			BeforeEach(func() {
+				client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+					WithVIPAddress("my-vip"),
+					WithSecureVIPAddress("my-secure-vip"),
+				)
+			})
+
+			It("registers the custom VIPs", func() {
+				Expect(client.Register(app)).To(Succeed())
+				instance := registeredInstance(fakeConn, 0)
+				Expect(instance.VipAddress).To(Equal("my-vip"))
+				Expect(instance.SecureVipAddress).To(Equal("my-secure-vip"))
+			})
+		})
+
+		Describe(".VIP", func() {
+			BeforeEach(func() {
+				fakeConn.GetVIPContextReturns(fargoApp.Instances, nil)
+			})
+
+			It("calls conn.GetVIP with the VIP", func() {
+				client.VIP("my-vip")
+				Expect(fakeConn.GetVIPContextCallCount()).To(Equal(1))
+				_, vip := fakeConn.GetVIPContextArgsForCall(0)
+				Expect(vip).To(Equal("my-vip"))
+			})
+
+			It("returns the instances", func() {
+				instances, err := client.VIP("my-vip")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(instances).To(HaveLen(1))
+				Expect(instances[0].ID).To(Equal(app.Instance.ID))
+			})
+
+			Context("when conn.GetVIP returns an error", func() {
+				BeforeEach(func() {
+					fakeConn.GetVIPContextReturns(nil, errors.New("some-error"))
+				})
+
+				It("returns the error", func() {
+					_, err := client.VIP("my-vip")
+					Expect(err).To(MatchError("Error retrieving VIP 'my-vip' from Eureka: some-error"))
+				})
+			})
+		})
+
+		Describe(".SecureVIP", func() {
+			BeforeEach(func() {
+				fakeConn.GetSVIPContextReturns(fargoApp.Instances, nil)
+			})
+
+			It("calls conn.GetSVIP with the VIP", func() {
+				instances, err := client.SecureVIP("my-svip")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(instances).To(HaveLen(1))
+				_, svip := fakeConn.GetSVIPContextArgsForCall(0)
+				Expect(svip).To(Equal("my-svip"))
+			})
+
+			Context("when conn.GetSVIP returns an error", func() {
+				BeforeEach(func() {
+					fakeConn.GetSVIPContextReturns(nil, errors.New("some-error"))
+				})
+
+				It("returns the error", func() {
+					_, err := client.SecureVIP("my-svip")
+					Expect(err).To(MatchError("Error retrieving secure VIP 'my-svip' from Eureka: some-error"))
+				})
+			})
+		})
+
+		Describe(".Register with lease settings", func() {
+			It("sends the default lease", func() {
+				Expect(client.Register(app)).To(Succeed())
+				leaseInfo := registeredInstance(fakeConn, 0).LeaseInfo
+				Expect(leaseInfo.RenewalIntervalInSecs).To(Equal(int32(30)))
+				Expect(leaseInfo.DurationInSecs).To(Equal(int32(90)))
+			})
+
+			Context("when lease options are given", func() {
+				BeforeEach(func() {
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+						WithLeaseRenewalInterval(5*time.Second),
+						WithLeaseDuration(15*time.Second),
+					)
+				})
+
+				It("sends the configured lease", func() {
+					Expect(client.Register(app)).To(Succeed())
+					leaseInfo := registeredInstance(fakeConn, 0).LeaseInfo
+					Expect(leaseInfo.RenewalIntervalInSecs).To(Equal(int32(5)))
+					Expect(leaseInfo.DurationInSecs).To(Equal(int32(15)))
+				})
+
+				It("exposes the lease settings", func() {
+					Expect(client.LeaseRenewalInterval()).To(Equal(5 * time.Second))
+					Expect(client.LeaseDuration()).To(Equal(15 * time.Second))
+				})
+
+				It("uses the renewal interval for heartbeats", func() {
+					Expect(client.HeartbeatInterval()).To(Equal(5 * time.Second))
+				})
 			})
 		})
 
@@ -179,54 +702,139 @@ var _ = Describe("eureka", func() {
 			It("returns the default interval", func() {
 				Expect(client.HeartbeatInterval()).To(Equal(30 * time.Second))
 			})
+		})
+
+		Describe(".App", func() {
+			It("calls conn.GetApp with the correct app name", func() {
+				client.App("foo")
+				Expect(fakeConn.GetAppContextCallCount()).To(Equal(1))
+				_, name := fakeConn.GetAppContextArgsForCall(0)
+				Expect(name).To(Equal("foo"))
+			})
 
-			Context("when the eureka server requires a different interval", func() {
-				var expectedInterval = 123 * time.Second
+			It("returns the app retrieved from conn.GetApp", func() {
+				result, err := client.App("foo")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.Name).To(Equal(app.Name))
+				Expect(result.Instances).To(Equal([]discovery.Instance{{
+					ID:                app.Instance.ID,
+					App:               strings.ToUpper(app.Name),
+					HostName:          app.URI(),
+					IPAddr:            "10.0.0.1",
+					Port:              80,
+					PortEnabled:       false,
+					SecurePort:        443,
+					SecurePortEnabled: true,
+					Status:            discovery.StatusUp,
+					Zone:              "zone-a",
+					Metadata: map[string]string{
+						"instanceId": app.Instance.ID,
+						"version":    "1.2.3",
+					},
+				}}))
+				Expect(result.Instances[0].BaseURL()).To(Equal("https://" + app.URI()))
+			})
 
+			Context("when Eureka does not know the app", func() {
 				BeforeEach(func() {
-					fakeConn.RegisterInstanceStub = func(i *fargo.Instance) error {
-						i.LeaseInfo = fargo.LeaseInfo{
-							RenewalIntervalInSecs: int32(expectedInterval.Seconds()),
-						}
-						return nil
-					}
+					fakeConn.GetAppContextReturns(nil, &StatusError{Code: http.StatusNotFound})
+				})
+
+				It("reports the app as unknown", func() {
+					_, err := client.App("foo")
+					Expect(err).To(MatchError("Error retrieving app 'foo' from Eureka: Eureka returned status 404"))
+					Expect(discovery.IsUnknownApp(err)).To(BeTrue())
 				})
+			})
 
-				Context("and it is called prior to the first register", func() {
-					It("returns the default interval", func() {
-						Expect(client.HeartbeatInterval()).To(Equal(30 * time.Second))
+			Context("when same-zone instances are preferred", func() {
+				BeforeEach(func() {
+					fargoApp.Instances = append(fargoApp.Instances, &fargo.Instance{
+						HostName: "other-zone-host",
+						App:      strings.ToUpper(app.Name),
+						Status:   fargo.UP,
+						Metadata: fargo.InstanceMetadata{Raw: []byte(`{"zone": "zone-b"}`)},
 					})
+
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval,
+						WithZone("zone-a"),
+						WithPreferSameZone(),
+					)
+				})
+
+				It("returns the instances in the same zone", func() {
+					result, err := client.App("foo")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.Instances).To(HaveLen(1))
+					Expect(result.Instances[0].Zone).To(Equal("zone-a"))
+				})
+
+				It("applies to Apps as well", func() {
+					result, err := client.Apps()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result[fargoApp.Name].Instances).To(HaveLen(1))
 				})
 
-				Context("and it is called after the first register", func() {
+				Context("and none of them is UP", func() {
 					BeforeEach(func() {
-						Expect(client.Register(app)).To(Succeed())
+						fargoApp.Instances[0].Status = fargo.DOWN
 					})
 
-					It("returns the interval set by the server", func() {
-						Expect(client.HeartbeatInterval()).To(Equal(expectedInterval))
+					It("falls back to the other zones", func() {
+						result, err := client.App("foo")
+						Expect(err).ToNot(HaveOccurred())
+						Expect(result.Instances).To(HaveLen(2))
 					})
 				})
 			})
-		})
 
-		Describe(".App", func() {
-			It("calls conn.GetApp with the correct app name", func() {
-				client.App("foo")
-				Expect(fakeConn.GetAppCallCount()).To(Equal(1))
-				Expect(fakeConn.GetAppArgsForCall(0)).To(Equal("foo"))
+			Context("when same-zone instances are not preferred", func() {
+				BeforeEach(func() {
+					fargoApp.Instances = append(fargoApp.Instances, &fargo.Instance{
+						HostName: "other-zone-host",
+						Metadata: fargo.InstanceMetadata{Raw: []byte(`{"zone": "zone-b"}`)},
+					})
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval, WithZone("zone-a"))
+				})
+
+				It("returns all instances", func() {
+					result, err := client.App("foo")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(result.Instances).To(HaveLen(2))
+				})
 			})
 
-			It("returns the app retrieved from conn.GetApp", func() {
-				uris, _ := client.App("foo")
-				Expect(uris).To(Equal([]string{app.URI()}))
+			Context("when the instance carries no instance id", func() {
+				BeforeEach(func() {
+					fargoApp.Instances[0].Metadata = fargo.InstanceMetadata{}
+				})
+
+				It("falls back to the host name", func() {
+					result, _ := client.App("foo")
+					Expect(result.Instances[0].ID).To(Equal(app.URI()))
+					Expect(result.Instances[0].Metadata).To(BeEmpty())
+				})
+			})
+
+			Context("when the ports were only decoded from JSON", func() {
+				BeforeEach(func() {
+					fargoApp.Instances[0].Port = 0
+					fargoApp.Instances[0].PortJ = fargo.Port{Number: "8080", Enabled: "true"}
+					fargoApp.Instances[0].SecurePortJ.Enabled = "false"
+				})
+
+				It("uses the JSON port", func() {
+					result, _ := client.App("foo")
+					Expect(result.Instances[0].Port).To(Equal(8080))
+					Expect(result.Instances[0].BaseURL()).To(Equal("http://" + app.URI() + ":8080"))
+				})
 			})
 
 			Context("when conn.GetApp returns an error", func() {
 				var expectedErr = errors.New("some-error")
 
 				BeforeEach(func() {
-					fakeConn.GetAppReturns(nil, expectedErr)
+					fakeConn.GetAppContextReturns(nil, expectedErr)
 				})
 
 				It("returns the error", func() {
@@ -241,15 +849,16 @@ var _ = Describe("eureka", func() {
 		Describe(".Apps", func() {
 			It("calls conn.GetApps", func() {
 				client.Apps()
-				Expect(fakeConn.GetAppsCallCount()).To(Equal(1))
+				Expect(fakeConn.GetAppsContextCallCount()).To(Equal(1))
 			})
 
 			It("returns the apps retrieved from conn.GetApps", func() {
 				apps, _ := client.Apps()
 				Expect(apps).To(HaveLen(len(fargoApps)))
-				for name, uris := range apps {
+				for name, a := range apps {
 					Expect(name).To(Equal(app.Name))
-					Expect(uris).To(Equal([]string{app.URI()}))
+					Expect(a.HostNames()).To(Equal([]string{app.URI()}))
+					Expect(a.Instances[0].Status).To(Equal(discovery.StatusUp))
 				}
 			})
 
@@ -257,7 +866,7 @@ var _ = Describe("eureka", func() {
 				var expectedErr = errors.New("some-error")
 
 				BeforeEach(func() {
-					fakeConn.GetAppsReturns(nil, expectedErr)
+					fakeConn.GetAppsContextReturns(nil, expectedErr)
 				})
 
 				It("returns the error", func() {
@@ -269,6 +878,92 @@ var _ = Describe("eureka", func() {
 			})
 		})
 
+		Describe(".Cache", func() {
+			Context("when the connection cannot fetch the registry", func() {
+				It("returns an error", func() {
+					_, err := client.Cache()
+					Expect(err).To(MatchError("Error creating registry cache: connection cannot fetch the registry"))
+				})
+			})
+
+			Context("when the connection can fetch the registry", func() {
+				var (
+					source *stubSource
+					cached = discovery.Instance{ID: "cached-id", App: "CACHED", Status: discovery.StatusUp}
+				)
+
+				BeforeEach(func() {
+					source = &stubSource{
+						full:   registryOf("UP_1_", "", cached),
+						deltas: []*registry{registryOf("UP_1_", "")},
+					}
+
+					connProvider = func(*Client) Connection {
+						return &cachingConn{fakeConn, source}
+					}
+					client = NewClient(expectedURIs, expectedPort, expectedTimeout, expectedPollInterval)
+				})
+
+				AfterEach(func() {
+					if cache, err := client.Cache(); err == nil {
+						cache.Stop()
+					}
+				})
+
+				It("starts the cache", func() {
+					cache, err := client.Cache()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(cache.Running()).To(BeTrue())
+					Expect(source.fullCallCount()).To(Equal(1))
+				})
+
+				It("returns the same cache on subsequent calls", func() {
+					first, _ := client.Cache()
+					second, _ := client.Cache()
+					Expect(second).To(Equal(first))
+				})
+
+				It("serves App from memory", func() {
+					client.Cache()
+
+					app, err := client.App("cached")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(app.Instances).To(Equal([]discovery.Instance{cached}))
+					Expect(fakeConn.GetAppContextCallCount()).To(BeZero())
+				})
+
+				It("serves VIP lookups from memory", func() {
+					client.Cache()
+
+					_, err := client.VIP("some-vip")
+					Expect(err).ToNot(HaveOccurred())
+					_, err = client.SecureVIP("some-vip")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(fakeConn.GetVIPContextCallCount()).To(BeZero())
+					Expect(fakeConn.GetSVIPContextCallCount()).To(BeZero())
+				})
+
+				It("serves Apps from memory", func() {
+					client.Cache()
+
+					apps, err := client.Apps()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(apps).To(HaveKey("CACHED"))
+					Expect(fakeConn.GetAppsContextCallCount()).To(BeZero())
+				})
+
+				Context("when the cache has been stopped", func() {
+					It("queries Eureka again", func() {
+						cache, _ := client.Cache()
+						cache.Stop()
+
+						client.App("foo")
+						Expect(fakeConn.GetAppContextCallCount()).To(Equal(1))
+					})
+				})
+			})
+		})
+
 		Describe(".URIs", func() {
 			It("returns the correct URIs", func() {
 				Expect(client.URIs()).To(HaveLen(len(expectedURIs)))
@@ -0,0 +1,766 @@
+// This file was generated by counterfeiter
+package fake
+
+import (
+	"sync"
+
+	"github.com/hudl/fargo"
+	"golang.org/x/net/context"
+)
+
+type Connection struct {
+	RegisterInstanceStub        func(*fargo.Instance) error
+	registerInstanceMutex       sync.RWMutex
+	registerInstanceArgsForCall []struct {
+		arg1 *fargo.Instance
+	}
+	registerInstanceReturns struct {
+		result1 error
+	}
+	DeregisterInstanceStub        func(*fargo.Instance) error
+	deregisterInstanceMutex       sync.RWMutex
+	deregisterInstanceArgsForCall []struct {
+		arg1 *fargo.Instance
+	}
+	deregisterInstanceReturns struct {
+		result1 error
+	}
+	HeartBeatInstanceStub        func(*fargo.Instance) error
+	heartBeatInstanceMutex       sync.RWMutex
+	heartBeatInstanceArgsForCall []struct {
+		arg1 *fargo.Instance
+	}
+	heartBeatInstanceReturns struct {
+		result1 error
+	}
+	GetAppStub        func(string) (*fargo.Application, error)
+	getAppMutex       sync.RWMutex
+	getAppArgsForCall []struct {
+		arg1 string
+	}
+	getAppReturns struct {
+		result1 *fargo.Application
+		result2 error
+	}
+	GetAppsStub        func() (map[string]*fargo.Application, error)
+	getAppsMutex       sync.RWMutex
+	getAppsArgsForCall []struct{}
+	getAppsReturns     struct {
+		result1 map[string]*fargo.Application
+		result2 error
+	}
+	AddMetadataStringStub        func(*fargo.Instance, string, string) error
+	addMetadataStringMutex       sync.RWMutex
+	addMetadataStringArgsForCall []struct {
+		arg1 *fargo.Instance
+		arg2 string
+		arg3 string
+	}
+	addMetadataStringReturns struct {
+		result1 error
+	}
+	UpdateInstanceStatusStub        func(*fargo.Instance, fargo.StatusType) error
+	updateInstanceStatusMutex       sync.RWMutex
+	updateInstanceStatusArgsForCall []struct {
+		arg1 *fargo.Instance
+		arg2 fargo.StatusType
+	}
+	updateInstanceStatusReturns struct {
+		result1 error
+	}
+	GetVIPStub        func(string) ([]*fargo.Instance, error)
+	getVIPMutex       sync.RWMutex
+	getVIPArgsForCall []struct {
+		arg1 string
+	}
+	getVIPReturns struct {
+		result1 []*fargo.Instance
+		result2 error
+	}
+	GetSVIPStub        func(string) ([]*fargo.Instance, error)
+	getSVIPMutex       sync.RWMutex
+	getSVIPArgsForCall []struct {
+		arg1 string
+	}
+	getSVIPReturns struct {
+		result1 []*fargo.Instance
+		result2 error
+	}
+	RegisterInstanceContextStub        func(context.Context, *fargo.Instance) error
+	registerInstanceContextMutex       sync.RWMutex
+	registerInstanceContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+	}
+	registerInstanceContextReturns struct {
+		result1 error
+	}
+	DeregisterInstanceContextStub        func(context.Context, *fargo.Instance) error
+	deregisterInstanceContextMutex       sync.RWMutex
+	deregisterInstanceContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+	}
+	deregisterInstanceContextReturns struct {
+		result1 error
+	}
+	HeartBeatInstanceContextStub        func(context.Context, *fargo.Instance) error
+	heartBeatInstanceContextMutex       sync.RWMutex
+	heartBeatInstanceContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+	}
+	heartBeatInstanceContextReturns struct {
+		result1 error
+	}
+	GetAppContextStub        func(context.Context, string) (*fargo.Application, error)
+	getAppContextMutex       sync.RWMutex
+	getAppContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getAppContextReturns struct {
+		result1 *fargo.Application
+		result2 error
+	}
+	GetAppsContextStub        func(context.Context) (map[string]*fargo.Application, error)
+	getAppsContextMutex       sync.RWMutex
+	getAppsContextArgsForCall []struct {
+		arg1 context.Context
+	}
+	getAppsContextReturns struct {
+		result1 map[string]*fargo.Application
+		result2 error
+	}
+	AddMetadataStringContextStub        func(context.Context, *fargo.Instance, string, string) error
+	addMetadataStringContextMutex       sync.RWMutex
+	addMetadataStringContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+		arg3 string
+		arg4 string
+	}
+	addMetadataStringContextReturns struct {
+		result1 error
+	}
+	UpdateInstanceStatusContextStub        func(context.Context, *fargo.Instance, fargo.StatusType) error
+	updateInstanceStatusContextMutex       sync.RWMutex
+	updateInstanceStatusContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+		arg3 fargo.StatusType
+	}
+	updateInstanceStatusContextReturns struct {
+		result1 error
+	}
+	GetVIPContextStub        func(context.Context, string) ([]*fargo.Instance, error)
+	getVIPContextMutex       sync.RWMutex
+	getVIPContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getVIPContextReturns struct {
+		result1 []*fargo.Instance
+		result2 error
+	}
+	GetSVIPContextStub        func(context.Context, string) ([]*fargo.Instance, error)
+	getSVIPContextMutex       sync.RWMutex
+	getSVIPContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getSVIPContextReturns struct {
+		result1 []*fargo.Instance
+		result2 error
+	}
+}
+
+func (fake *Connection) RegisterInstance(arg1 *fargo.Instance) error {
+	fake.registerInstanceMutex.Lock()
+	fake.registerInstanceArgsForCall = append(fake.registerInstanceArgsForCall, struct {
+		arg1 *fargo.Instance
+	}{arg1})
+	fake.registerInstanceMutex.Unlock()
+	if fake.RegisterInstanceStub != nil {
+		return fake.RegisterInstanceStub(arg1)
+	} else {
+		return fake.registerInstanceReturns.result1
+	}
+}
+
+func (fake *Connection) RegisterInstanceCallCount() int {
+	fake.registerInstanceMutex.RLock()
+	defer fake.registerInstanceMutex.RUnlock()
+	return len(fake.registerInstanceArgsForCall)
+}
+
+func (fake *Connection) RegisterInstanceArgsForCall(i int) *fargo.Instance {
+	fake.registerInstanceMutex.RLock()
+	defer fake.registerInstanceMutex.RUnlock()
+	return fake.registerInstanceArgsForCall[i].arg1
+}
+
+func (fake *Connection) RegisterInstanceReturns(result1 error) {
+	fake.RegisterInstanceStub = nil
+	fake.registerInstanceReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) DeregisterInstance(arg1 *fargo.Instance) error {
+	fake.deregisterInstanceMutex.Lock()
+	fake.deregisterInstanceArgsForCall = append(fake.deregisterInstanceArgsForCall, struct {
+		arg1 *fargo.Instance
+	}{arg1})
+	fake.deregisterInstanceMutex.Unlock()
+	if fake.DeregisterInstanceStub != nil {
+		return fake.DeregisterInstanceStub(arg1)
+	} else {
+		return fake.deregisterInstanceReturns.result1
+	}
+}
+
+func (fake *Connection) DeregisterInstanceCallCount() int {
+	fake.deregisterInstanceMutex.RLock()
+	defer fake.deregisterInstanceMutex.RUnlock()
+	return len(fake.deregisterInstanceArgsForCall)
+}
+
+func (fake *Connection) DeregisterInstanceArgsForCall(i int) *fargo.Instance {
+	fake.deregisterInstanceMutex.RLock()
+	defer fake.deregisterInstanceMutex.RUnlock()
+	return fake.deregisterInstanceArgsForCall[i].arg1
+}
+
+func (fake *Connection) DeregisterInstanceReturns(result1 error) {
+	fake.DeregisterInstanceStub = nil
+	fake.deregisterInstanceReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) HeartBeatInstance(arg1 *fargo.Instance) error {
+	fake.heartBeatInstanceMutex.Lock()
+	fake.heartBeatInstanceArgsForCall = append(fake.heartBeatInstanceArgsForCall, struct {
+		arg1 *fargo.Instance
+	}{arg1})
+	fake.heartBeatInstanceMutex.Unlock()
+	if fake.HeartBeatInstanceStub != nil {
+		return fake.HeartBeatInstanceStub(arg1)
+	} else {
+		return fake.heartBeatInstanceReturns.result1
+	}
+}
+
+func (fake *Connection) HeartBeatInstanceCallCount() int {
+	fake.heartBeatInstanceMutex.RLock()
+	defer fake.heartBeatInstanceMutex.RUnlock()
+	return len(fake.heartBeatInstanceArgsForCall)
+}
+
+func (fake *Connection) HeartBeatInstanceArgsForCall(i int) *fargo.Instance {
+	fake.heartBeatInstanceMutex.RLock()
+	defer fake.heartBeatInstanceMutex.RUnlock()
+	return fake.heartBeatInstanceArgsForCall[i].arg1
+}
+
+func (fake *Connection) HeartBeatInstanceReturns(result1 error) {
+	fake.HeartBeatInstanceStub = nil
+	fake.heartBeatInstanceReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) GetApp(arg1 string) (*fargo.Application, error) {
+	fake.getAppMutex.Lock()
+	fake.getAppArgsForCall = append(fake.getAppArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.getAppMutex.Unlock()
+	if fake.GetAppStub != nil {
+		return fake.GetAppStub(arg1)
+	} else {
+		return fake.getAppReturns.result1, fake.getAppReturns.result2
+	}
+}
+
+func (fake *Connection) GetAppCallCount() int {
+	fake.getAppMutex.RLock()
+	defer fake.getAppMutex.RUnlock()
+	return len(fake.getAppArgsForCall)
+}
+
+func (fake *Connection) GetAppArgsForCall(i int) string {
+	fake.getAppMutex.RLock()
+	defer fake.getAppMutex.RUnlock()
+	return fake.getAppArgsForCall[i].arg1
+}
+
+func (fake *Connection) GetAppReturns(result1 *fargo.Application, result2 error) {
+	fake.GetAppStub = nil
+	fake.getAppReturns = struct {
+		result1 *fargo.Application
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Connection) GetApps() (map[string]*fargo.Application, error) {
+	fake.getAppsMutex.Lock()
+	fake.getAppsArgsForCall = append(fake.getAppsArgsForCall, struct{}{})
+	fake.getAppsMutex.Unlock()
+	if fake.GetAppsStub != nil {
+		return fake.GetAppsStub()
+	} else {
+		return fake.getAppsReturns.result1, fake.getAppsReturns.result2
+	}
+}
+
+func (fake *Connection) GetAppsCallCount() int {
+	fake.getAppsMutex.RLock()
+	defer fake.getAppsMutex.RUnlock()
+	return len(fake.getAppsArgsForCall)
+}
+
+func (fake *Connection) GetAppsReturns(result1 map[string]*fargo.Application, result2 error) {
+	fake.GetAppsStub = nil
+	fake.getAppsReturns = struct {
+		result1 map[string]*fargo.Application
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Connection) AddMetadataString(arg1 *fargo.Instance, arg2 string, arg3 string) error {
+	fake.addMetadataStringMutex.Lock()
+	fake.addMetadataStringArgsForCall = append(fake.addMetadataStringArgsForCall, struct {
+		arg1 *fargo.Instance
+		arg2 string
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.addMetadataStringMutex.Unlock()
+	if fake.AddMetadataStringStub != nil {
+		return fake.AddMetadataStringStub(arg1, arg2, arg3)
+	} else {
+		return fake.addMetadataStringReturns.result1
+	}
+}
+
+func (fake *Connection) AddMetadataStringCallCount() int {
+	fake.addMetadataStringMutex.RLock()
+	defer fake.addMetadataStringMutex.RUnlock()
+	return len(fake.addMetadataStringArgsForCall)
+}
+
+func (fake *Connection) AddMetadataStringArgsForCall(i int) (*fargo.Instance, string, string) {
+	fake.addMetadataStringMutex.RLock()
+	defer fake.addMetadataStringMutex.RUnlock()
+	return fake.addMetadataStringArgsForCall[i].arg1, fake.addMetadataStringArgsForCall[i].arg2, fake.addMetadataStringArgsForCall[i].arg3
+}
+
+func (fake *Connection) AddMetadataStringReturns(result1 error) {
+	fake.AddMetadataStringStub = nil
+	fake.addMetadataStringReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) UpdateInstanceStatus(arg1 *fargo.Instance, arg2 fargo.StatusType) error {
+	fake.updateInstanceStatusMutex.Lock()
+	fake.updateInstanceStatusArgsForCall = append(fake.updateInstanceStatusArgsForCall, struct {
+		arg1 *fargo.Instance
+		arg2 fargo.StatusType
+	}{arg1, arg2})
+	fake.updateInstanceStatusMutex.Unlock()
+	if fake.UpdateInstanceStatusStub != nil {
+		return fake.UpdateInstanceStatusStub(arg1, arg2)
+	} else {
+		return fake.updateInstanceStatusReturns.result1
+	}
+}
+
+func (fake *Connection) UpdateInstanceStatusCallCount() int {
+	fake.updateInstanceStatusMutex.RLock()
+	defer fake.updateInstanceStatusMutex.RUnlock()
+	return len(fake.updateInstanceStatusArgsForCall)
+}
+
+func (fake *Connection) UpdateInstanceStatusArgsForCall(i int) (*fargo.Instance, fargo.StatusType) {
+	fake.updateInstanceStatusMutex.RLock()
+	defer fake.updateInstanceStatusMutex.RUnlock()
+	return fake.updateInstanceStatusArgsForCall[i].arg1, fake.updateInstanceStatusArgsForCall[i].arg2
+}
+
+func (fake *Connection) UpdateInstanceStatusReturns(result1 error) {
+	fake.UpdateInstanceStatusStub = nil
+	fake.updateInstanceStatusReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) GetVIP(arg1 string) ([]*fargo.Instance, error) {
+	fake.getVIPMutex.Lock()
+	fake.getVIPArgsForCall = append(fake.getVIPArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.getVIPMutex.Unlock()
+	if fake.GetVIPStub != nil {
+		return fake.GetVIPStub(arg1)
+	} else {
+		return fake.getVIPReturns.result1, fake.getVIPReturns.result2
+	}
+}
+
+func (fake *Connection) GetVIPCallCount() int {
+	fake.getVIPMutex.RLock()
+	defer fake.getVIPMutex.RUnlock()
+	return len(fake.getVIPArgsForCall)
+}
+
+func (fake *Connection) GetVIPArgsForCall(i int) string {
+	fake.getVIPMutex.RLock()
+	defer fake.getVIPMutex.RUnlock()
+	return fake.getVIPArgsForCall[i].arg1
+}
+
+func (fake *Connection) GetVIPReturns(result1 []*fargo.Instance, result2 error) {
+	fake.GetVIPStub = nil
+	fake.getVIPReturns = struct {
+		result1 []*fargo.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Connection) GetSVIP(arg1 string) ([]*fargo.Instance, error) {
+	fake.getSVIPMutex.Lock()
+	fake.getSVIPArgsForCall = append(fake.getSVIPArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.getSVIPMutex.Unlock()
+	if fake.GetSVIPStub != nil {
+		return fake.GetSVIPStub(arg1)
+	} else {
+		return fake.getSVIPReturns.result1, fake.getSVIPReturns.result2
+	}
+}
+
+func (fake *Connection) GetSVIPCallCount() int {
+	fake.getSVIPMutex.RLock()
+	defer fake.getSVIPMutex.RUnlock()
+	return len(fake.getSVIPArgsForCall)
+}
+
+func (fake *Connection) GetSVIPArgsForCall(i int) string {
+	fake.getSVIPMutex.RLock()
+	defer fake.getSVIPMutex.RUnlock()
+	return fake.getSVIPArgsForCall[i].arg1
+}
+
+func (fake *Connection) GetSVIPReturns(result1 []*fargo.Instance, result2 error) {
+	fake.GetSVIPStub = nil
+	fake.getSVIPReturns = struct {
+		result1 []*fargo.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Connection) RegisterInstanceContext(arg1 context.Context, arg2 *fargo.Instance) error {
+	fake.registerInstanceContextMutex.Lock()
+	fake.registerInstanceContextArgsForCall = append(fake.registerInstanceContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+	}{arg1, arg2})
+	fake.registerInstanceContextMutex.Unlock()
+	if fake.RegisterInstanceContextStub != nil {
+		return fake.RegisterInstanceContextStub(arg1, arg2)
+	} else {
+		return fake.registerInstanceContextReturns.result1
+	}
+}
+
+func (fake *Connection) RegisterInstanceContextCallCount() int {
+	fake.registerInstanceContextMutex.RLock()
+	defer fake.registerInstanceContextMutex.RUnlock()
+	return len(fake.registerInstanceContextArgsForCall)
+}
+
+func (fake *Connection) RegisterInstanceContextArgsForCall(i int) (context.Context, *fargo.Instance) {
+	fake.registerInstanceContextMutex.RLock()
+	defer fake.registerInstanceContextMutex.RUnlock()
+	return fake.registerInstanceContextArgsForCall[i].arg1, fake.registerInstanceContextArgsForCall[i].arg2
+}
+
+func (fake *Connection) RegisterInstanceContextReturns(result1 error) {
+	fake.RegisterInstanceContextStub = nil
+	fake.registerInstanceContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) DeregisterInstanceContext(arg1 context.Context, arg2 *fargo.Instance) error {
+	fake.deregisterInstanceContextMutex.Lock()
+	fake.deregisterInstanceContextArgsForCall = append(fake.deregisterInstanceContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+	}{arg1, arg2})
+	fake.deregisterInstanceContextMutex.Unlock()
+	if fake.DeregisterInstanceContextStub != nil {
+		return fake.DeregisterInstanceContextStub(arg1, arg2)
+	} else {
+		return fake.deregisterInstanceContextReturns.result1
+	}
+}
+
+func (fake *Connection) DeregisterInstanceContextCallCount() int {
+	fake.deregisterInstanceContextMutex.RLock()
+	defer fake.deregisterInstanceContextMutex.RUnlock()
+	return len(fake.deregisterInstanceContextArgsForCall)
+}
+
+func (fake *Connection) DeregisterInstanceContextArgsForCall(i int) (context.Context, *fargo.Instance) {
+	fake.deregisterInstanceContextMutex.RLock()
+	defer fake.deregisterInstanceContextMutex.RUnlock()
+	return fake.deregisterInstanceContextArgsForCall[i].arg1, fake.deregisterInstanceContextArgsForCall[i].arg2
+}
+
+func (fake *Connection) DeregisterInstanceContextReturns(result1 error) {
+	fake.DeregisterInstanceContextStub = nil
+	fake.deregisterInstanceContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) HeartBeatInstanceContext(arg1 context.Context, arg2 *fargo.Instance) error {
+	fake.heartBeatInstanceContextMutex.Lock()
+	fake.heartBeatInstanceContextArgsForCall = append(fake.heartBeatInstanceContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+	}{arg1, arg2})
+	fake.heartBeatInstanceContextMutex.Unlock()
+	if fake.HeartBeatInstanceContextStub != nil {
+		return fake.HeartBeatInstanceContextStub(arg1, arg2)
+	} else {
+		return fake.heartBeatInstanceContextReturns.result1
+	}
+}
+
+func (fake *Connection) HeartBeatInstanceContextCallCount() int {
+	fake.heartBeatInstanceContextMutex.RLock()
+	defer fake.heartBeatInstanceContextMutex.RUnlock()
+	return len(fake.heartBeatInstanceContextArgsForCall)
+}
+
+func (fake *Connection) HeartBeatInstanceContextArgsForCall(i int) (context.Context, *fargo.Instance) {
+	fake.heartBeatInstanceContextMutex.RLock()
+	defer fake.heartBeatInstanceContextMutex.RUnlock()
+	return fake.heartBeatInstanceContextArgsForCall[i].arg1, fake.heartBeatInstanceContextArgsForCall[i].arg2
+}
+
+func (fake *Connection) HeartBeatInstanceContextReturns(result1 error) {
+	fake.HeartBeatInstanceContextStub = nil
+	fake.heartBeatInstanceContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) GetAppContext(arg1 context.Context, arg2 string) (*fargo.Application, error) {
+	fake.getAppContextMutex.Lock()
+	fake.getAppContextArgsForCall = append(fake.getAppContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.getAppContextMutex.Unlock()
+	if fake.GetAppContextStub != nil {
+		return fake.GetAppContextStub(arg1, arg2)
+	} else {
+		return fake.getAppContextReturns.result1, fake.getAppContextReturns.result2
+	}
+}
+
+func (fake *Connection) GetAppContextCallCount() int {
+	fake.getAppContextMutex.RLock()
+	defer fake.getAppContextMutex.RUnlock()
+	return len(fake.getAppContextArgsForCall)
+}
+
+func (fake *Connection) GetAppContextArgsForCall(i int) (context.Context, string) {
+	fake.getAppContextMutex.RLock()
+	defer fake.getAppContextMutex.RUnlock()
+	return fake.getAppContextArgsForCall[i].arg1, fake.getAppContextArgsForCall[i].arg2
+}
+
+func (fake *Connection) GetAppContextReturns(result1 *fargo.Application, result2 error) {
+	fake.GetAppContextStub = nil
+	fake.getAppContextReturns = struct {
+		result1 *fargo.Application
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Connection) GetAppsContext(arg1 context.Context) (map[string]*fargo.Application, error) {
+	fake.getAppsContextMutex.Lock()
+	fake.getAppsContextArgsForCall = append(fake.getAppsContextArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.getAppsContextMutex.Unlock()
+	if fake.GetAppsContextStub != nil {
+		return fake.GetAppsContextStub(arg1)
+	} else {
+		return fake.getAppsContextReturns.result1, fake.getAppsContextReturns.result2
+	}
+}
+
+func (fake *Connection) GetAppsContextCallCount() int {
+	fake.getAppsContextMutex.RLock()
+	defer fake.getAppsContextMutex.RUnlock()
+	return len(fake.getAppsContextArgsForCall)
+}
+
+func (fake *Connection) GetAppsContextArgsForCall(i int) context.Context {
+	fake.getAppsContextMutex.RLock()
+	defer fake.getAppsContextMutex.RUnlock()
+	return fake.getAppsContextArgsForCall[i].arg1
+}
+
+func (fake *Connection) GetAppsContextReturns(result1 map[string]*fargo.Application, result2 error) {
+	fake.GetAppsContextStub = nil
+	fake.getAppsContextReturns = struct {
+		result1 map[string]*fargo.Application
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Connection) AddMetadataStringContext(arg1 context.Context, arg2 *fargo.Instance, arg3 string, arg4 string) error {
+	fake.addMetadataStringContextMutex.Lock()
+	fake.addMetadataStringContextArgsForCall = append(fake.addMetadataStringContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+		arg3 string
+		arg4 string
+	}{arg1, arg2, arg3, arg4})
+	fake.addMetadataStringContextMutex.Unlock()
+	if fake.AddMetadataStringContextStub != nil {
+		return fake.AddMetadataStringContextStub(arg1, arg2, arg3, arg4)
+	} else {
+		return fake.addMetadataStringContextReturns.result1
+	}
+}
+
+func (fake *Connection) AddMetadataStringContextCallCount() int {
+	fake.addMetadataStringContextMutex.RLock()
+	defer fake.addMetadataStringContextMutex.RUnlock()
+	return len(fake.addMetadataStringContextArgsForCall)
+}
+
+func (fake *Connection) AddMetadataStringContextArgsForCall(i int) (context.Context, *fargo.Instance, string, string) {
+	fake.addMetadataStringContextMutex.RLock()
+	defer fake.addMetadataStringContextMutex.RUnlock()
+	return fake.addMetadataStringContextArgsForCall[i].arg1, fake.addMetadataStringContextArgsForCall[i].arg2, fake.addMetadataStringContextArgsForCall[i].arg3, fake.addMetadataStringContextArgsForCall[i].arg4
+}
+
+func (fake *Connection) AddMetadataStringContextReturns(result1 error) {
+	fake.AddMetadataStringContextStub = nil
+	fake.addMetadataStringContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) UpdateInstanceStatusContext(arg1 context.Context, arg2 *fargo.Instance, arg3 fargo.StatusType) error {
+	fake.updateInstanceStatusContextMutex.Lock()
+	fake.updateInstanceStatusContextArgsForCall = append(fake.updateInstanceStatusContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 *fargo.Instance
+		arg3 fargo.StatusType
+	}{arg1, arg2, arg3})
+	fake.updateInstanceStatusContextMutex.Unlock()
+	if fake.UpdateInstanceStatusContextStub != nil {
+		return fake.UpdateInstanceStatusContextStub(arg1, arg2, arg3)
+	} else {
+		return fake.updateInstanceStatusContextReturns.result1
+	}
+}
+
+func (fake *Connection) UpdateInstanceStatusContextCallCount() int {
+	fake.updateInstanceStatusContextMutex.RLock()
+	defer fake.updateInstanceStatusContextMutex.RUnlock()
+	return len(fake.updateInstanceStatusContextArgsForCall)
+}
+
+func (fake *Connection) UpdateInstanceStatusContextArgsForCall(i int) (context.Context, *fargo.Instance, fargo.StatusType) {
+	fake.updateInstanceStatusContextMutex.RLock()
+	defer fake.updateInstanceStatusContextMutex.RUnlock()
+	return fake.updateInstanceStatusContextArgsForCall[i].arg1, fake.updateInstanceStatusContextArgsForCall[i].arg2, fake.updateInstanceStatusContextArgsForCall[i].arg3
+}
+
+func (fake *Connection) UpdateInstanceStatusContextReturns(result1 error) {
+	fake.UpdateInstanceStatusContextStub = nil
+	fake.updateInstanceStatusContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Connection) GetVIPContext(arg1 context.Context, arg2 string) ([]*fargo.Instance, error) {
+	fake.getVIPContextMutex.Lock()
+	fake.getVIPContextArgsForCall = append(fake.getVIPContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.getVIPContextMutex.Unlock()
+	if fake.GetVIPContextStub != nil {
+		return fake.GetVIPContextStub(arg1, arg2)
+	} else {
+		return fake.getVIPContextReturns.result1, fake.getVIPContextReturns.result2
+	}
+}
+
+func (fake *Connection) GetVIPContextCallCount() int {
+	fake.getVIPContextMutex.RLock()
+	defer fake.getVIPContextMutex.RUnlock()
+	return len(fake.getVIPContextArgsForCall)
+}
+
+func (fake *Connection) GetVIPContextArgsForCall(i int) (context.Context, string) {
+	fake.getVIPContextMutex.RLock()
+	defer fake.getVIPContextMutex.RUnlock()
+	return fake.getVIPContextArgsForCall[i].arg1, fake.getVIPContextArgsForCall[i].arg2
+}
+
+func (fake *Connection) GetVIPContextReturns(result1 []*fargo.Instance, result2 error) {
+	fake.GetVIPContextStub = nil
+	fake.getVIPContextReturns = struct {
+		result1 []*fargo.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Connection) GetSVIPContext(arg1 context.Context, arg2 string) ([]*fargo.Instance, error) {
+	fake.getSVIPContextMutex.Lock()
+	fake.getSVIPContextArgsForCall = append(fake.getSVIPContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.getSVIPContextMutex.Unlock()
+	if fake.GetSVIPContextStub != nil {
+		return fake.GetSVIPContextStub(arg1, arg2)
+	} else {
+		return fake.getSVIPContextReturns.result1, fake.getSVIPContextReturns.result2
+	}
+}
+
+func (fake *Connection) GetSVIPContextCallCount() int {
+	fake.getSVIPContextMutex.RLock()
+	defer fake.getSVIPContextMutex.RUnlock()
+	return len(fake.getSVIPContextArgsForCall)
+}
+
+func (fake *Connection) GetSVIPContextArgsForCall(i int) (context.Context, string) {
+	fake.getSVIPContextMutex.RLock()
+	defer fake.getSVIPContextMutex.RUnlock()
+	return fake.getSVIPContextArgsForCall[i].arg1, fake.getSVIPContextArgsForCall[i].arg2
+}
+
+func (fake *Connection) GetSVIPContextReturns(result1 []*fargo.Instance, result2 error) {
+	fake.GetSVIPContextStub = nil
+	fake.getSVIPContextReturns = struct {
+		result1 []*fargo.Instance
+		result2 error
+	}{result1, result2}
+}
@@ -0,0 +1,136 @@
+package eureka
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultQuarantine is how long a failing Eureka peer is skipped.
+var DefaultQuarantine = 30 * time.Second
+
+var peerNow = time.Now
+
+// PeerHealth describes the state of a single Eureka peer.
+type PeerHealth struct {
+	URI              string
+	Zone             string
+	Healthy          bool
+	Failures         int
+	LastError        error
+	QuarantinedUntil time.Time
+}
+
+type peer struct {
+	uri       string
+	zone      string
+	failures  int
+	lastError error
+	until     time.Time
+}
+
+// peers keeps track of failing Eureka peers and the order to try them in.
+type peers struct {
+	mutex      sync.Mutex
+	list       []*peer
+	zone       string
+	quarantine time.Duration
+}
+
+func newPeers(uris []string, zones map[string]string, zone string, quarantine time.Duration) *peers {
+	p := &peers{zone: zone, quarantine: quarantine}
+	for _, uri := range uris {
+		p.list = append(p.list, &peer{uri: uri, zone: zones[uri]})
+	}
+	return p
+}
+
+// ordered returns the healthy peers in configured order, those in the local
+// zone first. If all peers are quarantined they are returned anyway, the one
+// to be released first leading.
+func (p *peers) ordered() []*peer {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := peerNow()
+
+	healthy := []*peer{}
+	for _, zoneLocal := range []bool{true, false} {
+		for _, candidate := range p.list {
+			if p.local(candidate) == zoneLocal && !now.Before(candidate.until) {
+				healthy = append(healthy, candidate)
+			}
+		}
+	}
+
+	if len(healthy) > 0 {
+		return healthy
+	}
+
+	quarantined := make([]*peer, len(p.list))
+	copy(quarantined, p.list)
+	sort.Stable(byRelease(quarantined))
+	return quarantined
+}
+
+func (p *peers) local(candidate *peer) bool {
+	return p.zone != "" && candidate.zone == p.zone
+}
+
+func (p *peers) failed(failed *peer, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	failed.failures++
+	failed.lastError = err
+	failed.until = peerNow().Add(p.quarantine)
+}
+
+func (p *peers) succeeded(succeeded *peer) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	succeeded.failures = 0
+	succeeded.lastError = nil
+	succeeded.until = time.Time{}
+}
+
+func (p *peers) health() []PeerHealth {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := peerNow()
+
+	result := make([]PeerHealth, len(p.list))
+	for i, peer := range p.list {
+		result[i] = PeerHealth{
+			URI:              redact(peer.uri),
+			Zone:             peer.zone,
+			Healthy:          !now.Before(peer.until),
+			Failures:         peer.failures,
+			LastError:        peer.lastError,
+			QuarantinedUntil: peer.until,
+		}
+	}
+	return result
+}
+
+// redact hides the password in a peer URI.
+func redact(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.User == nil {
+		return uri
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+type byRelease []*peer
+
+func (b byRelease) Len() int           { return len(b) }
+func (b byRelease) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byRelease) Less(i, j int) bool { return b[i].until.Before(b[j].until) }
@@ -0,0 +1,130 @@
+package eureka
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("peers", func() {
+	var (
+		p       *peers
+		now     time.Time
+		origNow = peerNow
+
+		uris = []string{"uri-1", "uri-2", "uri-3"}
+	)
+
+	BeforeEach(func() {
+		now = time.Unix(1000, 0)
+		peerNow = func() time.Time {
+			return now
+		}
+
+		p = newPeers(uris, map[string]string{}, "", time.Minute)
+	})
+
+	AfterEach(func() {
+		peerNow = origNow
+	})
+
+	peerURIs := func(list []*peer) []string {
+		result := make([]string, len(list))
+		for i, peer := range list {
+			result[i] = peer.uri
+		}
+		return result
+	}
+
+	Describe(".ordered", func() {
+		It("returns the peers in the configured order", func() {
+			Expect(peerURIs(p.ordered())).To(Equal(uris))
+		})
+
+		Context("when a peer failed", func() {
+			BeforeEach(func() {
+				p.failed(p.list[0], errors.New("some-error"))
+			})
+
+			It("skips it", func() {
+				Expect(peerURIs(p.ordered())).To(Equal([]string{"uri-2", "uri-3"}))
+			})
+
+			It("tries it again after the quarantine", func() {
+				now = now.Add(time.Minute)
+				Expect(peerURIs(p.ordered())).To(Equal(uris))
+			})
+
+			It("tries it again once it succeeded", func() {
+				p.succeeded(p.list[0])
+				Expect(peerURIs(p.ordered())).To(Equal(uris))
+			})
+		})
+
+		Context("when all peers failed", func() {
+			BeforeEach(func() {
+				p.failed(p.list[1], errors.New("some-error"))
+				now = now.Add(time.Second)
+				p.failed(p.list[2], errors.New("some-error"))
+				now = now.Add(time.Second)
+				p.failed(p.list[0], errors.New("some-error"))
+			})
+
+			It("returns them anyway, the first to be released leading", func() {
+				Expect(peerURIs(p.ordered())).To(Equal([]string{"uri-2", "uri-3", "uri-1"}))
+			})
+		})
+
+		Context("when the local zone is known", func() {
+			BeforeEach(func() {
+				zones := map[string]string{"uri-1": "zone-a", "uri-2": "zone-b", "uri-3": "zone-b"}
+				p = newPeers(uris, zones, "zone-b", time.Minute)
+			})
+
+			It("prefers peers in the same zone", func() {
+				Expect(peerURIs(p.ordered())).To(Equal([]string{"uri-2", "uri-3", "uri-1"}))
+			})
+
+			It("falls back to other zones", func() {
+				p.failed(p.list[1], errors.New("some-error"))
+				p.failed(p.list[2], errors.New("some-error"))
+				Expect(peerURIs(p.ordered())).To(Equal([]string{"uri-1"}))
+			})
+		})
+	})
+
+	Describe(".health", func() {
+		It("reports all peers as healthy initially", func() {
+			for _, h := range p.health() {
+				Expect(h.Healthy).To(BeTrue())
+				Expect(h.Failures).To(BeZero())
+				Expect(h.LastError).To(BeNil())
+			}
+		})
+
+		It("reports failing peers", func() {
+			err := errors.New("some-error")
+			p.failed(p.list[1], err)
+			p.failed(p.list[1], err)
+
+			h := p.health()[1]
+			Expect(h.URI).To(Equal("uri-2"))
+			Expect(h.Healthy).To(BeFalse())
+			Expect(h.Failures).To(Equal(2))
+			Expect(h.LastError).To(Equal(err))
+			Expect(h.QuarantinedUntil).To(Equal(now.Add(time.Minute)))
+		})
+
+		It("resets the failures on success", func() {
+			p.failed(p.list[1], errors.New("some-error"))
+			p.succeeded(p.list[1])
+
+			h := p.health()[1]
+			Expect(h.Healthy).To(BeTrue())
+			Expect(h.Failures).To(BeZero())
+			Expect(h.LastError).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,10 @@
+package discovery
+
+// The specs live in package discovery_test since discovery/fake depends on
+// this package. Expose the package state they need to override.
+var (
+	AppProvider = &appProvider
+	Default     = currentRegistrar
+
+	Jitter = jitter
+)
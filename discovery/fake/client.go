@@ -5,7 +5,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/st3v/cfkit/discovery"
 	"github.com/st3v/cfkit/env"
+	"golang.org/x/net/context"
 )
 
 type Client struct {
@@ -33,26 +35,129 @@ type Client struct {
 	heartbeatReturns struct {
 		result1 error
 	}
+	SetStatusStub        func(app env.App, status discovery.Status) error
+	setStatusMutex       sync.RWMutex
+	setStatusArgsForCall []struct {
+		app    env.App
+		status discovery.Status
+	}
+	setStatusReturns struct {
+		result1 error
+	}
 	HeartbeatIntervalStub        func() time.Duration
 	heartbeatIntervalMutex       sync.RWMutex
 	heartbeatIntervalArgsForCall []struct{}
 	heartbeatIntervalReturns     struct {
 		result1 time.Duration
 	}
-	AppsStub        func() (map[string][]string, error)
+	AppsStub        func() (map[string]discovery.Application, error)
 	appsMutex       sync.RWMutex
 	appsArgsForCall []struct{}
 	appsReturns     struct {
-		result1 map[string][]string
+		result1 map[string]discovery.Application
 		result2 error
 	}
-	AppStub        func(name string) ([]string, error)
+	AppStub        func(name string) (discovery.Application, error)
 	appMutex       sync.RWMutex
 	appArgsForCall []struct {
 		name string
 	}
 	appReturns struct {
-		result1 []string
+		result1 discovery.Application
+		result2 error
+	}
+	VIPStub        func(vip string) ([]discovery.Instance, error)
+	vIPMutex       sync.RWMutex
+	vIPArgsForCall []struct {
+		vip string
+	}
+	vIPReturns struct {
+		result1 []discovery.Instance
+		result2 error
+	}
+	SecureVIPStub        func(vip string) ([]discovery.Instance, error)
+	secureVIPMutex       sync.RWMutex
+	secureVIPArgsForCall []struct {
+		vip string
+	}
+	secureVIPReturns struct {
+		result1 []discovery.Instance
+		result2 error
+	}
+	RegisterContextStub        func(ctx context.Context, app env.App) error
+	registerContextMutex       sync.RWMutex
+	registerContextArgsForCall []struct {
+		ctx context.Context
+		app env.App
+	}
+	registerContextReturns struct {
+		result1 error
+	}
+	DeregisterContextStub        func(ctx context.Context, app env.App) error
+	deregisterContextMutex       sync.RWMutex
+	deregisterContextArgsForCall []struct {
+		ctx context.Context
+		app env.App
+	}
+	deregisterContextReturns struct {
+		result1 error
+	}
+	HeartbeatContextStub        func(ctx context.Context, app env.App) error
+	heartbeatContextMutex       sync.RWMutex
+	heartbeatContextArgsForCall []struct {
+		ctx context.Context
+		app env.App
+	}
+	heartbeatContextReturns struct {
+		result1 error
+	}
+	SetStatusContextStub        func(ctx context.Context, app env.App, status discovery.Status) error
+	setStatusContextMutex       sync.RWMutex
+	setStatusContextArgsForCall []struct {
+		ctx    context.Context
+		app    env.App
+		status discovery.Status
+	}
+	setStatusContextReturns struct {
+		result1 error
+	}
+	AppsContextStub        func(ctx context.Context) (map[string]discovery.Application, error)
+	appsContextMutex       sync.RWMutex
+	appsContextArgsForCall []struct {
+		ctx context.Context
+	}
+	appsContextReturns struct {
+		result1 map[string]discovery.Application
+		result2 error
+	}
+	AppContextStub        func(ctx context.Context, name string) (discovery.Application, error)
+	appContextMutex       sync.RWMutex
+	appContextArgsForCall []struct {
+		ctx  context.Context
+		name string
+	}
+	appContextReturns struct {
+		result1 discovery.Application
+		result2 error
+	}
+	VIPContextStub        func(ctx context.Context, vip string) ([]discovery.Instance, error)
+	vIPContextMutex       sync.RWMutex
+	vIPContextArgsForCall []struct {
+		ctx context.Context
+		vip string
+	}
+	vIPContextReturns struct {
+		result1 []discovery.Instance
+		result2 error
+	}
+	SecureVIPContextStub        func(ctx context.Context, vip string) ([]discovery.Instance, error)
+	secureVIPContextMutex       sync.RWMutex
+	secureVIPContextArgsForCall []struct {
+		ctx context.Context
+		vip string
+	}
+	secureVIPContextReturns struct {
+		result1 []discovery.Instance
 		result2 error
 	}
 }
@@ -153,6 +258,39 @@ func (fake *Client) HeartbeatReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *Client) SetStatus(app env.App, status discovery.Status) error {
+	fake.setStatusMutex.Lock()
+	fake.setStatusArgsForCall = append(fake.setStatusArgsForCall, struct {
+		app    env.App
+		status discovery.Status
+	}{app, status})
+	fake.setStatusMutex.Unlock()
+	if fake.SetStatusStub != nil {
+		return fake.SetStatusStub(app, status)
+	} else {
+		return fake.setStatusReturns.result1
+	}
+}
+
+func (fake *Client) SetStatusCallCount() int {
+	fake.setStatusMutex.RLock()
+	defer fake.setStatusMutex.RUnlock()
+	return len(fake.setStatusArgsForCall)
+}
+
+func (fake *Client) SetStatusArgsForCall(i int) (env.App, discovery.Status) {
+	fake.setStatusMutex.RLock()
+	defer fake.setStatusMutex.RUnlock()
+	return fake.setStatusArgsForCall[i].app, fake.setStatusArgsForCall[i].status
+}
+
+func (fake *Client) SetStatusReturns(result1 error) {
+	fake.SetStatusStub = nil
+	fake.setStatusReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Client) HeartbeatInterval() time.Duration {
 	fake.heartbeatIntervalMutex.Lock()
 	fake.heartbeatIntervalArgsForCall = append(fake.heartbeatIntervalArgsForCall, struct{}{})
@@ -177,7 +315,7 @@ func (fake *Client) HeartbeatIntervalReturns(result1 time.Duration) {
 	}{result1}
 }
 
-func (fake *Client) Apps() (map[string][]string, error) {
+func (fake *Client) Apps() (map[string]discovery.Application, error) {
 	fake.appsMutex.Lock()
 	fake.appsArgsForCall = append(fake.appsArgsForCall, struct{}{})
 	fake.appsMutex.Unlock()
@@ -194,15 +332,15 @@ func (fake *Client) AppsCallCount() int {
 	return len(fake.appsArgsForCall)
 }
 
-func (fake *Client) AppsReturns(result1 map[string][]string, result2 error) {
+func (fake *Client) AppsReturns(result1 map[string]discovery.Application, result2 error) {
 	fake.AppsStub = nil
 	fake.appsReturns = struct {
-		result1 map[string][]string
+		result1 map[string]discovery.Application
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *Client) App(name string) ([]string, error) {
+func (fake *Client) App(name string) (discovery.Application, error) {
 	fake.appMutex.Lock()
 	fake.appArgsForCall = append(fake.appArgsForCall, struct {
 		name string
@@ -227,10 +365,344 @@ func (fake *Client) AppArgsForCall(i int) string {
 	return fake.appArgsForCall[i].name
 }
 
-func (fake *Client) AppReturns(result1 []string, result2 error) {
+func (fake *Client) AppReturns(result1 discovery.Application, result2 error) {
 	fake.AppStub = nil
 	fake.appReturns = struct {
-		result1 []string
+		result1 discovery.Application
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Client) VIP(vip string) ([]discovery.Instance, error) {
+	fake.vIPMutex.Lock()
+	fake.vIPArgsForCall = append(fake.vIPArgsForCall, struct {
+		vip string
+	}{vip})
+	fake.vIPMutex.Unlock()
+	if fake.VIPStub != nil {
+		return fake.VIPStub(vip)
+	} else {
+		return fake.vIPReturns.result1, fake.vIPReturns.result2
+	}
+}
+
+func (fake *Client) VIPCallCount() int {
+	fake.vIPMutex.RLock()
+	defer fake.vIPMutex.RUnlock()
+	return len(fake.vIPArgsForCall)
+}
+
+func (fake *Client) VIPArgsForCall(i int) string {
+	fake.vIPMutex.RLock()
+	defer fake.vIPMutex.RUnlock()
+	return fake.vIPArgsForCall[i].vip
+}
+
+func (fake *Client) VIPReturns(result1 []discovery.Instance, result2 error) {
+	fake.VIPStub = nil
+	fake.vIPReturns = struct {
+		result1 []discovery.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Client) SecureVIP(vip string) ([]discovery.Instance, error) {
+	fake.secureVIPMutex.Lock()
+	fake.secureVIPArgsForCall = append(fake.secureVIPArgsForCall, struct {
+		vip string
+	}{vip})
+	fake.secureVIPMutex.Unlock()
+	if fake.SecureVIPStub != nil {
+		return fake.SecureVIPStub(vip)
+	} else {
+		return fake.secureVIPReturns.result1, fake.secureVIPReturns.result2
+	}
+}
+
+func (fake *Client) SecureVIPCallCount() int {
+	fake.secureVIPMutex.RLock()
+	defer fake.secureVIPMutex.RUnlock()
+	return len(fake.secureVIPArgsForCall)
+}
+
+func (fake *Client) SecureVIPArgsForCall(i int) string {
+	fake.secureVIPMutex.RLock()
+	defer fake.secureVIPMutex.RUnlock()
+	return fake.secureVIPArgsForCall[i].vip
+}
+
+func (fake *Client) SecureVIPReturns(result1 []discovery.Instance, result2 error) {
+	fake.SecureVIPStub = nil
+	fake.secureVIPReturns = struct {
+		result1 []discovery.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Client) RegisterContext(ctx context.Context, app env.App) error {
+	fake.registerContextMutex.Lock()
+	fake.registerContextArgsForCall = append(fake.registerContextArgsForCall, struct {
+		ctx context.Context
+		app env.App
+	}{ctx, app})
+	fake.registerContextMutex.Unlock()
+	if fake.RegisterContextStub != nil {
+		return fake.RegisterContextStub(ctx, app)
+	} else {
+		return fake.registerContextReturns.result1
+	}
+}
+
+func (fake *Client) RegisterContextCallCount() int {
+	fake.registerContextMutex.RLock()
+	defer fake.registerContextMutex.RUnlock()
+	return len(fake.registerContextArgsForCall)
+}
+
+func (fake *Client) RegisterContextArgsForCall(i int) (context.Context, env.App) {
+	fake.registerContextMutex.RLock()
+	defer fake.registerContextMutex.RUnlock()
+	return fake.registerContextArgsForCall[i].ctx, fake.registerContextArgsForCall[i].app
+}
+
+func (fake *Client) RegisterContextReturns(result1 error) {
+	fake.RegisterContextStub = nil
+	fake.registerContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Client) DeregisterContext(ctx context.Context, app env.App) error {
+	fake.deregisterContextMutex.Lock()
+	fake.deregisterContextArgsForCall = append(fake.deregisterContextArgsForCall, struct {
+		ctx context.Context
+		app env.App
+	}{ctx, app})
+	fake.deregisterContextMutex.Unlock()
+	if fake.DeregisterContextStub != nil {
+		return fake.DeregisterContextStub(ctx, app)
+	} else {
+		return fake.deregisterContextReturns.result1
+	}
+}
+
+func (fake *Client) DeregisterContextCallCount() int {
+	fake.deregisterContextMutex.RLock()
+	defer fake.deregisterContextMutex.RUnlock()
+	return len(fake.deregisterContextArgsForCall)
+}
+
+func (fake *Client) DeregisterContextArgsForCall(i int) (context.Context, env.App) {
+	fake.deregisterContextMutex.RLock()
+	defer fake.deregisterContextMutex.RUnlock()
+	return fake.deregisterContextArgsForCall[i].ctx, fake.deregisterContextArgsForCall[i].app
+}
+
+func (fake *Client) DeregisterContextReturns(result1 error) {
+	fake.DeregisterContextStub = nil
+	fake.deregisterContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Client) HeartbeatContext(ctx context.Context, app env.App) error {
+	fake.heartbeatContextMutex.Lock()
+	fake.heartbeatContextArgsForCall = append(fake.heartbeatContextArgsForCall, struct {
+		ctx context.Context
+		app env.App
+	}{ctx, app})
+	fake.heartbeatContextMutex.Unlock()
+	if fake.HeartbeatContextStub != nil {
+		return fake.HeartbeatContextStub(ctx, app)
+	} else {
+		return fake.heartbeatContextReturns.result1
+	}
+}
+
+func (fake *Client) HeartbeatContextCallCount() int {
+	fake.heartbeatContextMutex.RLock()
+	defer fake.heartbeatContextMutex.RUnlock()
+	return len(fake.heartbeatContextArgsForCall)
+}
+
+func (fake *Client) HeartbeatContextArgsForCall(i int) (context.Context, env.App) {
+	fake.heartbeatContextMutex.RLock()
+	defer fake.heartbeatContextMutex.RUnlock()
+	return fake.heartbeatContextArgsForCall[i].ctx, fake.heartbeatContextArgsForCall[i].app
+}
+
+func (fake *Client) HeartbeatContextReturns(result1 error) {
+	fake.HeartbeatContextStub = nil
+	fake.heartbeatContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Client) SetStatusContext(ctx context.Context, app env.App, status discovery.Status) error {
+	fake.setStatusContextMutex.Lock()
+	fake.setStatusContextArgsForCall = append(fake.setStatusContextArgsForCall, struct {
+		ctx    context.Context
+		app    env.App
+		status discovery.Status
+	}{ctx, app, status})
+	fake.setStatusContextMutex.Unlock()
+	if fake.SetStatusContextStub != nil {
+		return fake.SetStatusContextStub(ctx, app, status)
+	} else {
+		return fake.setStatusContextReturns.result1
+	}
+}
+
+func (fake *Client) SetStatusContextCallCount() int {
+	fake.setStatusContextMutex.RLock()
+	defer fake.setStatusContextMutex.RUnlock()
+	return len(fake.setStatusContextArgsForCall)
+}
+
+func (fake *Client) SetStatusContextArgsForCall(i int) (context.Context, env.App, discovery.Status) {
+	fake.setStatusContextMutex.RLock()
+	defer fake.setStatusContextMutex.RUnlock()
+	return fake.setStatusContextArgsForCall[i].ctx, fake.setStatusContextArgsForCall[i].app, fake.setStatusContextArgsForCall[i].status
+}
+
+func (fake *Client) SetStatusContextReturns(result1 error) {
+	fake.SetStatusContextStub = nil
+	fake.setStatusContextReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Client) AppsContext(ctx context.Context) (map[string]discovery.Application, error) {
+	fake.appsContextMutex.Lock()
+	fake.appsContextArgsForCall = append(fake.appsContextArgsForCall, struct {
+		ctx context.Context
+	}{ctx})
+	fake.appsContextMutex.Unlock()
+	if fake.AppsContextStub != nil {
+		return fake.AppsContextStub(ctx)
+	} else {
+		return fake.appsContextReturns.result1, fake.appsContextReturns.result2
+	}
+}
+
+func (fake *Client) AppsContextCallCount() int {
+	fake.appsContextMutex.RLock()
+	defer fake.appsContextMutex.RUnlock()
+	return len(fake.appsContextArgsForCall)
+}
+
+func (fake *Client) AppsContextArgsForCall(i int) context.Context {
+	fake.appsContextMutex.RLock()
+	defer fake.appsContextMutex.RUnlock()
+	return fake.appsContextArgsForCall[i].ctx
+}
+
+func (fake *Client) AppsContextReturns(result1 map[string]discovery.Application, result2 error) {
+	fake.AppsContextStub = nil
+	fake.appsContextReturns = struct {
+		result1 map[string]discovery.Application
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Client) AppContext(ctx context.Context, name string) (discovery.Application, error) {
+	fake.appContextMutex.Lock()
+	fake.appContextArgsForCall = append(fake.appContextArgsForCall, struct {
+		ctx  context.Context
+		name string
+	}{ctx, name})
+	fake.appContextMutex.Unlock()
+	if fake.AppContextStub != nil {
+		return fake.AppContextStub(ctx, name)
+	} else {
+		return fake.appContextReturns.result1, fake.appContextReturns.result2
+	}
+}
+
+func (fake *Client) AppContextCallCount() int {
+	fake.appContextMutex.RLock()
+	defer fake.appContextMutex.RUnlock()
+	return len(fake.appContextArgsForCall)
+}
+
+func (fake *Client) AppContextArgsForCall(i int) (context.Context, string) {
+	fake.appContextMutex.RLock()
+	defer fake.appContextMutex.RUnlock()
+	return fake.appContextArgsForCall[i].ctx, fake.appContextArgsForCall[i].name
+}
+
+func (fake *Client) AppContextReturns(result1 discovery.Application, result2 error) {
+	fake.AppContextStub = nil
+	fake.appContextReturns = struct {
+		result1 discovery.Application
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Client) VIPContext(ctx context.Context, vip string) ([]discovery.Instance, error) {
+	fake.vIPContextMutex.Lock()
+	fake.vIPContextArgsForCall = append(fake.vIPContextArgsForCall, struct {
+		ctx context.Context
+		vip string
+	}{ctx, vip})
+	fake.vIPContextMutex.Unlock()
+	if fake.VIPContextStub != nil {
+		return fake.VIPContextStub(ctx, vip)
+	} else {
+		return fake.vIPContextReturns.result1, fake.vIPContextReturns.result2
+	}
+}
+
+func (fake *Client) VIPContextCallCount() int {
+	fake.vIPContextMutex.RLock()
+	defer fake.vIPContextMutex.RUnlock()
+	return len(fake.vIPContextArgsForCall)
+}
+
+func (fake *Client) VIPContextArgsForCall(i int) (context.Context, string) {
+	fake.vIPContextMutex.RLock()
+	defer fake.vIPContextMutex.RUnlock()
+	return fake.vIPContextArgsForCall[i].ctx, fake.vIPContextArgsForCall[i].vip
+}
+
+func (fake *Client) VIPContextReturns(result1 []discovery.Instance, result2 error) {
+	fake.VIPContextStub = nil
+	fake.vIPContextReturns = struct {
+		result1 []discovery.Instance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Client) SecureVIPContext(ctx context.Context, vip string) ([]discovery.Instance, error) {
+	fake.secureVIPContextMutex.Lock()
+	fake.secureVIPContextArgsForCall = append(fake.secureVIPContextArgsForCall, struct {
+		ctx context.Context
+		vip string
+	}{ctx, vip})
+	fake.secureVIPContextMutex.Unlock()
+	if fake.SecureVIPContextStub != nil {
+		return fake.SecureVIPContextStub(ctx, vip)
+	} else {
+		return fake.secureVIPContextReturns.result1, fake.secureVIPContextReturns.result2
+	}
+}
+
+func (fake *Client) SecureVIPContextCallCount() int {
+	fake.secureVIPContextMutex.RLock()
+	defer fake.secureVIPContextMutex.RUnlock()
+	return len(fake.secureVIPContextArgsForCall)
+}
+
+func (fake *Client) SecureVIPContextArgsForCall(i int) (context.Context, string) {
+	fake.secureVIPContextMutex.RLock()
+	defer fake.secureVIPContextMutex.RUnlock()
+	return fake.secureVIPContextArgsForCall[i].ctx, fake.secureVIPContextArgsForCall[i].vip
+}
+
+func (fake *Client) SecureVIPContextReturns(result1 []discovery.Instance, result2 error) {
+	fake.SecureVIPContextStub = nil
+	fake.secureVIPContextReturns = struct {
+		result1 []discovery.Instance
 		result2 error
 	}{result1, result2}
 }
@@ -0,0 +1,246 @@
+package discovery
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"golang.org/x/net/context"
+
+	"github.com/st3v/cfkit/env"
+)
+
+var errNotStarted = errors.New("Registrar not started")
+
+// DefaultDeregisterTimeout bounds deregistering once the context given to
+// Stop is done.
+var DefaultDeregisterTimeout = 5 * time.Second
+
+// Registrar keeps a single app registered with a discovery service. Several
+// registrars may share a client to register multiple logical apps from one
+// process.
+type Registrar struct {
+	client   Client
+	app      env.App
+	settings settings
+
+	mutex  sync.Mutex
+	status *statusManager
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRegistrar(client Client, app env.App, opts ...Option) *Registrar {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	if s.clock == nil {
+		s.clock = SystemClock
+	}
+	if s.backOff == nil {
+		s.backOff = NewBackOff(s.clock)
+	}
+
+	return &Registrar{
+		client:   client,
+		app:      app,
+		settings: s,
+	}
+}
+
+// Start registers the app in the background and keeps sending heartbeats
+// until Stop is called or ctx is done.
+func (r *Registrar) Start(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cancel != nil {
+		return errors.New("Registrar already started")
+	}
+
+	// a previous loop might still be finishing after Stop
+	if r.done != nil {
+		<-r.done
+	}
+
+	r.status = newStatusManager(r.client, r.app, r.settings)
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	go r.registerAndKeepAlive(ctx, r.status, r.done)
+
+	return nil
+}
+
+// Stop marks the instance DOWN, waits for the drain timeout and deregisters
+// the app. A registration or heartbeat in flight is abandoned. The drain takes
+// at most half of the time left until the deadline of ctx, and the app is
+// deregistered within DefaultDeregisterTimeout if ctx is done by then.
+func (r *Registrar) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	if r.cancel == nil {
+		r.mutex.Unlock()
+		return nil
+	}
+	cancel, done, status := r.cancel, r.done, r.status
+	r.cancel = nil
+	r.mutex.Unlock()
+
+	cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	// take the instance out of rotation before it disappears
+	if err := status.override(ctx, StatusDown); err != nil {
+		log.Println(err.Error())
+	}
+
+	if drain := r.drainTimeout(ctx); drain > 0 {
+		select {
+		case <-r.settings.clock.After(drain):
+		case <-ctx.Done():
+		}
+	}
+
+	// the app has to disappear even if the drain used up the deadline
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), DefaultDeregisterTimeout)
+		defer cancel()
+	}
+
+	return r.client.DeregisterContext(ctx, r.app)
+}
+
+// drainTimeout leaves at least half of the time until the deadline of ctx
+// for deregistering.
+func (r *Registrar) drainTimeout(ctx context.Context) time.Duration {
+	drain := r.settings.drainTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := deadline.Sub(time.Now()) / 2; left < drain {
+			drain = left
+		}
+	}
+	return drain
+}
+
+func (r *Registrar) Running() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.cancel != nil
+}
+
+// Ready reports the instance UP if it has been started with WaitForReady.
+func (r *Registrar) Ready() error {
+	status := r.statusManager()
+	if status == nil {
+		return errNotStarted
+	}
+	return status.markReady()
+}
+
+// SetStatus manually overrides the reported status, e.g. OUT_OF_SERVICE for
+// maintenance. Setting StatusUp clears the override.
+func (r *Registrar) SetStatus(status Status) error {
+	manager := r.statusManager()
+	if manager == nil {
+		return errNotStarted
+	}
+	return manager.override(context.Background(), status)
+}
+
+func (r *Registrar) Status() Status {
+	status := r.statusManager()
+	if status == nil {
+		return StatusUnknown
+	}
+	return status.effective()
+}
+
+// statusManager returns nil unless the registrar is running.
+func (r *Registrar) statusManager() *statusManager {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cancel == nil {
+		return nil
+	}
+	return r.status
+}
+
+// registerAndKeepAlive registers the app and sends heartbeats, backing off
+// whenever either fails.
+func (r *Registrar) registerAndKeepAlive(ctx context.Context, status *statusManager, done chan struct{}) {
+	defer close(done)
+
+	b := r.settings.backOff
+	b.Reset()
+
+	var interval time.Duration
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.settings.clock.After(interval):
+		}
+
+		if err := r.client.RegisterContext(ctx, r.app); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Println(err.Error())
+			if interval = b.NextBackOff(); interval == backoff.Stop {
+				log.Printf("Giving up registering app '%s'\n", r.app.Name)
+				return
+			}
+			continue
+		}
+		b.Reset()
+
+		if err := status.check(ctx); err != nil && ctx.Err() == nil {
+			log.Println(err.Error())
+		}
+
+		err := r.keepAlive(ctx, status)
+		switch {
+		case err == nil:
+			return
+		case IsNotRegistered(err):
+			// the registry lost track of the instance, register again right away
+			interval = 0
+		default:
+			if interval = b.NextBackOff(); interval == backoff.Stop {
+				log.Printf("Giving up registering app '%s'\n", r.app.Name)
+				return
+			}
+		}
+	}
+}
+
+// keepAlive sends heartbeats until the context is done or a heartbeat fails.
+func (r *Registrar) keepAlive(ctx context.Context, status *statusManager) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.settings.clock.After(jitter(r.client.HeartbeatInterval())):
+			if err := r.client.HeartbeatContext(ctx, r.app); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Println(err.Error())
+				return err
+			}
+
+			if err := status.check(ctx); err != nil && ctx.Err() == nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+}
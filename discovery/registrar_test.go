@@ -0,0 +1,259 @@
+package discovery_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	. "github.com/st3v/cfkit/discovery"
+	"github.com/st3v/cfkit/discovery/fake"
+	"github.com/st3v/cfkit/env"
+)
+
+var _ = Describe("Registrar", func() {
+	var (
+		fakeClient *fake.Client
+		app        env.App
+		registrar  *Registrar
+	)
+
+	BeforeEach(func() {
+		fakeClient = new(fake.Client)
+		fakeClient.HeartbeatIntervalReturns(10 * time.Millisecond)
+
+		app = env.App{Name: "app-name", Instance: env.AppInstance{ID: "instance-id"}}
+		registrar = NewRegistrar(fakeClient, app)
+	})
+
+	AfterEach(func() {
+		registrar.Stop(context.Background())
+	})
+
+	Describe(".Start", func() {
+		It("registers the app", func() {
+			Expect(registrar.Start(context.Background())).To(Succeed())
+			Eventually(fakeClient.RegisterContextCallCount).Should(Equal(1))
+			_, registered := fakeClient.RegisterContextArgsForCall(0)
+			Expect(registered).To(Equal(app))
+		})
+
+		It("sends heartbeats", func() {
+			Expect(registrar.Start(context.Background())).To(Succeed())
+			Eventually(fakeClient.HeartbeatContextCallCount).Should(BeNumerically(">=", 3))
+		})
+
+		It("reports the instance as UP", func() {
+			Expect(registrar.Start(context.Background())).To(Succeed())
+			Eventually(registrar.Status).Should(Equal(StatusUp))
+			Eventually(fakeClient.SetStatusContextCallCount).Should(Equal(1))
+		})
+
+		Context("when it has been started already", func() {
+			BeforeEach(func() {
+				Expect(registrar.Start(context.Background())).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(registrar.Start(context.Background())).To(MatchError("Registrar already started"))
+			})
+		})
+
+		Context("when the context is done", func() {
+			It("stops sending heartbeats", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				Expect(registrar.Start(ctx)).To(Succeed())
+				Eventually(fakeClient.HeartbeatContextCallCount).Should(BeNumerically(">", 0))
+
+				cancel()
+				time.Sleep(20 * time.Millisecond)
+				callCount := fakeClient.HeartbeatContextCallCount()
+				Consistently(fakeClient.HeartbeatContextCallCount).Should(Equal(callCount))
+			})
+		})
+	})
+
+	Describe(".Stop", func() {
+		It("does nothing unless started", func() {
+			Expect(registrar.Stop(context.Background())).To(Succeed())
+			Expect(fakeClient.DeregisterContextCallCount()).To(BeZero())
+		})
+
+		Context("when started", func() {
+			BeforeEach(func() {
+				Expect(registrar.Start(context.Background())).To(Succeed())
+				Eventually(fakeClient.SetStatusContextCallCount).Should(Equal(1))
+			})
+
+			It("marks the instance DOWN and deregisters it", func() {
+				Expect(registrar.Stop(context.Background())).To(Succeed())
+				Expect(fakeClient.SetStatusContextCallCount()).To(Equal(2))
+				_, _, status := fakeClient.SetStatusContextArgsForCall(1)
+				Expect(status).To(Equal(StatusDown))
+				Expect(fakeClient.DeregisterContextCallCount()).To(Equal(1))
+				_, deregistered := fakeClient.DeregisterContextArgsForCall(0)
+				Expect(deregistered).To(Equal(app))
+			})
+
+			It("stops sending heartbeats", func() {
+				Expect(registrar.Stop(context.Background())).To(Succeed())
+				callCount := fakeClient.HeartbeatContextCallCount()
+				Consistently(fakeClient.HeartbeatContextCallCount).Should(Equal(callCount))
+				Expect(registrar.Running()).To(BeFalse())
+			})
+
+			It("can be started again", func() {
+				Expect(registrar.Stop(context.Background())).To(Succeed())
+				Expect(registrar.Start(context.Background())).To(Succeed())
+				Eventually(fakeClient.RegisterContextCallCount).Should(Equal(2))
+			})
+
+			Context("when a heartbeat hangs", func() {
+				BeforeEach(func() {
+					Expect(registrar.Stop(context.Background())).To(Succeed())
+
+					fakeClient = new(fake.Client)
+					fakeClient.HeartbeatIntervalReturns(10 * time.Millisecond)
+					fakeClient.HeartbeatContextStub = func(ctx context.Context, _ env.App) error {
+						<-ctx.Done()
+						return ctx.Err()
+					}
+
+					registrar = NewRegistrar(fakeClient, app)
+					Expect(registrar.Start(context.Background())).To(Succeed())
+					Eventually(fakeClient.HeartbeatContextCallCount).Should(Equal(1))
+				})
+
+				It("abandons the heartbeat", func() {
+					start := time.Now()
+					Expect(registrar.Stop(context.Background())).To(Succeed())
+					Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+					Expect(fakeClient.DeregisterContextCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when deregistering fails", func() {
+				BeforeEach(func() {
+					fakeClient.DeregisterContextReturns(errors.New("some-error"))
+				})
+
+				It("returns the error", func() {
+					Expect(registrar.Stop(context.Background())).To(MatchError("some-error"))
+				})
+			})
+		})
+
+		Context("when a drain timeout is configured", func() {
+			BeforeEach(func() {
+				registrar = NewRegistrar(fakeClient, app, WithDrainTimeout(time.Hour))
+				Expect(registrar.Start(context.Background())).To(Succeed())
+			})
+
+			BeforeEach(func() {
+				fakeClient.DeregisterContextStub = func(ctx context.Context, _ env.App) error {
+					return ctx.Err()
+				}
+			})
+
+			It("leaves time to deregister before the deadline", func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+
+				start := time.Now()
+				Expect(registrar.Stop(ctx)).To(Succeed())
+				Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+				Expect(fakeClient.DeregisterContextCallCount()).To(Equal(1))
+			})
+
+			It("still deregisters once the context is cancelled", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+
+				start := time.Now()
+				Expect(registrar.Stop(ctx)).To(Succeed())
+				Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+				Expect(fakeClient.DeregisterContextCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("status", func() {
+		Context("when not started", func() {
+			It("returns an error", func() {
+				Expect(registrar.Ready()).To(MatchError("Registrar not started"))
+				Expect(registrar.SetStatus(StatusDown)).To(MatchError("Registrar not started"))
+				Expect(registrar.Status()).To(Equal(StatusUnknown))
+			})
+		})
+
+		Context("when waiting for readiness", func() {
+			BeforeEach(func() {
+				registrar = NewRegistrar(fakeClient, app, WaitForReady())
+				Expect(registrar.Start(context.Background())).To(Succeed())
+				Eventually(fakeClient.HeartbeatContextCallCount).Should(BeNumerically(">", 0))
+			})
+
+			It("stays STARTING until the app is ready", func() {
+				Expect(registrar.Status()).To(Equal(StatusStarting))
+				Expect(registrar.Ready()).To(Succeed())
+				Expect(registrar.Status()).To(Equal(StatusUp))
+			})
+		})
+	})
+
+	Context("with several registrars sharing a client", func() {
+		var other *Registrar
+
+		BeforeEach(func() {
+			otherApp := app
+			otherApp.Name = "other-app"
+			other = NewRegistrar(fakeClient, otherApp)
+		})
+
+		AfterEach(func() {
+			other.Stop(context.Background())
+		})
+
+		It("registers every app", func() {
+			Expect(registrar.Start(context.Background())).To(Succeed())
+			Expect(other.Start(context.Background())).To(Succeed())
+			Eventually(fakeClient.RegisterContextCallCount).Should(Equal(2))
+
+			_, first := fakeClient.RegisterContextArgsForCall(0)
+			_, second := fakeClient.RegisterContextArgsForCall(1)
+			names := []string{first.Name, second.Name}
+			Expect(names).To(ConsistOf("app-name", "other-app"))
+		})
+
+		It("keeps their status apart", func() {
+			registrar.Start(context.Background())
+			other.Start(context.Background())
+			Eventually(other.Status).Should(Equal(StatusUp))
+
+			Expect(registrar.SetStatus(StatusOutOfService)).To(Succeed())
+			Expect(registrar.Status()).To(Equal(StatusOutOfService))
+			Expect(other.Status()).To(Equal(StatusUp))
+		})
+	})
+
+	It("is safe for concurrent use", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+
+				registrar.Start(context.Background())
+				registrar.Status()
+				registrar.Stop(context.Background())
+			}()
+		}
+		wg.Wait()
+
+		Expect(registrar.Running()).To(BeFalse())
+	})
+})
@@ -0,0 +1,119 @@
+package discovery
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var DefaultShutdownTimeout = 30 * time.Second
+
+// Shutdown coordinates a graceful shutdown. It deregisters the app from
+// discovery before running the registered hooks, all within a deadline, and
+// leaves it to the app to exit afterwards.
+type Shutdown struct {
+	timeout    time.Duration
+	mutex      sync.Mutex
+	registrars []*Registrar
+	hooks      []func(context.Context) error
+}
+
+// NewShutdown returns a coordinator completing within the given timeout. The
+// registrar started by Enable is always stopped.
+func NewShutdown(timeout time.Duration) *Shutdown {
+	return &Shutdown{timeout: timeout}
+}
+
+// Deregister stops the given registrar on shutdown.
+func (s *Shutdown) Deregister(r *Registrar) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.registrars = append(s.registrars, r)
+}
+
+// OnShutdown adds a hook, e.g. http.Server.Shutdown, run after the app has
+// been deregistered. Hooks run in the order they have been added and should
+// return once the context is done.
+func (s *Shutdown) OnShutdown(hook func(context.Context) error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// Run shuts down, giving up on whatever has not completed once ctx is done or
+// the timeout has passed.
+func (s *Shutdown) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	s.mutex.Lock()
+	registrars := append([]*Registrar{}, s.registrars...)
+	hooks := append([]func(context.Context) error{}, s.hooks...)
+	s.mutex.Unlock()
+
+	if r := takeDefault(); r != nil {
+		registrars = append(registrars, r)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+		errs     []string
+	)
+
+	// deregister all apps at once, their drain timeouts overlap
+	for _, r := range registrars {
+		wg.Add(1)
+		go func(r *Registrar) {
+			defer wg.Done()
+			if err := r.Stop(ctx); err != nil {
+				errMutex.Lock()
+				errs = append(errs, err.Error())
+				errMutex.Unlock()
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	for _, hook := range hooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Error shutting down: %v", errs)
+	}
+
+	return nil
+}
+
+// OnSignal runs the shutdown once the process receives one of the given
+// signals, SIGINT, SIGHUP or SIGTERM by default. The returned channel yields
+// its result, after which the app should exit. Further signals are left to
+// their default behavior.
+func (s *Shutdown) OnSignal(signals ...os.Signal) <-chan error {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, signals...)
+
+	result := make(chan error, 1)
+	go func() {
+		sig := <-sigChan
+		signal.Stop(sigChan)
+
+		log.Printf("Received %s, shutting down\n", sig)
+		result <- s.Run(context.Background())
+	}()
+
+	return result
+}
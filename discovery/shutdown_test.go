@@ -0,0 +1,144 @@
+package discovery_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	. "github.com/st3v/cfkit/discovery"
+	"github.com/st3v/cfkit/discovery/fake"
+	"github.com/st3v/cfkit/env"
+)
+
+var _ = Describe("Shutdown", func() {
+	var (
+		fakeClient *fake.Client
+		registrar  *Registrar
+		shutdown   *Shutdown
+		rec        *callRecorder
+	)
+
+	BeforeEach(func() {
+		r := new(callRecorder)
+		c := new(fake.Client)
+		c.HeartbeatIntervalReturns(10 * time.Millisecond)
+		c.DeregisterContextStub = func(ctx context.Context, _ env.App) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			r.record("deregister")
+			return nil
+		}
+		rec, fakeClient = r, c
+
+		registrar = NewRegistrar(fakeClient, env.App{Name: "app-name"})
+		Expect(registrar.Start(context.Background())).To(Succeed())
+
+		shutdown = NewShutdown(time.Second)
+		shutdown.Deregister(registrar)
+	})
+
+	AfterEach(func() {
+		registrar.Stop(context.Background())
+	})
+
+	Describe(".Run", func() {
+		It("deregisters the app before running the hooks in order", func() {
+			shutdown.OnShutdown(func(context.Context) error {
+				rec.record("hook-1")
+				return nil
+			})
+			shutdown.OnShutdown(func(context.Context) error {
+				rec.record("hook-2")
+				return nil
+			})
+
+			Expect(shutdown.Run(context.Background())).To(Succeed())
+			Expect(rec.Calls()).To(Equal([]string{"deregister", "hook-1", "hook-2"}))
+			Expect(registrar.Running()).To(BeFalse())
+		})
+
+		It("gives the hooks a deadline", func() {
+			shutdown = NewShutdown(50 * time.Millisecond)
+			shutdown.OnShutdown(func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+			start := time.Now()
+			err := shutdown.Run(context.Background())
+			Expect(err).To(MatchError(ContainSubstring("deadline exceeded")))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+
+		It("cuts the drain short at the deadline", func() {
+			registrar.Stop(context.Background())
+			registrar = NewRegistrar(fakeClient, env.App{Name: "app-name"}, WithDrainTimeout(time.Hour))
+			Expect(registrar.Start(context.Background())).To(Succeed())
+
+			shutdown = NewShutdown(50 * time.Millisecond)
+			shutdown.Deregister(registrar)
+
+			start := time.Now()
+			Expect(shutdown.Run(context.Background())).To(Succeed())
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Expect(rec.Calls()).To(ContainElement("deregister"))
+		})
+
+		It("runs all hooks and reports their errors", func() {
+			shutdown.OnShutdown(func(context.Context) error {
+				return errors.New("some-error")
+			})
+			shutdown.OnShutdown(func(context.Context) error {
+				rec.record("hook")
+				return nil
+			})
+
+			err := shutdown.Run(context.Background())
+			Expect(err).To(MatchError("Error shutting down: [some-error]"))
+			Expect(rec.Calls()).To(ContainElement("hook"))
+		})
+
+		Context("when discovery has been enabled", func() {
+			BeforeEach(func() {
+				os.Setenv("VCAP_APPLICATION", vcapApplication)
+				Expect(Enable(fakeClient)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				Disable()
+				os.Unsetenv("VCAP_APPLICATION")
+			})
+
+			It("deregisters the default registrar as well", func() {
+				Expect(shutdown.Run(context.Background())).To(Succeed())
+				Expect(rec.Calls()).To(Equal([]string{"deregister", "deregister"}))
+				Expect(Default()).To(BeNil())
+			})
+		})
+	})
+
+	Describe(".OnSignal", func() {
+		// the test runner handles SIGINT and SIGTERM itself
+
+		It("shuts down upon receiving one of the given signals", func() {
+			result := shutdown.OnSignal(syscall.SIGHUP)
+			Consistently(result).ShouldNot(Receive())
+
+			syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+			Eventually(result).Should(Receive(BeNil()))
+			Expect(rec.Calls()).To(Equal([]string{"deregister"}))
+		})
+
+		It("handles SIGHUP by default", func() {
+			result := shutdown.OnSignal()
+			syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+			Eventually(result).Should(Receive(BeNil()))
+		})
+	})
+})
@@ -0,0 +1,158 @@
+package discovery
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"golang.org/x/net/context"
+
+	"github.com/st3v/cfkit/env"
+)
+
+var errNotEnabled = errors.New("Discovery not enabled")
+
+type Option func(*settings)
+
+type settings struct {
+	waitForReady bool
+	healthCheck  func() error
+	drainTimeout time.Duration
+	backOff      backoff.BackOff
+	clock        Clock
+}
+
+// WaitForReady keeps the instance STARTING until Ready is called.
+func WaitForReady() Option {
+	return func(s *settings) {
+		s.waitForReady = true
+	}
+}
+
+// WithHealthCheck reports the instance as DOWN for as long as the check fails.
+// It is evaluated after every heartbeat.
+func WithHealthCheck(check func() error) Option {
+	return func(s *settings) {
+		s.healthCheck = check
+	}
+}
+
+// WithDrainTimeout delays deregistration after the instance has been marked
+// DOWN, giving clients time to pick up the change.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.drainTimeout = timeout
+	}
+}
+
+func Ready() error {
+	r := currentRegistrar()
+	if r == nil {
+		return errNotEnabled
+	}
+	return r.Ready()
+}
+
+// SetStatus manually overrides the reported status, e.g. OUT_OF_SERVICE for
+// maintenance. Setting StatusUp clears the override.
+func SetStatus(status Status) error {
+	r := currentRegistrar()
+	if r == nil {
+		return errNotEnabled
+	}
+	return r.SetStatus(status)
+}
+
+func CurrentStatus() Status {
+	r := currentRegistrar()
+	if r == nil {
+		return StatusUnknown
+	}
+	return r.Status()
+}
+
+type statusManager struct {
+	mutex       sync.Mutex
+	client      Client
+	app         env.App
+	healthCheck func() error
+	ready       bool
+	healthy     bool
+	manual      Status
+	reported    Status
+}
+
+func newStatusManager(client Client, app env.App, s settings) *statusManager {
+	return &statusManager{
+		client:      client,
+		app:         app,
+		healthCheck: s.healthCheck,
+		ready:       !s.waitForReady,
+		healthy:     true,
+		reported:    StatusStarting,
+	}
+}
+
+func (m *statusManager) markReady() error {
+	m.mutex.Lock()
+	m.ready = true
+	m.mutex.Unlock()
+	return m.sync(context.Background())
+}
+
+func (m *statusManager) override(ctx context.Context, status Status) error {
+	m.mutex.Lock()
+	m.manual = status
+	if status == StatusUp {
+		m.manual = ""
+	}
+	m.mutex.Unlock()
+	return m.sync(ctx)
+}
+
+// check runs the health check, if any, and reports status changes.
+func (m *statusManager) check(ctx context.Context) error {
+	if m.healthCheck != nil {
+		healthy := m.healthCheck() == nil
+		m.mutex.Lock()
+		m.healthy = healthy
+		m.mutex.Unlock()
+	}
+	return m.sync(ctx)
+}
+
+func (m *statusManager) effective() Status {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch {
+	case m.manual != "":
+		return m.manual
+	case !m.ready:
+		return StatusStarting
+	case !m.healthy:
+		return StatusDown
+	default:
+		return StatusUp
+	}
+}
+
+// sync reports the effective status if it differs from the last reported one.
+func (m *statusManager) sync(ctx context.Context) error {
+	status := m.effective()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if status == m.reported {
+		return nil
+	}
+
+	if err := m.client.SetStatusContext(ctx, m.app, status); err != nil {
+		return err
+	}
+
+	m.reported = status
+	return nil
+}
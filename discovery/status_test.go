@@ -0,0 +1,169 @@
+package discovery_test
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	. "github.com/st3v/cfkit/discovery"
+	"github.com/st3v/cfkit/discovery/fake"
+	"github.com/st3v/cfkit/env"
+)
+
+type callRecorder struct {
+	sync.Mutex
+	calls []string
+}
+
+func (r *callRecorder) record(call string) {
+	r.Lock()
+	defer r.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *callRecorder) Calls() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+var _ = Describe("status", func() {
+	var (
+		fakeClient *fake.Client
+		rec        *callRecorder
+	)
+
+	BeforeEach(func() {
+		os.Setenv("VCAP_APPLICATION", vcapApplication)
+
+		// the stubs capture locals, since the shutdown of a previous spec
+		// may still be in flight
+		r := new(callRecorder)
+		c := new(fake.Client)
+		c.HeartbeatIntervalReturns(10 * time.Millisecond)
+		c.RegisterContextStub = func(context.Context, env.App) error {
+			r.record("register")
+			return nil
+		}
+		c.SetStatusContextStub = func(_ context.Context, app env.App, status Status) error {
+			r.record(string(status))
+			return nil
+		}
+		c.DeregisterContextStub = func(context.Context, env.App) error {
+			r.record("deregister")
+			return nil
+		}
+		rec, fakeClient = r, c
+	})
+
+	AfterEach(func() {
+		Disable()
+		os.Unsetenv("VCAP_APPLICATION")
+	})
+
+	It("reports the instance as UP once it is registered", func() {
+		Enable(fakeClient)
+		Eventually(rec.Calls).Should(Equal([]string{"register", "UP"}))
+		Expect(CurrentStatus()).To(Equal(StatusUp))
+	})
+
+	It("marks the instance DOWN before deregistering it", func() {
+		Enable(fakeClient)
+		Eventually(rec.Calls).Should(Equal([]string{"register", "UP"}))
+		Disable()
+		Eventually(rec.Calls).Should(Equal([]string{"register", "UP", "DOWN", "deregister"}))
+	})
+
+	Context("when waiting for readiness", func() {
+		BeforeEach(func() {
+			Enable(fakeClient, WaitForReady())
+			Eventually(fakeClient.HeartbeatContextCallCount).Should(BeNumerically(">", 0))
+		})
+
+		It("stays STARTING until the app is ready", func() {
+			Consistently(fakeClient.SetStatusContextCallCount).Should(BeZero())
+			Expect(CurrentStatus()).To(Equal(StatusStarting))
+
+			Expect(Ready()).To(Succeed())
+			Expect(rec.Calls()).To(Equal([]string{"register", "UP"}))
+		})
+	})
+
+	Context("when the status is set manually", func() {
+		BeforeEach(func() {
+			Enable(fakeClient)
+			Eventually(rec.Calls).Should(Equal([]string{"register", "UP"}))
+		})
+
+		It("reports the override until the instance is set UP again", func() {
+			Expect(SetStatus(StatusOutOfService)).To(Succeed())
+			Expect(CurrentStatus()).To(Equal(StatusOutOfService))
+			Consistently(rec.Calls).Should(Equal([]string{"register", "UP", "OUT_OF_SERVICE"}))
+
+			Expect(SetStatus(StatusUp)).To(Succeed())
+			Expect(rec.Calls()).To(Equal([]string{"register", "UP", "OUT_OF_SERVICE", "UP"}))
+		})
+
+		Context("and reporting the status fails", func() {
+			BeforeEach(func() {
+				Disable()
+
+				c := new(fake.Client)
+				c.HeartbeatIntervalReturns(time.Hour)
+				c.SetStatusContextReturns(errors.New("some-error"))
+
+				Enable(c)
+				Eventually(c.RegisterContextCallCount).Should(Equal(1))
+			})
+
+			It("returns the error", func() {
+				Expect(SetStatus(StatusOutOfService)).To(MatchError("some-error"))
+			})
+		})
+	})
+
+	Context("when a health check is configured", func() {
+		var (
+			mutex    sync.Mutex
+			checkErr error
+		)
+
+		setCheckErr := func(err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			checkErr = err
+		}
+
+		BeforeEach(func() {
+			setCheckErr(nil)
+			Enable(fakeClient, WithHealthCheck(func() error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return checkErr
+			}))
+			Eventually(rec.Calls).Should(Equal([]string{"register", "UP"}))
+		})
+
+		It("follows the health check on every heartbeat", func() {
+			setCheckErr(errors.New("database unreachable"))
+			Eventually(rec.Calls).Should(Equal([]string{"register", "UP", "DOWN"}))
+			Expect(CurrentStatus()).To(Equal(StatusDown))
+
+			setCheckErr(nil)
+			Eventually(rec.Calls).Should(Equal([]string{"register", "UP", "DOWN", "UP"}))
+		})
+	})
+
+	Context("when discovery is not enabled", func() {
+		It("returns an error", func() {
+			Expect(Ready()).To(MatchError("Discovery not enabled"))
+			Expect(SetStatus(StatusDown)).To(MatchError("Discovery not enabled"))
+			Expect(CurrentStatus()).To(Equal(StatusUnknown))
+		})
+	})
+})
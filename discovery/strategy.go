@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+)
+
+// Strategy chooses one of the candidates, which are UP instances of the same
+// app in a stable order. inFlight reports the outstanding requests to an
+// instance.
+type Strategy func(candidates []Instance, inFlight func(Instance) int) Instance
+
+// RoundRobin cycles through the instances of each app.
+func RoundRobin() Strategy {
+	var mutex sync.Mutex
+	next := map[string]int{}
+
+	return func(candidates []Instance, _ func(Instance) int) Instance {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		app := candidates[0].App
+		i := next[app] % len(candidates)
+		next[app] = i + 1
+		return candidates[i]
+	}
+}
+
+func Random() Strategy {
+	return func(candidates []Instance, _ func(Instance) int) Instance {
+		return candidates[rand.Intn(len(candidates))]
+	}
+}
+
+// LeastOutstanding chooses the instance with the fewest requests in flight,
+// breaking ties at random.
+func LeastOutstanding() Strategy {
+	return func(candidates []Instance, inFlight func(Instance) int) Instance {
+		var least []Instance
+		min := -1
+		for _, inst := range candidates {
+			n := inFlight(inst)
+			switch {
+			case min < 0 || n < min:
+				min, least = n, []Instance{inst}
+			case n == min:
+				least = append(least, inst)
+			}
+		}
+		return least[rand.Intn(len(least))]
+	}
+}
+
+// WeightedByMetadata chooses instances at random, in proportion to the
+// integer weight found in their metadata under the given key. Instances
+// without a valid weight count as 1, those with a weight of 0 are only
+// chosen if no other instance has any weight.
+func WeightedByMetadata(key string) Strategy {
+	return func(candidates []Instance, inFlight func(Instance) int) Instance {
+		weights := make([]int, len(candidates))
+		total := 0
+		for i, inst := range candidates {
+			weights[i] = weight(inst, key)
+			total += weights[i]
+		}
+
+		if total == 0 {
+			return Random()(candidates, inFlight)
+		}
+
+		n := rand.Intn(total)
+		for i, w := range weights {
+			if n < w {
+				return candidates[i]
+			}
+			n -= w
+		}
+		return candidates[len(candidates)-1]
+	}
+}
+
+func weight(inst Instance, key string) int {
+	w, err := strconv.Atoi(inst.Metadata[key])
+	if err != nil {
+		return 1
+	}
+	if w < 0 {
+		return 0
+	}
+	return w
+}
+
+// ZonePreferring restricts the strategy to the instances in the given zone,
+// unless there are none.
+func ZonePreferring(zone string, strategy Strategy) Strategy {
+	return func(candidates []Instance, inFlight func(Instance) int) Instance {
+		local := Application{Instances: candidates}.Filter(func(inst Instance) bool {
+			return inst.Zone == zone
+		})
+
+		if len(local.Instances) > 0 {
+			return strategy(local.Instances, inFlight)
+		}
+		return strategy(candidates, inFlight)
+	}
+}
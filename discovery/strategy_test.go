@@ -0,0 +1,106 @@
+package discovery_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/st3v/cfkit/discovery"
+)
+
+var _ = Describe("strategies", func() {
+	var (
+		candidates []Instance
+		inFlight   map[string]int
+	)
+
+	count := func(inst Instance) int {
+		return inFlight[inst.ID]
+	}
+
+	choose := func(strategy Strategy, times int) map[string]int {
+		chosen := map[string]int{}
+		for i := 0; i < times; i++ {
+			chosen[strategy(candidates, count).ID]++
+		}
+		return chosen
+	}
+
+	BeforeEach(func() {
+		candidates = []Instance{
+			{ID: "a", App: "app-name", Zone: "zone-1", Metadata: map[string]string{"weight": "3"}},
+			{ID: "b", App: "app-name", Zone: "zone-2", Metadata: map[string]string{"weight": "0"}},
+			{ID: "c", App: "app-name", Zone: "zone-2", Metadata: map[string]string{}},
+		}
+		inFlight = map[string]int{}
+	})
+
+	Describe(".RoundRobin", func() {
+		It("cycles through the candidates", func() {
+			strategy := RoundRobin()
+			var ids []string
+			for i := 0; i < 4; i++ {
+				ids = append(ids, strategy(candidates, count).ID)
+			}
+			Expect(ids).To(Equal([]string{"a", "b", "c", "a"}))
+		})
+
+		It("keeps track of each app separately", func() {
+			strategy := RoundRobin()
+			strategy(candidates, count)
+
+			other := []Instance{{ID: "x", App: "other-app"}, {ID: "y", App: "other-app"}}
+			Expect(strategy(other, count).ID).To(Equal("x"))
+			Expect(strategy(candidates, count).ID).To(Equal("b"))
+		})
+	})
+
+	Describe(".Random", func() {
+		It("chooses any of the candidates", func() {
+			Expect(choose(Random(), 100)).To(HaveLen(3))
+		})
+	})
+
+	Describe(".LeastOutstanding", func() {
+		It("chooses the candidate with the fewest requests in flight", func() {
+			inFlight = map[string]int{"a": 2, "b": 1, "c": 3}
+			Expect(choose(LeastOutstanding(), 10)).To(Equal(map[string]int{"b": 10}))
+		})
+
+		It("spreads ties", func() {
+			inFlight = map[string]int{"a": 1, "b": 1, "c": 3}
+			Expect(choose(LeastOutstanding(), 100)).To(HaveLen(2))
+		})
+	})
+
+	Describe(".WeightedByMetadata", func() {
+		It("chooses candidates in proportion to their weight", func() {
+			chosen := choose(WeightedByMetadata("weight"), 1000)
+			Expect(chosen).ToNot(HaveKey("b"))
+			Expect(chosen["a"]).To(BeNumerically(">", 2*chosen["c"]))
+		})
+
+		Context("when no candidate has any weight", func() {
+			BeforeEach(func() {
+				for _, inst := range candidates {
+					inst.Metadata["weight"] = "0"
+				}
+			})
+
+			It("chooses any of the candidates", func() {
+				Expect(choose(WeightedByMetadata("weight"), 100)).To(HaveLen(3))
+			})
+		})
+	})
+
+	Describe(".ZonePreferring", func() {
+		It("chooses candidates in the zone", func() {
+			Expect(choose(ZonePreferring("zone-2", RoundRobin()), 4)).To(Equal(map[string]int{"b": 2, "c": 2}))
+		})
+
+		Context("when there is no candidate in the zone", func() {
+			It("chooses any of the candidates", func() {
+				Expect(choose(ZonePreferring("zone-3", RoundRobin()), 3)).To(HaveLen(3))
+			})
+		})
+	})
+})
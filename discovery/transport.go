@@ -0,0 +1,239 @@
+package discovery
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultTransportRetries is how many other instances a Transport tries after
+// failing to connect to an instance.
+var DefaultTransportRetries = 2
+
+type TransportOption func(*Transport)
+
+// WithBaseTransport sets the transport sending the rewritten requests,
+// http.DefaultTransport by default.
+func WithBaseTransport(base http.RoundTripper) TransportOption {
+	return func(t *Transport) {
+		t.base = base
+	}
+}
+
+// PreserveHost keeps the logical name in the Host header. By default the
+// header carries the host name of the instance, i.e. its route when the app
+// has been registered through the router.
+func PreserveHost() TransportOption {
+	return func(t *Transport) {
+		t.preserveHost = true
+	}
+}
+
+func WithRetries(retries int) TransportOption {
+	return func(t *Transport) {
+		t.retries = retries
+	}
+}
+
+// Transport sends requests for logical hosts, e.g. http://app-name/path, to
+// an instance of the app picked by the balancer. Requests for hosts with an
+// explicit port or hosts unknown to the discovery service are passed on
+// unchanged.
+type Transport struct {
+	balancer     *Balancer
+	base         http.RoundTripper
+	preserveHost bool
+	retries      int
+
+	mutex    sync.Mutex
+	rewrites map[*http.Request]*http.Request
+	lookups  map[*http.Request]context.CancelFunc
+}
+
+func NewTransport(balancer *Balancer, opts ...TransportOption) *Transport {
+	t := &Transport{
+		balancer: balancer,
+		base:     http.DefaultTransport,
+		retries:  DefaultTransportRetries,
+		rewrites: map[*http.Request]*http.Request{},
+		lookups:  map[*http.Request]context.CancelFunc{},
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// RoundTrip retries idempotent requests without a body on another instance if
+// it cannot connect to the picked one.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := req.URL.Host
+	if _, _, err := net.SplitHostPort(name); err == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := t.lookupContext(req)
+	defer cancel()
+
+	var (
+		tried   []Instance
+		lastErr error
+	)
+
+	for {
+		inst, done, err := t.balancer.Pick(ctx, name, tried...)
+		switch {
+		case IsUnknownApp(err):
+			return t.base.RoundTrip(req)
+		case err != nil && lastErr != nil:
+			closeBody(req)
+			return nil, lastErr
+		case err != nil:
+			closeBody(req)
+			return nil, err
+		}
+
+		resp, err := t.send(req, t.rewrite(req, inst))
+		if err == nil {
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: done}
+			return resp, nil
+		}
+
+		done()
+		if !retriable(req, err) || len(tried) >= t.retries {
+			closeBody(req)
+			return nil, err
+		}
+
+		tried = append(tried, inst)
+		lastErr = err
+	}
+}
+
+// CancelRequest cancels a request in flight, allowing the Transport to be
+// used by clients with a timeout.
+func (t *Transport) CancelRequest(req *http.Request) {
+	t.mutex.Lock()
+	rewritten, ok := t.rewrites[req]
+	if cancel, lookingUp := t.lookups[req]; lookingUp {
+		cancel()
+	}
+	t.mutex.Unlock()
+
+	if !ok {
+		rewritten = req
+	}
+
+	if c, ok := t.base.(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		c.CancelRequest(rewritten)
+	}
+}
+
+// lookupContext is done once the request is cancelled, through either its
+// Cancel channel or CancelRequest, bounding the lookup of its instances.
+func (t *Transport) lookupContext(req *http.Request) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	t.mutex.Lock()
+	t.lookups[req] = cancel
+	t.mutex.Unlock()
+
+	if req.Cancel != nil {
+		go func() {
+			select {
+			case <-req.Cancel:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	return ctx, func() {
+		t.mutex.Lock()
+		delete(t.lookups, req)
+		t.mutex.Unlock()
+		cancel()
+	}
+}
+
+func (t *Transport) send(req, rewritten *http.Request) (*http.Response, error) {
+	t.mutex.Lock()
+	t.rewrites[req] = rewritten
+	t.mutex.Unlock()
+
+	defer func() {
+		t.mutex.Lock()
+		delete(t.rewrites, req)
+		t.mutex.Unlock()
+	}()
+
+	return t.base.RoundTrip(rewritten)
+}
+
+func (t *Transport) rewrite(req *http.Request, inst Instance) *http.Request {
+	base, _ := url.Parse(inst.BaseURL())
+
+	u := *req.URL
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+
+	r := new(http.Request)
+	*r = *req
+	r.URL = &u
+
+	r.Host = ""
+	if t.preserveHost {
+		r.Host = req.Host
+		if r.Host == "" {
+			r.Host = req.URL.Host
+		}
+	}
+
+	return r
+}
+
+// closeBody closes the request body when giving up, RoundTrip has to close it
+// even on errors.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// retriable reports whether the request never reached the instance and can
+// safely be sent again.
+func retriable(req *http.Request, err error) bool {
+	if req.Body != nil {
+		return false
+	}
+
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+	default:
+		return false
+	}
+
+	e, ok := err.(*net.OpError)
+	return ok && e.Op == "dial"
+}
+
+// releasingBody ends the pick of the instance once the response has been
+// read.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
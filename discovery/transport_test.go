@@ -0,0 +1,225 @@
+package discovery_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+
+	. "github.com/st3v/cfkit/discovery"
+	"github.com/st3v/cfkit/discovery/fake"
+)
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+var _ = Describe("Transport", func() {
+	var (
+		fakeClient *fake.Client
+		backends   []*httptest.Server
+		hosts      chan string
+		balancer   *Balancer
+		client     *http.Client
+		app        Application
+	)
+
+	instanceOf := func(id string, server *httptest.Server) Instance {
+		u, _ := url.Parse(server.URL)
+		host, port, _ := net.SplitHostPort(u.Host)
+		p, _ := strconv.Atoi(port)
+		return Instance{ID: id, App: "app-name", HostName: host, Port: p, PortEnabled: true, Status: StatusUp}
+	}
+
+	get := func(uri string) string {
+		resp, err := client.Get(uri)
+		Expect(err).ToNot(HaveOccurred())
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	BeforeEach(func() {
+		hosts = make(chan string, 10)
+		backends = nil
+		for _, name := range []string{"backend-a", "backend-b"} {
+			n, h := name, hosts
+			backends = append(backends, httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				h <- req.Host
+				w.Write([]byte(n + req.URL.RequestURI()))
+			})))
+		}
+
+		app = Application{Name: "app-name", Instances: []Instance{
+			instanceOf("a", backends[0]),
+			instanceOf("b", backends[1]),
+		}}
+
+		fakeClient = new(fake.Client)
+		fakeClient.AppContextReturns(app, nil)
+		balancer = NewBalancer(fakeClient)
+		client = &http.Client{Transport: NewTransport(balancer)}
+	})
+
+	AfterEach(func() {
+		for _, b := range backends {
+			b.Close()
+		}
+	})
+
+	It("sends requests for the app to its instances", func() {
+		Expect(get("http://app-name/items?id=1")).To(Equal("backend-a/items?id=1"))
+		Expect(get("http://app-name/items?id=2")).To(Equal("backend-b/items?id=2"))
+
+		_, name := fakeClient.AppContextArgsForCall(0)
+		Expect(name).To(Equal("app-name"))
+	})
+
+	It("sets the Host header to the instance", func() {
+		get("http://app-name/")
+		Expect(<-hosts).To(Equal(strings.TrimPrefix(backends[0].URL, "http://")))
+	})
+
+	It("releases the instance once the response has been read", func() {
+		get("http://app-name/")
+		Expect(balancer.InFlight(app.Instances[0])).To(BeZero())
+	})
+
+	Context("when the Host header is preserved", func() {
+		BeforeEach(func() {
+			client = &http.Client{Transport: NewTransport(balancer, PreserveHost())}
+		})
+
+		It("keeps the logical name", func() {
+			get("http://app-name/")
+			Expect(<-hosts).To(Equal("app-name"))
+		})
+	})
+
+	Context("when the host is unknown", func() {
+		BeforeEach(func() {
+			fakeClient.AppContextReturns(Application{}, notFoundError(true))
+		})
+
+		It("passes the request on unchanged", func() {
+			_, err := client.Get("http://unknown-host.invalid/")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("unknown-host.invalid"))
+		})
+	})
+
+	Context("when the host has a port", func() {
+		It("passes the request on unchanged without a lookup", func() {
+			Expect(get(backends[1].URL + "/items")).To(Equal("backend-b/items"))
+			Expect(fakeClient.AppContextCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when the lookup fails", func() {
+		BeforeEach(func() {
+			fakeClient.AppContextReturns(Application{}, notFoundError(false))
+		})
+
+		It("returns the error", func() {
+			_, err := client.Get("http://app-name/")
+			Expect(err).To(MatchError(ContainSubstring("not found")))
+		})
+
+		It("closes the request body", func() {
+			body := &closeRecorder{Reader: strings.NewReader("body")}
+			_, err := client.Post("http://app-name/", "text/plain", body)
+			Expect(err).To(HaveOccurred())
+			Expect(body.closed).To(BeTrue())
+		})
+	})
+
+	Context("when the lookup hangs", func() {
+		BeforeEach(func() {
+			fakeClient.AppContextStub = func(ctx context.Context, name string) (Application, error) {
+				<-ctx.Done()
+				return Application{}, ctx.Err()
+			}
+		})
+
+		It("gives up once the client times out", func() {
+			client.Timeout = 50 * time.Millisecond
+
+			errs := make(chan error, 1)
+			go func() {
+				_, err := client.Get("http://app-name/")
+				errs <- err
+			}()
+
+			Eventually(errs).Should(Receive(HaveOccurred()))
+		})
+
+		It("gives up once the request is cancelled", func() {
+			cancel := make(chan struct{})
+			req, _ := http.NewRequest("GET", "http://app-name/", nil)
+			req.Cancel = cancel
+			time.AfterFunc(20*time.Millisecond, func() { close(cancel) })
+
+			errs := make(chan error, 1)
+			go func() {
+				_, err := client.Do(req)
+				errs <- err
+			}()
+
+			Eventually(errs).Should(Receive(MatchError(ContainSubstring("context canceled"))))
+		})
+	})
+
+	Context("when an instance cannot be reached", func() {
+		BeforeEach(func() {
+			backends[0].Close()
+		})
+
+		It("retries on another instance", func() {
+			Expect(get("http://app-name/")).To(Equal("backend-b/"))
+		})
+
+		It("does not retry requests that are not idempotent", func() {
+			_, err := client.Post("http://app-name/", "text/plain", strings.NewReader("body"))
+			Expect(err).To(HaveOccurred())
+			Consistently(hosts).ShouldNot(Receive())
+		})
+
+		Context("and neither can the others", func() {
+			BeforeEach(func() {
+				backends[1].Close()
+			})
+
+			It("returns the error", func() {
+				_, err := client.Get("http://app-name/")
+				Expect(err).To(MatchError(ContainSubstring("connection refused")))
+				Expect(fakeClient.AppContextCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("and retries are disabled", func() {
+			BeforeEach(func() {
+				client = &http.Client{Transport: NewTransport(balancer, WithRetries(0))}
+			})
+
+			It("returns the error", func() {
+				_, err := client.Get("http://app-name/")
+				Expect(err).To(MatchError(ContainSubstring("connection refused")))
+			})
+		})
+	})
+})
@@ -22,6 +22,7 @@ type App struct {
 	StateTimestamp int         `json:"state_timestamp"`
 	Instance       AppInstance `json:"instance"`
 	Space          Space       `json:"space"`
+	Organization   Org         `json:"organization"`
 }
 
 type AppLimits struct {
@@ -31,11 +32,12 @@ type AppLimits struct {
 }
 
 type AppInstance struct {
-	ID    string `json:"id"`
-	Index int    `json:"index"`
-	IP    string `json:"ip"`
-	Port  int    `json:"port"`
-	Addr  string `json:"addr"`
+	ID         string `json:"id"`
+	Index      int    `json:"index"`
+	IP         string `json:"ip"`
+	InternalIP string `json:"internal_ip"`
+	Port       int    `json:"port"`
+	Addr       string `json:"addr"`
 }
 
 type Space struct {
@@ -43,6 +45,11 @@ type Space struct {
 	Name string `json:"name"`
 }
 
+type Org struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
 func Application() (App, error) {
 	vcapApp := os.Getenv(appEnvVar)
 	if vcapApp == "" {
@@ -73,6 +80,8 @@ func (a *App) UnmarshalJSON(data []byte) error {
 		AppID         string `json:"application_id"`
 		SpaceID       string `json:"space_id"`
 		SpaceName     string `json:"space_name"`
+		OrgID         string `json:"organization_id"`
+		OrgName       string `json:"organization_name"`
 		*AppAlias
 	}{
 		AppAlias: (*AppAlias)(a),
@@ -82,21 +91,37 @@ func (a *App) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	// newer Cloud Foundry versions only set $PORT
+	if a.Port == 0 {
+		a.Port = appPort()
+	}
+
 	a.ID = aux.AppID
 	a.Space.ID = aux.SpaceID
 	a.Space.Name = aux.SpaceName
+	a.Organization.ID = aux.OrgID
+	a.Organization.Name = aux.OrgName
 	a.Addr = fmt.Sprintf("%s:%d", aux.Host, aux.Port)
 	a.Instance = AppInstance{
-		ID:    aux.InstanceID,
-		Index: aux.InstanceIndex,
-		Port:  instancePort(),
-		IP:    instanceIP(),
-		Addr:  instanceAddr(),
+		ID:         aux.InstanceID,
+		Index:      aux.InstanceIndex,
+		Port:       instancePort(),
+		IP:         instanceIP(),
+		InternalIP: instanceInternalIP(),
+		Addr:       instanceAddr(),
 	}
 
 	return nil
 }
 
+func appPort() int {
+	port, err := strconv.Atoi(os.Getenv("PORT"))
+	if err != nil {
+		return 0
+	}
+	return port
+}
+
 func instancePort() int {
 	port, err := strconv.Atoi(os.Getenv("CF_INSTANCE_PORT"))
 	if err != nil {
@@ -109,6 +134,12 @@ func instanceIP() string {
 	return os.Getenv("CF_INSTANCE_IP")
 }
 
+// instanceInternalIP is the address of the container on the container
+// network, only set if container networking is enabled.
+func instanceInternalIP() string {
+	return os.Getenv("CF_INSTANCE_INTERNAL_IP")
+}
+
 func instanceAddr() string {
 	return os.Getenv("CF_INSTANCE_ADDR")
 }
@@ -2,6 +2,7 @@ package env_test
 
 import (
 	"os"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -14,6 +15,7 @@ var _ = Describe(".Application", func() {
 		BeforeEach(func() {
 			os.Setenv("VCAP_APPLICATION", vcapApplication)
 			os.Setenv("CF_INSTANCE_IP", "1.2.3.4")
+			os.Setenv("CF_INSTANCE_INTERNAL_IP", "10.255.0.4")
 			os.Setenv("CF_INSTANCE_PORT", "12345")
 			os.Setenv("CF_INSTANCE_ADDR", "1.2.3.4:12345")
 		})
@@ -21,6 +23,7 @@ var _ = Describe(".Application", func() {
 		AfterEach(func() {
 			os.Unsetenv("VCAP_APPLICATION")
 			os.Unsetenv("CF_INSTANCE_IP")
+			os.Unsetenv("CF_INSTANCE_INTERNAL_IP")
 			os.Unsetenv("CF_INSTANCE_PORT")
 			os.Unsetenv("CF_INSTANCE_ADDR")
 		})
@@ -45,11 +48,14 @@ var _ = Describe(".Application", func() {
 			Expect(app.Limits.FileDescriptors).To(Equal(16384))
 			Expect(app.Space.ID).To(Equal("cc35031c-b4af-4eea-9914-b25cc0db3888"))
 			Expect(app.Space.Name).To(Equal("development"))
+			Expect(app.Organization.ID).To(Equal("0f1b2d3c-4e5f-4a6b-8c7d-9e0f1a2b3c4d"))
+			Expect(app.Organization.Name).To(Equal("cfkit-org"))
 			Expect(app.StartTimestamp).To(Equal(123456789))
 			Expect(app.StateTimestamp).To(Equal(987654321))
 			Expect(app.Instance.ID).To(Equal("3fc7db2dfa534d3cb6094f17fe6e12f5"))
 			Expect(app.Instance.Index).To(Equal(99))
 			Expect(app.Instance.IP).To(Equal("1.2.3.4"))
+			Expect(app.Instance.InternalIP).To(Equal("10.255.0.4"))
 			Expect(app.Instance.Port).To(Equal(12345))
 			Expect(app.Instance.Addr).To(Equal("1.2.3.4:12345"))
 		})
@@ -76,6 +82,41 @@ var _ = Describe(".Application", func() {
 		})
 	})
 
+	Context("when CF_INSTANCE_INTERNAL_IP env var is not set", func() {
+		BeforeEach(func() {
+			os.Setenv("VCAP_APPLICATION", vcapApplication)
+			os.Unsetenv("CF_INSTANCE_INTERNAL_IP")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("VCAP_APPLICATION")
+		})
+
+		It("sets an empty internal IP", func() {
+			app, _ := env.Application()
+			Expect(app.Instance.InternalIP).To(Equal(""))
+		})
+	})
+
+	Context("when VCAP_APPLICATION does not contain the port", func() {
+		BeforeEach(func() {
+			os.Setenv("VCAP_APPLICATION", strings.Replace(vcapApplication, `"port": 63940,`, "", 1))
+			os.Setenv("PORT", "8080")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("VCAP_APPLICATION")
+			os.Unsetenv("PORT")
+		})
+
+		It("uses the PORT env var", func() {
+			app, err := env.Application()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(app.Port).To(Equal(8080))
+			Expect(app.Addr).To(Equal("0.0.0.0:8080"))
+		})
+	})
+
 	Context("when CF_INSTANCE_PORT env var is not set", func() {
 		BeforeEach(func() {
 			os.Setenv("VCAP_APPLICATION", vcapApplication)
@@ -191,6 +232,8 @@ var vcapApplication = `
   "instance_index": 99,
   "space_id": "cc35031c-b4af-4eea-9914-b25cc0db3888",
   "space_name": "development",
+  "organization_id": "0f1b2d3c-4e5f-4a6b-8c7d-9e0f1a2b3c4d",
+  "organization_name": "cfkit-org",
   "uris": [
    "cfkit.cfapps.io"
   ],
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -35,6 +36,15 @@ func ServiceWithName(name string) (Service, error) {
 	return services.withName(name)
 }
 
+func Services() ([]Service, error) {
+	services, err := getServices()
+	if err != nil {
+		return []Service{}, err
+	}
+
+	return services.all(), nil
+}
+
 type serviceMap map[string][]Service
 
 func getServices() (serviceMap, error) {
@@ -51,6 +61,20 @@ func getServices() (serviceMap, error) {
 	return *services, nil
 }
 
+func (m serviceMap) all() []Service {
+	labels := make([]string, 0, len(m))
+	for label := range m {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	result := []Service{}
+	for _, label := range labels {
+		result = append(result, m[label]...)
+	}
+	return result
+}
+
 func (m serviceMap) withTag(tag string) (Service, error) {
 	for _, services := range m {
 		for _, service := range services {
@@ -131,6 +131,36 @@ var _ = Describe(".ServiceWithName", func() {
 	})
 })
 
+var _ = Describe(".Services", func() {
+	BeforeEach(func() {
+		os.Setenv("VCAP_SERVICES", vcapServices)
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("VCAP_SERVICES")
+	})
+
+	It("returns all services", func() {
+		services, err := env.Services()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(services).To(HaveLen(2))
+		Expect(services[0].Name).To(Equal("service-name-1"))
+		Expect(services[1].Name).To(Equal("service-name-2"))
+	})
+
+	Context("when VCAP_SERVICES is not set", func() {
+		BeforeEach(func() {
+			os.Unsetenv("VCAP_SERVICES")
+		})
+
+		It("returns an error", func() {
+			_, err := env.Services()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("VCAP_SERVICES not set"))
+		})
+	})
+})
+
 var vcapServices = `
 	{
 		"service-label": [
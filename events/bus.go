@@ -0,0 +1,230 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/st3v/cfkit/env"
+	"github.com/st3v/cfkit/service"
+	"github.com/streadway/amqp"
+)
+
+var (
+	DefaultExchange      = "cfkit.events"
+	DefaultSchemaVersion = "1"
+)
+
+var (
+	channelProvider = rabbitChannel
+	now             = time.Now
+)
+
+func rabbitChannel(rabbit *service.RabbitMQ) (service.AMQPChannel, error) {
+	return rabbit.Channel()
+}
+
+type Typed interface {
+	EventType() string
+}
+
+type Versioned interface {
+	SchemaVersion() string
+}
+
+type Handler func(e Event) error
+
+type Option func(*Bus)
+
+func WithExchange(name string) Option {
+	return func(b *Bus) {
+		b.exchange = name
+	}
+}
+
+func WithMode(mode Mode) Option {
+	return func(b *Bus) {
+		b.mode = mode
+	}
+}
+
+func WithCodec(codec Codec) Option {
+	return func(b *Bus) {
+		b.codec = codec
+	}
+}
+
+func WithSchemaVersion(version string) Option {
+	return func(b *Bus) {
+		b.schemaVersion = version
+	}
+}
+
+type Bus struct {
+	sequence      uint64
+	channel       service.AMQPChannel
+	app           env.App
+	exchange      string
+	mode          Mode
+	codec         Codec
+	schemaVersion string
+	closeOnce     sync.Once
+	dispatchers   sync.WaitGroup
+}
+
+func NewBus(rabbit *service.RabbitMQ, app env.App, opts ...Option) (*Bus, error) {
+	channel, err := channelProvider(rabbit)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening AMQP channel: %s", err)
+	}
+
+	bus := &Bus{
+		channel:       channel,
+		app:           app,
+		exchange:      DefaultExchange,
+		mode:          Binary,
+		codec:         JSON,
+		schemaVersion: DefaultSchemaVersion,
+	}
+
+	for _, opt := range opts {
+		opt(bus)
+	}
+
+	if err := channel.ExchangeDeclare(bus.exchange, "topic", true, false, false, false, nil); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("Error declaring exchange '%s': %s", bus.exchange, err)
+	}
+
+	return bus, nil
+}
+
+func (b *Bus) Publish(v interface{}) error {
+	event, err := b.NewEvent(v)
+	if err != nil {
+		return err
+	}
+	return b.PublishEvent(event)
+}
+
+func (b *Bus) PublishEvent(e Event) error {
+	msg, err := encode(e, b.mode)
+	if err != nil {
+		return err
+	}
+
+	if err := b.channel.Publish(b.exchange, e.Type, false, false, msg); err != nil {
+		return fmt.Errorf("Error publishing event '%s': %s", e.Type, err)
+	}
+
+	return nil
+}
+
+func (b *Bus) NewEvent(v interface{}) (Event, error) {
+	data, err := b.codec.Marshal(v)
+	if err != nil {
+		return Event{}, fmt.Errorf("Error encoding event data: %s", err)
+	}
+
+	schemaVersion := b.schemaVersion
+	if versioned, ok := v.(Versioned); ok {
+		schemaVersion = versioned.SchemaVersion()
+	}
+
+	return Event{
+		ID:              b.nextID(),
+		Source:          b.Source(),
+		SpecVersion:     SpecVersion,
+		Type:            b.TypeOf(v),
+		DataContentType: b.codec.ContentType(),
+		Time:            now(),
+		SchemaVersion:   schemaVersion,
+		Data:            data,
+		codec:           b.codec,
+	}, nil
+}
+
+func (b *Bus) Source() string {
+	return fmt.Sprintf("/apps/%s/instances/%s", b.app.Name, b.app.Instance.ID)
+}
+
+func (b *Bus) TypeOf(v interface{}) string {
+	if typed, ok := v.(Typed); ok {
+		return typed.EventType()
+	}
+
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil {
+		return b.app.Name
+	}
+
+	return fmt.Sprintf("%s.%s", b.app.Name, t.Name())
+}
+
+func (b *Bus) Subscribe(pattern string, handler Handler) error {
+	queue := fmt.Sprintf("%s.%s", b.app.Name, pattern)
+
+	if _, err := b.channel.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("Error declaring queue '%s': %s", queue, err)
+	}
+
+	if err := b.channel.QueueBind(queue, pattern, b.exchange, false, nil); err != nil {
+		return fmt.Errorf("Error binding queue '%s' to '%s': %s", queue, pattern, err)
+	}
+
+	deliveries, err := b.channel.Consume(queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("Error consuming from queue '%s': %s", queue, err)
+	}
+
+	b.dispatchers.Add(1)
+	go b.dispatch(deliveries, handler)
+
+	return nil
+}
+
+// Close closes the channel and waits for running handlers to return. It must
+// not be called from within a handler, that would wait for itself.
+func (b *Bus) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		err = b.channel.Close()
+		b.dispatchers.Wait()
+	})
+	return err
+}
+
+func (b *Bus) dispatch(deliveries <-chan amqp.Delivery, handler Handler) {
+	defer b.dispatchers.Done()
+
+	for d := range deliveries {
+		event, err := decode(d)
+		if err != nil {
+			log.Printf("Error decoding event: %s\n", err)
+			d.Nack(false, false)
+			continue
+		}
+
+		event.codec = b.codec
+
+		if err := handler(event); err != nil {
+			log.Printf("Error handling event '%s': %s\n", event.ID, err)
+			d.Nack(false, false)
+			continue
+		}
+
+		d.Ack(false)
+	}
+}
+
+func (b *Bus) nextID() string {
+	seq := atomic.AddUint64(&b.sequence, 1)
+	return fmt.Sprintf("%s-%d-%d", b.app.Instance.ID, now().UnixNano(), seq)
+}
@@ -0,0 +1,352 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/st3v/cfkit/env"
+	"github.com/st3v/cfkit/service"
+	"github.com/st3v/cfkit/service/fake"
+	"github.com/streadway/amqp"
+)
+
+type orderCreated struct {
+	OrderID string `json:"order_id"`
+	Amount  int    `json:"amount"`
+}
+
+type typedEvent struct {
+	Name string `json:"name"`
+}
+
+func (typedEvent) EventType() string {
+	return "com.example.typed"
+}
+
+func (typedEvent) SchemaVersion() string {
+	return "42"
+}
+
+type fakeAcknowledger struct {
+	sync.Mutex
+	acked  []uint64
+	nacked []uint64
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.Lock()
+	defer a.Unlock()
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.Lock()
+	defer a.Unlock()
+	a.nacked = append(a.nacked, tag)
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func (a *fakeAcknowledger) Acked() []uint64 {
+	a.Lock()
+	defer a.Unlock()
+	return append([]uint64{}, a.acked...)
+}
+
+func (a *fakeAcknowledger) Nacked() []uint64 {
+	a.Lock()
+	defer a.Unlock()
+	return append([]uint64{}, a.nacked...)
+}
+
+var _ = Describe("Bus", func() {
+	var (
+		fakeChannel         *fake.AMQPChannel
+		origChannelProvider = channelProvider
+		origNow             = now
+		expectedTime        = time.Date(2015, 11, 5, 10, 30, 0, 0, time.UTC)
+		bus                 *Bus
+		opts                []Option
+
+		app = env.App{
+			Name: "orders",
+			Instance: env.AppInstance{
+				ID: "instance-id",
+			},
+		}
+	)
+
+	BeforeEach(func() {
+		fakeChannel = new(fake.AMQPChannel)
+		channelProvider = func(*service.RabbitMQ) (service.AMQPChannel, error) {
+			return fakeChannel, nil
+		}
+		now = func() time.Time {
+			return expectedTime
+		}
+		opts = []Option{}
+	})
+
+	AfterEach(func() {
+		channelProvider = origChannelProvider
+		now = origNow
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		bus, err = NewBus(&service.RabbitMQ{}, app, opts...)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	Describe(".NewBus", func() {
+		It("declares a durable topic exchange", func() {
+			Expect(fakeChannel.ExchangeDeclareCallCount()).To(Equal(1))
+			name, kind, durable, _, _, _, _ := fakeChannel.ExchangeDeclareArgsForCall(0)
+			Expect(name).To(Equal(DefaultExchange))
+			Expect(kind).To(Equal("topic"))
+			Expect(durable).To(BeTrue())
+		})
+
+		Context("when a custom exchange is specified", func() {
+			BeforeEach(func() {
+				opts = append(opts, WithExchange("custom"))
+			})
+
+			It("declares the custom exchange", func() {
+				name, _, _, _, _, _, _ := fakeChannel.ExchangeDeclareArgsForCall(0)
+				Expect(name).To(Equal("custom"))
+			})
+		})
+
+		Context("when opening the channel fails", func() {
+			It("returns an error", func() {
+				channelProvider = func(*service.RabbitMQ) (service.AMQPChannel, error) {
+					return nil, errors.New("some-error")
+				}
+				_, err := NewBus(&service.RabbitMQ{}, app)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Error opening AMQP channel"))
+			})
+		})
+
+		Context("when declaring the exchange fails", func() {
+			It("closes the channel and returns an error", func() {
+				fakeChannel.ExchangeDeclareReturns(errors.New("some-error"))
+				_, err := NewBus(&service.RabbitMQ{}, app)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Error declaring exchange"))
+				Expect(fakeChannel.CloseCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe(".Publish", func() {
+		It("routes the event by its type", func() {
+			Expect(bus.Publish(orderCreated{"123", 99})).To(Succeed())
+			Expect(fakeChannel.PublishCallCount()).To(Equal(1))
+			exchange, key, _, _, _ := fakeChannel.PublishArgsForCall(0)
+			Expect(exchange).To(Equal(DefaultExchange))
+			Expect(key).To(Equal("orders.orderCreated"))
+		})
+
+		It("fills source, type, id and schema version from the app", func() {
+			bus.Publish(&orderCreated{"123", 99})
+			_, _, _, _, msg := fakeChannel.PublishArgsForCall(0)
+			Expect(msg.Headers["cloudEvents:specversion"]).To(Equal("1.0"))
+			Expect(msg.Headers["cloudEvents:source"]).To(Equal("/apps/orders/instances/instance-id"))
+			Expect(msg.Headers["cloudEvents:type"]).To(Equal("orders.orderCreated"))
+			Expect(msg.Headers["cloudEvents:id"]).To(HavePrefix("instance-id-"))
+			Expect(msg.Headers["cloudEvents:schemaversion"]).To(Equal(DefaultSchemaVersion))
+			Expect(msg.ContentType).To(Equal("application/json"))
+			Expect(msg.Body).To(MatchJSON(`{"order_id": "123", "amount": 99}`))
+		})
+
+		It("assigns unique ids", func() {
+			bus.Publish(orderCreated{})
+			bus.Publish(orderCreated{})
+			_, _, _, _, msg1 := fakeChannel.PublishArgsForCall(0)
+			_, _, _, _, msg2 := fakeChannel.PublishArgsForCall(1)
+			Expect(msg1.MessageId).ToNot(Equal(msg2.MessageId))
+		})
+
+		Context("when the value defines its own type and schema version", func() {
+			It("uses them", func() {
+				bus.Publish(typedEvent{"foo"})
+				_, key, _, _, msg := fakeChannel.PublishArgsForCall(0)
+				Expect(key).To(Equal("com.example.typed"))
+				Expect(msg.Headers["cloudEvents:type"]).To(Equal("com.example.typed"))
+				Expect(msg.Headers["cloudEvents:schemaversion"]).To(Equal("42"))
+			})
+		})
+
+		Context("when in structured mode", func() {
+			BeforeEach(func() {
+				opts = append(opts, WithMode(Structured))
+			})
+
+			It("publishes a structured cloud event", func() {
+				bus.Publish(orderCreated{"123", 99})
+				_, _, _, _, msg := fakeChannel.PublishArgsForCall(0)
+				Expect(msg.ContentType).To(Equal(StructuredContentType))
+				Expect(msg.Headers).To(BeEmpty())
+				Expect(msg.Body).To(MatchJSON(`{
+					"specversion": "1.0",
+					"id": "` + msg.MessageId + `",
+					"source": "/apps/orders/instances/instance-id",
+					"type": "orders.orderCreated",
+					"datacontenttype": "application/json",
+					"time": "2015-11-05T10:30:00Z",
+					"schemaversion": "1",
+					"data": {"order_id": "123", "amount": 99}
+				}`))
+			})
+		})
+
+		Context("when publishing fails", func() {
+			It("returns an error", func() {
+				fakeChannel.PublishReturns(errors.New("some-error"))
+				err := bus.Publish(orderCreated{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Error publishing event 'orders.orderCreated'"))
+			})
+		})
+	})
+
+	Describe(".Subscribe", func() {
+		var (
+			deliveries chan amqp.Delivery
+			acker      *fakeAcknowledger
+			received   chan Event
+			handlerErr error
+		)
+
+		BeforeEach(func() {
+			deliveries = make(chan amqp.Delivery, 1)
+			acker = new(fakeAcknowledger)
+			received = make(chan Event, 1)
+			handlerErr = nil
+			fakeChannel.ConsumeReturns(deliveries, nil)
+		})
+
+		AfterEach(func() {
+			close(deliveries)
+			bus.Close()
+		})
+
+		JustBeforeEach(func() {
+			Expect(bus.Subscribe("orders.#", func(e Event) error {
+				received <- e
+				return handlerErr
+			})).To(Succeed())
+		})
+
+		It("declares and binds a queue for the app", func() {
+			name, durable, _, _, _, _ := fakeChannel.QueueDeclareArgsForCall(0)
+			Expect(name).To(Equal("orders.orders.#"))
+			Expect(durable).To(BeTrue())
+
+			queue, key, exchange, _, _ := fakeChannel.QueueBindArgsForCall(0)
+			Expect(queue).To(Equal("orders.orders.#"))
+			Expect(key).To(Equal("orders.#"))
+			Expect(exchange).To(Equal(DefaultExchange))
+		})
+
+		Context("when a binary event is delivered", func() {
+			BeforeEach(func() {
+				deliveries <- deliveryFrom(encodeBinary(testEvent()), acker, 1)
+			})
+
+			It("passes the typed event to the handler and acks it", func() {
+				var e Event
+				Eventually(received).Should(Receive(&e))
+				Expect(e.Type).To(Equal("orders.orderCreated"))
+
+				var order orderCreated
+				Expect(e.DataAs(&order)).To(Succeed())
+				Expect(order).To(Equal(orderCreated{"123", 99}))
+
+				Eventually(acker.Acked).Should(Equal([]uint64{1}))
+			})
+		})
+
+		Context("when a structured event is delivered", func() {
+			BeforeEach(func() {
+				msg, err := encodeStructured(testEvent())
+				Expect(err).ToNot(HaveOccurred())
+				deliveries <- deliveryFrom(msg, acker, 2)
+			})
+
+			It("passes the typed event to the handler", func() {
+				var e Event
+				Eventually(received).Should(Receive(&e))
+
+				var order orderCreated
+				Expect(e.DataAs(&order)).To(Succeed())
+				Expect(order).To(Equal(orderCreated{"123", 99}))
+			})
+		})
+
+		Context("when the handler fails", func() {
+			BeforeEach(func() {
+				handlerErr = errors.New("some-error")
+				deliveries <- deliveryFrom(encodeBinary(testEvent()), acker, 3)
+			})
+
+			It("nacks the delivery", func() {
+				Eventually(acker.Nacked).Should(Equal([]uint64{3}))
+				Expect(acker.Acked()).To(BeEmpty())
+			})
+		})
+
+		Context("when the delivery is not a cloud event", func() {
+			BeforeEach(func() {
+				deliveries <- deliveryFrom(amqp.Publishing{Body: []byte("foo")}, acker, 4)
+			})
+
+			It("nacks the delivery without calling the handler", func() {
+				Eventually(acker.Nacked).Should(Equal([]uint64{4}))
+				Consistently(received).ShouldNot(Receive())
+			})
+		})
+	})
+
+	Describe(".Close", func() {
+		It("closes the channel once", func() {
+			Expect(bus.Close()).To(Succeed())
+			Expect(bus.Close()).To(Succeed())
+			Expect(fakeChannel.CloseCallCount()).To(Equal(1))
+		})
+	})
+})
+
+func testEvent() Event {
+	return Event{
+		ID:              "event-id",
+		Source:          "/apps/orders/instances/instance-id",
+		SpecVersion:     SpecVersion,
+		Type:            "orders.orderCreated",
+		DataContentType: "application/json",
+		Time:            time.Date(2015, 11, 5, 10, 30, 0, 0, time.UTC),
+		SchemaVersion:   "1",
+		Data:            []byte(`{"order_id": "123", "amount": 99}`),
+	}
+}
+
+func deliveryFrom(msg amqp.Publishing, acker amqp.Acknowledger, tag uint64) amqp.Delivery {
+	return amqp.Delivery{
+		Acknowledger: acker,
+		Headers:      msg.Headers,
+		ContentType:  msg.ContentType,
+		MessageId:    msg.MessageId,
+		Type:         msg.Type,
+		DeliveryTag:  tag,
+		Body:         msg.Body,
+	}
+}
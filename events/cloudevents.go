@@ -0,0 +1,223 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const (
+	SpecVersion = "1.0"
+
+	StructuredContentType = "application/cloudevents+json"
+
+	headerPrefix           = "cloudEvents:"
+	schemaVersionExtension = "schemaversion"
+)
+
+type Mode int
+
+const (
+	Binary Mode = iota
+	Structured
+)
+
+type Event struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	Subject         string
+	Time            time.Time
+	SchemaVersion   string
+	Data            []byte
+
+	codec Codec
+}
+
+func (e Event) DataAs(v interface{}) error {
+	if e.codec == nil {
+		return errors.New("No codec to decode event data")
+	}
+	return e.codec.Unmarshal(e.Data, v)
+}
+
+type structuredEvent struct {
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time,omitempty"`
+	SchemaVersion   string          `json:"schemaversion,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+func encode(e Event, mode Mode) (amqp.Publishing, error) {
+	if mode == Structured {
+		return encodeStructured(e)
+	}
+	return encodeBinary(e), nil
+}
+
+func encodeBinary(e Event) amqp.Publishing {
+	headers := amqp.Table{
+		headerPrefix + "id":          e.ID,
+		headerPrefix + "source":      e.Source,
+		headerPrefix + "specversion": e.SpecVersion,
+		headerPrefix + "type":        e.Type,
+	}
+
+	if e.Subject != "" {
+		headers[headerPrefix+"subject"] = e.Subject
+	}
+
+	if !e.Time.IsZero() {
+		headers[headerPrefix+"time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	if e.SchemaVersion != "" {
+		headers[headerPrefix+schemaVersionExtension] = e.SchemaVersion
+	}
+
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  e.DataContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    e.ID,
+		Timestamp:    e.Time,
+		Type:         e.Type,
+		Body:         e.Data,
+	}
+}
+
+func encodeStructured(e Event) (amqp.Publishing, error) {
+	se := structuredEvent{
+		ID:              e.ID,
+		Source:          e.Source,
+		SpecVersion:     e.SpecVersion,
+		Type:            e.Type,
+		DataContentType: e.DataContentType,
+		Subject:         e.Subject,
+		SchemaVersion:   e.SchemaVersion,
+	}
+
+	if !e.Time.IsZero() {
+		se.Time = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+
+	if isJSON(e.DataContentType) && validJSON(e.Data) {
+		se.Data = json.RawMessage(e.Data)
+	} else {
+		se.DataBase64 = e.Data
+	}
+
+	body, err := json.Marshal(se)
+	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("Error encoding structured event: %s", err)
+	}
+
+	return amqp.Publishing{
+		ContentType:  StructuredContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    e.ID,
+		Timestamp:    e.Time,
+		Type:         e.Type,
+		Body:         body,
+	}, nil
+}
+
+func decode(d amqp.Delivery) (Event, error) {
+	if strings.HasPrefix(d.ContentType, StructuredContentType) {
+		return decodeStructured(d)
+	}
+	return decodeBinary(d)
+}
+
+func decodeBinary(d amqp.Delivery) (Event, error) {
+	header := func(name string) string {
+		s, _ := d.Headers[headerPrefix+name].(string)
+		return s
+	}
+
+	e := Event{
+		ID:              header("id"),
+		Source:          header("source"),
+		SpecVersion:     header("specversion"),
+		Type:            header("type"),
+		Subject:         header("subject"),
+		SchemaVersion:   header(schemaVersionExtension),
+		DataContentType: d.ContentType,
+		Data:            d.Body,
+	}
+
+	if t := header("time"); t != "" {
+		var err error
+		if e.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+			return Event{}, fmt.Errorf("Error parsing event time: %s", err)
+		}
+	}
+
+	return e, validate(e)
+}
+
+func decodeStructured(d amqp.Delivery) (Event, error) {
+	var se structuredEvent
+	if err := json.Unmarshal(d.Body, &se); err != nil {
+		return Event{}, fmt.Errorf("Error decoding structured event: %s", err)
+	}
+
+	e := Event{
+		ID:              se.ID,
+		Source:          se.Source,
+		SpecVersion:     se.SpecVersion,
+		Type:            se.Type,
+		DataContentType: se.DataContentType,
+		Subject:         se.Subject,
+		SchemaVersion:   se.SchemaVersion,
+		Data:            []byte(se.Data),
+	}
+
+	if se.DataBase64 != nil {
+		e.Data = se.DataBase64
+	}
+
+	if se.Time != "" {
+		var err error
+		if e.Time, err = time.Parse(time.RFC3339Nano, se.Time); err != nil {
+			return Event{}, fmt.Errorf("Error parsing event time: %s", err)
+		}
+	}
+
+	return e, validate(e)
+}
+
+func validate(e Event) error {
+	if e.SpecVersion != SpecVersion {
+		return fmt.Errorf("Unsupported CloudEvents spec version '%s'", e.SpecVersion)
+	}
+
+	if e.ID == "" || e.Source == "" || e.Type == "" {
+		return errors.New("Event is missing required attributes")
+	}
+
+	return nil
+}
+
+func validJSON(data []byte) bool {
+	var raw json.RawMessage
+	return json.Unmarshal(data, &raw) == nil
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "" ||
+		strings.HasPrefix(contentType, "application/json") ||
+		strings.HasSuffix(strings.SplitN(contentType, ";", 2)[0], "+json")
+}
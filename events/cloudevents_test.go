@@ -0,0 +1,94 @@
+package events
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/streadway/amqp"
+)
+
+var _ = Describe("cloudevents", func() {
+	var event Event
+
+	BeforeEach(func() {
+		event = testEvent()
+		event.Subject = "order-123"
+	})
+
+	Describe("binary mode", func() {
+		It("maps attributes to prefixed headers", func() {
+			msg := encodeBinary(event)
+			Expect(msg.Headers).To(Equal(amqp.Table{
+				"cloudEvents:id":            "event-id",
+				"cloudEvents:source":        "/apps/orders/instances/instance-id",
+				"cloudEvents:specversion":   "1.0",
+				"cloudEvents:type":          "orders.orderCreated",
+				"cloudEvents:subject":       "order-123",
+				"cloudEvents:time":          "2015-11-05T10:30:00Z",
+				"cloudEvents:schemaversion": "1",
+			}))
+			Expect(msg.ContentType).To(Equal("application/json"))
+			Expect(msg.Body).To(Equal(event.Data))
+		})
+
+		It("round-trips", func() {
+			decoded, err := decode(deliveryFrom(encodeBinary(event), nil, 1))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded).To(Equal(event))
+		})
+	})
+
+	Describe("structured mode", func() {
+		It("embeds JSON data", func() {
+			msg, err := encodeStructured(event)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msg.ContentType).To(Equal(StructuredContentType))
+			Expect(msg.Body).To(MatchJSON(`{
+				"specversion": "1.0",
+				"id": "event-id",
+				"source": "/apps/orders/instances/instance-id",
+				"type": "orders.orderCreated",
+				"datacontenttype": "application/json",
+				"subject": "order-123",
+				"time": "2015-11-05T10:30:00Z",
+				"schemaversion": "1",
+				"data": {"order_id": "123", "amount": 99}
+			}`))
+		})
+
+		It("base64 encodes non-JSON data", func() {
+			event.DataContentType = "application/octet-stream"
+			event.Data = []byte{0x01, 0x02}
+			msg, err := encodeStructured(event)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(msg.Body)).To(ContainSubstring(`"data_base64":"AQI="`))
+		})
+
+		It("round-trips", func() {
+			msg, err := encodeStructured(event)
+			Expect(err).ToNot(HaveOccurred())
+			decoded, err := decode(deliveryFrom(msg, nil, 1))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decoded.ID).To(Equal(event.ID))
+			Expect(decoded.Time).To(Equal(event.Time))
+			Expect(decoded.Data).To(MatchJSON(event.Data))
+		})
+	})
+
+	Context("when the spec version is not supported", func() {
+		It("returns an error", func() {
+			event.SpecVersion = "0.3"
+			_, err := decode(deliveryFrom(encodeBinary(event), nil, 1))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Unsupported CloudEvents spec version '0.3'"))
+		})
+	})
+
+	Context("when required attributes are missing", func() {
+		It("returns an error", func() {
+			event.Source = ""
+			_, err := decode(deliveryFrom(encodeBinary(event), nil, 1))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Event is missing required attributes"))
+		})
+	})
+})
@@ -0,0 +1,117 @@
+package lifecycle
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/st3v/cfkit/service"
+	"github.com/streadway/amqp"
+)
+
+type Funcs struct {
+	OpenFunc  func() error
+	CheckFunc func() error
+	CloseFunc func() error
+}
+
+func (f Funcs) Open() error {
+	if f.OpenFunc == nil {
+		return nil
+	}
+	return f.OpenFunc()
+}
+
+func (f Funcs) Check() error {
+	if f.CheckFunc == nil {
+		return nil
+	}
+	return f.CheckFunc()
+}
+
+func (f Funcs) Close() error {
+	if f.CloseFunc == nil {
+		return nil
+	}
+	return f.CloseFunc()
+}
+
+func Closer(c io.Closer) Dependency {
+	return Funcs{CloseFunc: c.Close}
+}
+
+type RabbitDependency struct {
+	rabbit *service.RabbitMQ
+	mutex  sync.RWMutex
+	conn   *amqp.Connection
+	closed chan *amqp.Error
+	lost   error
+}
+
+func Rabbit(rabbit *service.RabbitMQ) *RabbitDependency {
+	return &RabbitDependency{rabbit: rabbit}
+}
+
+func (d *RabbitDependency) Open() error {
+	conn, err := d.rabbit.Dial()
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.conn = conn
+	d.closed = conn.NotifyClose(make(chan *amqp.Error, 1))
+	d.lost = nil
+
+	return nil
+}
+
+func (d *RabbitDependency) Check() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.conn == nil {
+		return errors.New("Not connected")
+	}
+
+	if d.lost != nil {
+		return d.lost
+	}
+
+	select {
+	case err, ok := <-d.closed:
+		d.lost = errors.New("Connection closed")
+		if ok && err != nil {
+			d.lost = err
+		}
+		return d.lost
+	default:
+		return nil
+	}
+}
+
+func (d *RabbitDependency) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.conn == nil {
+		return nil
+	}
+
+	conn := d.conn
+	d.conn = nil
+
+	if d.lost != nil {
+		return nil
+	}
+
+	return conn.Close()
+}
+
+func (d *RabbitDependency) Connection() *amqp.Connection {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.conn
+}
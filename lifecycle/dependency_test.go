@@ -0,0 +1,62 @@
+package lifecycle
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/st3v/cfkit/env"
+	"github.com/st3v/cfkit/service"
+)
+
+type closer struct {
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return errors.New("some-error")
+}
+
+var _ = Describe("Funcs", func() {
+	It("treats missing functions as no-ops", func() {
+		f := Funcs{}
+		Expect(f.Open()).To(Succeed())
+		Expect(f.Check()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+	})
+})
+
+var _ = Describe(".Closer", func() {
+	It("closes the wrapped closer", func() {
+		c := new(closer)
+		err := Closer(c).Close()
+		Expect(c.closed).To(BeTrue())
+		Expect(err).To(MatchError("some-error"))
+	})
+})
+
+var _ = Describe("RabbitDependency", func() {
+	var dep *RabbitDependency
+
+	BeforeEach(func() {
+		rabbit, err := service.RabbitFromService(env.Service{
+			Credentials: map[string]interface{}{"uri": "amqp://127.0.0.1:1/"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		dep = Rabbit(rabbit)
+	})
+
+	It("is not healthy before it has been opened", func() {
+		Expect(dep.Check()).To(MatchError("Not connected"))
+	})
+
+	It("fails to open when the broker is unreachable", func() {
+		Expect(dep.Open()).ToNot(Succeed())
+		Expect(dep.Connection()).To(BeNil())
+	})
+
+	It("does not fail to close when it has not been opened", func() {
+		Expect(dep.Close()).To(Succeed())
+	})
+})
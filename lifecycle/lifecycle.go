@@ -0,0 +1,357 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	DefaultOpenTimeout   = 30 * time.Second
+	DefaultRetryInterval = 1 * time.Second
+	DefaultProbeInterval = 15 * time.Second
+	DefaultProbeTimeout  = 5 * time.Second
+)
+
+var now = time.Now
+
+type Dependency interface {
+	Open() error
+	Check() error
+	Close() error
+}
+
+type Status struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type Report struct {
+	Healthy      bool     `json:"healthy"`
+	Dependencies []Status `json:"dependencies"`
+}
+
+type Option func(*Container)
+
+func WithOpenTimeout(timeout time.Duration) Option {
+	return func(c *Container) {
+		c.openTimeout = timeout
+	}
+}
+
+func WithRetryInterval(interval time.Duration) Option {
+	return func(c *Container) {
+		c.retryInterval = interval
+	}
+}
+
+func WithProbeInterval(interval time.Duration) Option {
+	return func(c *Container) {
+		c.probeInterval = interval
+	}
+}
+
+func WithProbeTimeout(timeout time.Duration) Option {
+	return func(c *Container) {
+		c.probeTimeout = timeout
+	}
+}
+
+type entry struct {
+	name   string
+	dep    Dependency
+	opened bool
+	status Status
+}
+
+type Container struct {
+	mutex         sync.RWMutex
+	entries       []*entry
+	openTimeout   time.Duration
+	retryInterval time.Duration
+	probeInterval time.Duration
+	probeTimeout  time.Duration
+	stop          chan struct{}
+	probes        sync.WaitGroup
+}
+
+func New(opts ...Option) *Container {
+	c := &Container{
+		openTimeout:   DefaultOpenTimeout,
+		retryInterval: DefaultRetryInterval,
+		probeInterval: DefaultProbeInterval,
+		probeTimeout:  DefaultProbeTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *Container) Add(name string, dep Dependency) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = append(c.entries, &entry{
+		name:   name,
+		dep:    dep,
+		status: Status{Name: name},
+	})
+}
+
+func (c *Container) Start(ctx context.Context) error {
+	c.mutex.RLock()
+	entries := append([]*entry{}, c.entries...)
+	c.mutex.RUnlock()
+
+	for _, e := range entries {
+		if err := c.open(ctx, e); err != nil {
+			c.Close()
+			return fmt.Errorf("Error opening dependency '%s': %s", e.name, err)
+		}
+	}
+
+	c.mutex.Lock()
+	c.stop = make(chan struct{})
+	for _, e := range entries {
+		c.probes.Add(1)
+		go c.probe(e, c.stop)
+	}
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// Run starts all dependencies and blocks until the context is cancelled or
+// the process receives SIGINT or SIGTERM, at which point everything is closed
+// in reverse order.
+func (c *Container) Run(ctx context.Context) error {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	if err := c.Start(ctx); err != nil {
+		return err
+	}
+
+	select {
+	case <-sigChan:
+	case <-ctx.Done():
+	}
+
+	return c.Close()
+}
+
+func (c *Container) Close() error {
+	c.mutex.Lock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	entries := append([]*entry{}, c.entries...)
+	c.mutex.Unlock()
+
+	c.probes.Wait()
+
+	var errs []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+
+		c.mutex.Lock()
+		opened := e.opened
+		e.opened = false
+		c.mutex.Unlock()
+
+		if !opened {
+			continue
+		}
+
+		if err := e.dep.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", e.name, err))
+		}
+
+		c.setStatus(e, errors.New("closed"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Error closing dependencies: %v", errs)
+	}
+
+	return nil
+}
+
+func (c *Container) Status() Report {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	report := Report{
+		Healthy:      true,
+		Dependencies: make([]Status, len(c.entries)),
+	}
+
+	for i, e := range c.entries {
+		report.Dependencies[i] = e.status
+		report.Healthy = report.Healthy && e.status.Healthy
+	}
+
+	return report
+}
+
+func (c *Container) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Status()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+func (c *Container) open(ctx context.Context, e *entry) error {
+	deadline := now().Add(c.openTimeout)
+	attemptCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	attempt := func(fn func() error, abandon func()) error {
+		var lastErr error
+		for {
+			err := call(attemptCtx, fn, abandon)
+			switch {
+			case err == nil:
+				return nil
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case err == context.DeadlineExceeded && lastErr != nil:
+				return lastErr
+			case err == context.DeadlineExceeded:
+				return fmt.Errorf("No response within %s", c.openTimeout)
+			}
+			lastErr = err
+
+			log.Printf("Dependency '%s' not ready: %s\n", e.name, err)
+
+			if !now().Add(c.retryInterval).Before(deadline) {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retryInterval):
+			}
+		}
+	}
+
+	// a dependency that opens only after we gave up is closed right away
+	closeLate := func() {
+		if err := e.dep.Close(); err != nil {
+			log.Printf("Error closing dependency '%s': %s\n", e.name, err)
+		}
+	}
+
+	if err := attempt(e.dep.Open, closeLate); err != nil {
+		c.setStatus(e, err)
+		return err
+	}
+
+	c.mutex.Lock()
+	e.opened = true
+	c.mutex.Unlock()
+
+	err := attempt(e.dep.Check, nil)
+	c.setStatus(e, err)
+	return err
+}
+
+// call gives up waiting for fn once ctx is done, abandon runs if fn succeeds
+// after that.
+func call(ctx context.Context, fn func() error, abandon func()) error {
+	results := make(chan error, 1)
+	go func() {
+		results <- fn()
+	}()
+
+	select {
+	case err := <-results:
+		return err
+	case <-ctx.Done():
+		go func() {
+			if err := <-results; err == nil && abandon != nil {
+				abandon()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+// probe checks the dependency periodically. A check that does not respond
+// within the probe timeout counts as failed and is abandoned, so is one in
+// flight when the container stops.
+func (c *Container) probe(e *entry, stop chan struct{}) {
+	defer c.probes.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.probeInterval):
+			err := c.check(ctx, e)
+			if ctx.Err() != nil {
+				return
+			}
+			c.setStatus(e, err)
+		}
+	}
+}
+
+func (c *Container) check(ctx context.Context, e *entry) error {
+	ctx, cancel := context.WithTimeout(ctx, c.probeTimeout)
+	defer cancel()
+
+	err := call(ctx, e.dep.Check, nil)
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("No response within %s", c.probeTimeout)
+	}
+	return err
+}
+
+func (c *Container) setStatus(e *entry, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e.status = Status{
+		Name:      e.name,
+		Healthy:   err == nil,
+		CheckedAt: now(),
+	}
+
+	if err != nil {
+		e.status.Error = err.Error()
+	}
+}
@@ -0,0 +1,389 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/context"
+)
+
+type recorder struct {
+	sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(call string) {
+	r.Lock()
+	defer r.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) Calls() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+func (r *recorder) dependency(name string) Funcs {
+	return Funcs{
+		OpenFunc: func() error {
+			r.record("open " + name)
+			return nil
+		},
+		CloseFunc: func() error {
+			r.record("close " + name)
+			return nil
+		},
+	}
+}
+
+var _ = Describe("Container", func() {
+	var (
+		rec       *recorder
+		container *Container
+	)
+
+	BeforeEach(func() {
+		rec = new(recorder)
+		container = New(
+			WithOpenTimeout(50*time.Millisecond),
+			WithRetryInterval(time.Millisecond),
+			WithProbeInterval(5*time.Millisecond),
+		)
+	})
+
+	AfterEach(func() {
+		container.Close()
+	})
+
+	Describe(".Start", func() {
+		It("opens dependencies in declared order", func() {
+			container.Add("a", rec.dependency("a"))
+			container.Add("b", rec.dependency("b"))
+			container.Add("c", rec.dependency("c"))
+
+			Expect(container.Start(context.Background())).To(Succeed())
+			Expect(rec.Calls()).To(Equal([]string{"open a", "open b", "open c"}))
+		})
+
+		It("retries until a dependency is ready", func() {
+			attempts := 0
+			container.Add("flaky", Funcs{
+				OpenFunc: func() error {
+					attempts++
+					if attempts < 3 {
+						return errors.New("not yet")
+					}
+					return nil
+				},
+			})
+
+			Expect(container.Start(context.Background())).To(Succeed())
+			Expect(attempts).To(Equal(3))
+		})
+
+		It("waits for the dependency to pass its health check", func() {
+			checks := 0
+			container.Add("warming", Funcs{
+				CheckFunc: func() error {
+					checks++
+					if checks < 2 {
+						return errors.New("warming up")
+					}
+					return nil
+				},
+			})
+
+			Expect(container.Start(context.Background())).To(Succeed())
+			Expect(container.Status().Healthy).To(BeTrue())
+		})
+
+		Context("when a dependency never becomes ready", func() {
+			BeforeEach(func() {
+				container.Add("a", rec.dependency("a"))
+				container.Add("b", rec.dependency("b"))
+				container.Add("broken", Funcs{
+					OpenFunc: func() error {
+						return errors.New("some-error")
+					},
+				})
+				container.Add("c", rec.dependency("c"))
+			})
+
+			It("returns an error after the timeout", func() {
+				err := container.Start(context.Background())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Error opening dependency 'broken': some-error"))
+			})
+
+			It("closes the already opened dependencies in reverse order", func() {
+				container.Start(context.Background())
+				Expect(rec.Calls()).To(Equal([]string{"open a", "open b", "close b", "close a"}))
+			})
+		})
+
+		Context("when a dependency hangs", func() {
+			var (
+				release chan struct{}
+				closed  chan struct{}
+			)
+
+			BeforeEach(func() {
+				release = make(chan struct{})
+				closed = make(chan struct{})
+
+				// late calls outlive the spec, keep them off the shared variables
+				released, closing := release, closed
+				container.Add("hanging", Funcs{
+					OpenFunc: func() error {
+						<-released
+						return nil
+					},
+					CloseFunc: func() error {
+						close(closing)
+						return nil
+					},
+				})
+			})
+
+			It("returns an error after the timeout", func() {
+				defer close(release)
+
+				err := container.Start(context.Background())
+				Expect(err).To(MatchError("Error opening dependency 'hanging': No response within 50ms"))
+			})
+
+			It("closes the dependency should it open after all", func() {
+				Expect(container.Start(context.Background())).ToNot(Succeed())
+				Consistently(closed).ShouldNot(BeClosed())
+
+				close(release)
+				Eventually(closed).Should(BeClosed())
+			})
+
+			It("gives up once the context is cancelled", func() {
+				defer close(release)
+
+				released := release
+				container = New(WithOpenTimeout(time.Hour))
+				container.Add("hanging", Funcs{
+					OpenFunc: func() error {
+						<-released
+						return nil
+					},
+				})
+
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+
+				err := container.Start(ctx)
+				Expect(err).To(MatchError("Error opening dependency 'hanging': context canceled"))
+			})
+		})
+
+		Context("when the context is cancelled while waiting", func() {
+			It("gives up", func() {
+				container = New(WithOpenTimeout(time.Hour), WithRetryInterval(time.Millisecond))
+				container.Add("broken", Funcs{
+					OpenFunc: func() error {
+						return errors.New("some-error")
+					},
+				})
+
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+
+				err := container.Start(ctx)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("context canceled"))
+			})
+		})
+	})
+
+	Describe(".Close", func() {
+		It("closes dependencies in reverse order", func() {
+			container.Add("a", rec.dependency("a"))
+			container.Add("b", rec.dependency("b"))
+			Expect(container.Start(context.Background())).To(Succeed())
+
+			Expect(container.Close()).To(Succeed())
+			Expect(rec.Calls()).To(Equal([]string{"open a", "open b", "close b", "close a"}))
+		})
+
+		It("closes every dependency only once", func() {
+			container.Add("a", rec.dependency("a"))
+			Expect(container.Start(context.Background())).To(Succeed())
+
+			container.Close()
+			container.Close()
+			Expect(rec.Calls()).To(Equal([]string{"open a", "close a"}))
+		})
+
+		It("reports errors but keeps closing", func() {
+			container.Add("a", rec.dependency("a"))
+			container.Add("b", Funcs{CloseFunc: func() error {
+				return errors.New("some-error")
+			}})
+			Expect(container.Start(context.Background())).To(Succeed())
+
+			err := container.Close()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("b: some-error"))
+			Expect(rec.Calls()).To(ContainElement("close a"))
+		})
+	})
+
+	Describe(".Run", func() {
+		BeforeEach(func() {
+			container.Add("a", rec.dependency("a"))
+		})
+
+		It("closes everything when the context is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- container.Run(ctx)
+			}()
+
+			Eventually(rec.Calls).Should(Equal([]string{"open a"}))
+			cancel()
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(rec.Calls()).To(Equal([]string{"open a", "close a"}))
+		})
+
+		It("closes everything upon receiving SIGTERM", func() {
+			// keep the test runner from handling the signal
+			signal.Reset(syscall.SIGTERM)
+
+			done := make(chan error)
+			go func() {
+				done <- container.Run(context.Background())
+			}()
+
+			Eventually(rec.Calls).Should(Equal([]string{"open a"}))
+			syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+			Eventually(done).Should(Receive(BeNil()))
+			Expect(rec.Calls()).To(Equal([]string{"open a", "close a"}))
+		})
+	})
+
+	Describe(".Status", func() {
+		var (
+			mutex    sync.Mutex
+			checkErr error
+		)
+
+		BeforeEach(func() {
+			checkErr = nil
+			container.Add("db", Funcs{CheckFunc: func() error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return checkErr
+			}})
+			container.Add("cache", Funcs{})
+			Expect(container.Start(context.Background())).To(Succeed())
+		})
+
+		It("reports every dependency as healthy", func() {
+			report := container.Status()
+			Expect(report.Healthy).To(BeTrue())
+			Expect(report.Dependencies).To(HaveLen(2))
+			Expect(report.Dependencies[0].Name).To(Equal("db"))
+			Expect(report.Dependencies[1].Name).To(Equal("cache"))
+		})
+
+		It("picks up failing health probes", func() {
+			mutex.Lock()
+			checkErr = errors.New("connection lost")
+			mutex.Unlock()
+
+			Eventually(func() bool {
+				return container.Status().Healthy
+			}).Should(BeFalse())
+
+			Expect(container.Status().Dependencies[0].Error).To(Equal("connection lost"))
+			Expect(container.Status().Dependencies[1].Healthy).To(BeTrue())
+		})
+	})
+
+	Describe("health probes", func() {
+		Context("when a check hangs", func() {
+			var release chan struct{}
+
+			BeforeEach(func() {
+				release = make(chan struct{})
+				released := release
+
+				var (
+					checks int
+					m      sync.Mutex
+				)
+
+				container = New(WithProbeInterval(5*time.Millisecond), WithProbeTimeout(20*time.Millisecond))
+				container.Add("hanging", Funcs{CheckFunc: func() error {
+					m.Lock()
+					checks++
+					first := checks == 1
+					m.Unlock()
+
+					if !first {
+						<-released
+					}
+					return nil
+				}})
+				Expect(container.Start(context.Background())).To(Succeed())
+			})
+
+			AfterEach(func() {
+				close(release)
+			})
+
+			It("reports the dependency as unhealthy", func() {
+				Eventually(func() string {
+					return container.Status().Dependencies[0].Error
+				}).Should(Equal("No response within 20ms"))
+			})
+
+			It("does not wait for it when closing", func() {
+				time.Sleep(10 * time.Millisecond)
+
+				done := make(chan error)
+				go func() {
+					done <- container.Close()
+				}()
+				Eventually(done).Should(Receive(BeNil()))
+			})
+		})
+	})
+
+	Describe(".HealthHandler", func() {
+		It("responds with 200 when healthy", func() {
+			container.Add("a", Funcs{})
+			Expect(container.Start(context.Background())).To(Succeed())
+
+			w := httptest.NewRecorder()
+			container.HealthHandler().ServeHTTP(w, &http.Request{})
+			Expect(w.Code).To(Equal(http.StatusOK))
+
+			var report Report
+			Expect(json.Unmarshal(w.Body.Bytes(), &report)).To(Succeed())
+			Expect(report.Healthy).To(BeTrue())
+		})
+
+		It("responds with 503 when unhealthy", func() {
+			container.Add("a", Funcs{})
+
+			w := httptest.NewRecorder()
+			container.HealthHandler().ServeHTTP(w, &http.Request{})
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+})
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/st3v/cfkit/env"
+)
+
+type Connector interface {
+	Name() string
+	Matches(svc env.Service) bool
+	Connect(svc env.Service) (interface{}, error)
+}
+
+type LiftFunc func(svc env.Service) (interface{}, error)
+
+type Matcher struct {
+	Labels  []string
+	Tags    []string
+	Schemes []string
+}
+
+func (m Matcher) Matches(svc env.Service) bool {
+	if containsFold(m.Labels, svc.Label) {
+		return true
+	}
+
+	for _, tag := range svc.Tags {
+		if containsFold(m.Tags, tag) {
+			return true
+		}
+	}
+
+	if uri, ok := svc.Credentials["uri"].(string); ok {
+		if u, err := url.Parse(uri); err == nil && containsFold(m.Schemes, u.Scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewConnector(name string, matcher Matcher, lift LiftFunc) Connector {
+	return &connector{name, matcher, lift}
+}
+
+type connector struct {
+	name    string
+	matcher Matcher
+	lift    LiftFunc
+}
+
+func (c *connector) Name() string {
+	return c.name
+}
+
+func (c *connector) Matches(svc env.Service) bool {
+	return c.matcher.Matches(svc)
+}
+
+func (c *connector) Connect(svc env.Service) (interface{}, error) {
+	return c.lift(svc)
+}
+
+var (
+	RabbitConnector = NewConnector(
+		"rabbitmq",
+		Matcher{
+			Labels:  []string{"p-rabbitmq", "p.rabbitmq", "rabbitmq", "cloudamqp"},
+			Tags:    []string{"rabbitmq", "amqp"},
+			Schemes: []string{"amqp", "amqps"},
+		},
+		func(svc env.Service) (interface{}, error) {
+			rabbit, err := rabbitLift(svc)
+			if err != nil {
+				return nil, err
+			}
+			return rabbit, nil
+		},
+	)
+
+	EurekaConnector = NewConnector(
+		"eureka",
+		Matcher{
+			Labels: []string{"p-service-registry", "eureka"},
+			Tags:   []string{"eureka"},
+		},
+		func(svc env.Service) (interface{}, error) {
+			client, err := eurekaLift(svc)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+		},
+	)
+
+	ConsulConnector = NewConnector(
+		"consul",
+		Matcher{
+			Labels: []string{"consul"},
+			Tags:   []string{"consul"},
+		},
+		func(svc env.Service) (interface{}, error) {
+			client, err := consulLift(svc)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+		},
+	)
+
+	EmailConnector = NewConnector(
+		"email",
+		Matcher{
+			Labels:  []string{"sendgrid", "smtp"},
+			Tags:    []string{"smtp", "email"},
+			Schemes: []string{"smtp", "smtps"},
+		},
+		func(svc env.Service) (interface{}, error) {
+			mail, err := smtpLift(svc)
+			if err != nil {
+				return nil, err
+			}
+			return mail, nil
+		},
+	)
+)
+
+type Binding struct {
+	Service   env.Service
+	Connector Connector
+}
+
+type Registry struct {
+	mutex      sync.RWMutex
+	connectors []Connector
+}
+
+func NewRegistry(connectors ...Connector) *Registry {
+	return &Registry{connectors: connectors}
+}
+
+func (r *Registry) Register(c Connector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.connectors = append(r.connectors, c)
+}
+
+// ConnectorFor returns the most recently registered connector matching the
+// service, so that custom connectors take precedence over built-in ones.
+func (r *Registry) ConnectorFor(svc env.Service) (Connector, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for i := len(r.connectors) - 1; i >= 0; i-- {
+		if r.connectors[i].Matches(svc) {
+			return r.connectors[i], true
+		}
+	}
+
+	return nil, false
+}
+
+func (r *Registry) Connect(name string) (interface{}, error) {
+	svc, err := env.ServiceWithName(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.ConnectService(svc)
+}
+
+func (r *Registry) ConnectService(svc env.Service) (interface{}, error) {
+	c, ok := r.ConnectorFor(svc)
+	if !ok {
+		return nil, fmt.Errorf("No connector found for service '%s'", svc.Name)
+	}
+	return c.Connect(svc)
+}
+
+func (r *Registry) Bindings() ([]Binding, error) {
+	services, err := env.Services()
+	if err != nil {
+		return []Binding{}, err
+	}
+
+	bindings := []Binding{}
+	for _, svc := range services {
+		if c, ok := r.ConnectorFor(svc); ok {
+			bindings = append(bindings, Binding{svc, c})
+		}
+	}
+
+	return bindings, nil
+}
+
+var DefaultRegistry = NewRegistry(RabbitConnector, EurekaConnector, ConsulConnector, EmailConnector)
+
+func Register(c Connector) {
+	DefaultRegistry.Register(c)
+}
+
+func Connect(name string) (interface{}, error) {
+	return DefaultRegistry.Connect(name)
+}
+
+func Bindings() ([]Binding, error) {
+	return DefaultRegistry.Bindings()
+}
+
+func containsFold(values []string, value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for _, v := range values {
+		if strings.ToUpper(v) == strings.ToUpper(value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/st3v/cfkit/discovery/consul"
+	"github.com/st3v/cfkit/env"
+)
+
+var (
+	DefaultConsulServiceName = "consul"
+	DefaultConsulProtocol    = "http"
+	DefaultConsulURI         = "http://localhost:8500"
+	DefaultConsulTimeout     = 10 * time.Second
+
+	DefaultConsulTokenPropertyKey      = "token"
+	DefaultConsulDatacenterPropertyKey = "datacenter"
+	DefaultConsulTimeoutPropertyKey    = "timeout"
+	DefaultConsulCheckTTLPropertyKey   = "check_ttl"
+	DefaultConsulTagsPropertyKey       = "tags"
+
+	DefaultConsulRegistrationMethodPropertyKey = "registration_method"
+	DefaultConsulInternalHostNamePropertyKey   = "internal_host_name"
+)
+
+func Consul() (*consul.Client, error) {
+	return ConsulWithName(DefaultConsulServiceName)
+}
+
+func ConsulWithName(name string) (*consul.Client, error) {
+	svc, err := env.ServiceWithName(name)
+	if err != nil {
+		return nil, err
+	}
+	return consulLift(svc)
+}
+
+func ConsulWithTag(tag string) (*consul.Client, error) {
+	svc, err := env.ServiceWithTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return consulLift(svc)
+}
+
+var consulLift = ConsulFromService
+
+// ConsulFromService returns a client for the Consul agent at the service URI,
+// the local agent if none is given. Timeout and check TTL are given in
+// seconds.
+func ConsulFromService(svc env.Service) (*consul.Client, error) {
+	uri, err := consulURI(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := credentialInt(svc, DefaultConsulTimeoutPropertyKey, int(DefaultConsulTimeout/time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []consul.Option{}
+
+	if token := credentialString(svc, DefaultConsulTokenPropertyKey); token != "" {
+		opts = append(opts, consul.WithToken(token))
+	}
+
+	if dc := credentialString(svc, DefaultConsulDatacenterPropertyKey); dc != "" {
+		opts = append(opts, consul.WithDatacenter(dc))
+	}
+
+	ttl, err := credentialInt(svc, DefaultConsulCheckTTLPropertyKey, 0)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		opts = append(opts, consul.WithCheckTTL(time.Duration(ttl)*time.Second))
+	}
+
+	tags, err := consulTags(svc)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		opts = append(opts, consul.WithTags(tags...))
+	}
+
+	registrationOpts, err := consulRegistrationOptions(svc)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, registrationOpts...)
+
+	return consul.NewClient(uri, time.Duration(timeout)*time.Second, opts...), nil
+}
+
+func consulURI(svc env.Service) (string, error) {
+	raw, ok := svc.Credentials["uri"]
+	if !ok {
+		return DefaultConsulURI, nil
+	}
+
+	uri, ok := raw.(string)
+	if !ok || uri == "" {
+		return "", errors.New("Invalid Consul URI")
+	}
+
+	if !strings.Contains(uri, "://") {
+		uri = fmt.Sprintf("%s://%s", DefaultConsulProtocol, uri)
+	}
+
+	if _, err := url.Parse(uri); err != nil {
+		return "", fmt.Errorf("Error parsing Consul URI: %s", err)
+	}
+
+	return uri, nil
+}
+
+func consulTags(svc env.Service) ([]string, error) {
+	raw, ok := svc.Credentials[DefaultConsulTagsPropertyKey]
+	if !ok {
+		return nil, nil
+	}
+
+	rawTags, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("Invalid Consul tags")
+	}
+
+	tags := make([]string, len(rawTags))
+	for i, rawTag := range rawTags {
+		tag, ok := rawTag.(string)
+		if !ok {
+			return nil, errors.New("Invalid Consul tags")
+		}
+		tags[i] = tag
+	}
+
+	return tags, nil
+}
+
+// consulRegistrationOptions reads whether the app registers its route or,
+// with registration_method set to direct, its container address.
+func consulRegistrationOptions(svc env.Service) ([]consul.Option, error) {
+	opts := []consul.Option{}
+
+	raw, ok := svc.Credentials[DefaultConsulRegistrationMethodPropertyKey]
+	if !ok {
+		return opts, nil
+	}
+
+	method, _ := raw.(string)
+	switch mode := consul.RegistrationMode(method); mode {
+	case consul.RouteRegistration, consul.DirectRegistration:
+		opts = append(opts, consul.WithRegistrationMode(mode))
+	default:
+		return nil, fmt.Errorf("Invalid Consul registration method '%v'", raw)
+	}
+
+	if host, ok := svc.Credentials[DefaultConsulInternalHostNamePropertyKey].(string); ok && host != "" {
+		opts = append(opts, consul.WithInternalHostName(host))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/st3v/cfkit/discovery/consul"
+	"github.com/st3v/cfkit/env"
+)
+
+var _ = Describe(".Consul", func() {
+	var (
+		origLift = consulLift
+		lifted   env.Service
+	)
+
+	BeforeEach(func() {
+		os.Setenv("VCAP_SERVICES", vcapServicesConsul)
+
+		lifted = env.Service{}
+
+		consulLift = func(svc env.Service) (*consul.Client, error) {
+			lifted = svc
+			return origLift(svc)
+		}
+	})
+
+	AfterEach(func() {
+		consulLift = origLift
+		os.Unsetenv("VCAP_SERVICES")
+	})
+
+	It("lifts the correct service", func() {
+		Consul()
+		Expect(lifted.Name).To(Equal("consul"))
+	})
+
+	It("returns a client with the correct URI", func() {
+		c, err := Consul()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.URI()).To(Equal("http://consul.example.com:8500"))
+	})
+
+	Describe(".ConsulWithName", func() {
+		It("lifts the correct service", func() {
+			_, err := ConsulWithName("other-consul")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lifted.Name).To(Equal("other-consul"))
+		})
+
+		Context("when the service cannot be found", func() {
+			It("returns a corresponding error", func() {
+				_, err := ConsulWithName("unknown")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe(".ConsulWithTag", func() {
+		It("lifts the correct service", func() {
+			_, err := ConsulWithTag("service-discovery")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lifted.Name).To(Equal("other-consul"))
+		})
+	})
+})
+
+var _ = Describe(".ConsulFromService", func() {
+	var svc env.Service
+
+	BeforeEach(func() {
+		svc = env.Service{Credentials: map[string]interface{}{}}
+	})
+
+	Context("when the service does not have any properties", func() {
+		It("uses the local agent and the defaults", func() {
+			c, err := ConsulFromService(svc)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.URI()).To(Equal(DefaultConsulURI))
+			Expect(c.Timeout()).To(Equal(DefaultConsulTimeout))
+			Expect(c.CheckTTL()).To(Equal(consul.DefaultCheckTTL))
+			Expect(c.RegistrationMode()).To(Equal(consul.RouteRegistration))
+		})
+	})
+
+	Context("when the service specifies properties", func() {
+		BeforeEach(func() {
+			svc.Credentials = map[string]interface{}{
+				"uri":                 "consul.example.com:8500",
+				"datacenter":          "dc-2",
+				"timeout":             float64(3),
+				"check_ttl":           "15",
+				"tags":                []interface{}{"v1", "canary"},
+				"registration_method": "direct",
+			}
+		})
+
+		It("uses them", func() {
+			c, err := ConsulFromService(svc)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.URI()).To(Equal("http://consul.example.com:8500"))
+			Expect(c.Datacenter()).To(Equal("dc-2"))
+			Expect(c.Timeout()).To(Equal(3 * time.Second))
+			Expect(c.CheckTTL()).To(Equal(15 * time.Second))
+			Expect(c.Tags()).To(Equal([]string{"v1", "canary"}))
+			Expect(c.RegistrationMode()).To(Equal(consul.DirectRegistration))
+		})
+	})
+
+	Context("when the uri is empty", func() {
+		It("returns a corresponding error", func() {
+			svc.Credentials["uri"] = ""
+			_, err := ConsulFromService(svc)
+			Expect(err).To(MatchError("Invalid Consul URI"))
+		})
+	})
+
+	Context("when the tags have an invalid type", func() {
+		It("returns a corresponding error", func() {
+			svc.Credentials["tags"] = "v1"
+			_, err := ConsulFromService(svc)
+			Expect(err).To(MatchError("Invalid Consul tags"))
+		})
+	})
+
+	Context("when the check_ttl is invalid", func() {
+		It("returns a corresponding error", func() {
+			svc.Credentials["check_ttl"] = "soon"
+			_, err := ConsulFromService(svc)
+			Expect(err).To(MatchError("Invalid check_ttl 'soon'"))
+		})
+	})
+
+	Context("when the registration method is unknown", func() {
+		It("returns a corresponding error", func() {
+			svc.Credentials["registration_method"] = "magic"
+			_, err := ConsulFromService(svc)
+			Expect(err).To(MatchError("Invalid Consul registration method 'magic'"))
+		})
+	})
+
+	Context("when talking to the agent", func() {
+		var (
+			server *httptest.Server
+			mutex  sync.Mutex
+			token  string
+			body   map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/v1/agent/service/register" {
+					http.NotFound(w, req)
+					return
+				}
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				token = req.Header.Get("X-Consul-Token")
+				json.NewDecoder(req.Body).Decode(&body)
+			}))
+
+			svc.Credentials["uri"] = server.URL
+			svc.Credentials["token"] = "some-token"
+			svc.Credentials["tags"] = []interface{}{"v1"}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("registers the app with the configured credentials", func() {
+			c, err := ConsulFromService(svc)
+			Expect(err).ToNot(HaveOccurred())
+			defer c.Close()
+
+			app := env.App{
+				Name:     "app-name",
+				URIs:     []string{"app-name.example.com"},
+				Instance: env.AppInstance{ID: "instance-id"},
+			}
+			Expect(c.Register(app)).To(Succeed())
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			Expect(token).To(Equal("some-token"))
+			Expect(body["ID"]).To(Equal("app-name-instance-id"))
+			Expect(body["Tags"]).To(Equal([]interface{}{"v1"}))
+		})
+	})
+})
+
+var vcapServicesConsul = `{
+	"user-provided": [
+	 {
+		"credentials": {
+		 "uri": "consul.example.com:8500"
+		},
+		"label": "user-provided",
+		"name": "consul",
+		"tags": []
+	 },
+	 {
+		"credentials": {
+		 "uri": "http://other-consul.example.com:8500"
+		},
+		"label": "consul",
+		"name": "other-consul",
+		"tags": ["service-discovery"]
+	 }
+	]
+}`
@@ -0,0 +1,19 @@
+package service
+
+import "github.com/st3v/cfkit/discovery"
+
+// Discovery returns a client for the registry bound to the app, to be passed
+// to discovery.Enable. Eureka takes precedence over Consul.
+func Discovery() (discovery.Client, error) {
+	client, err := Eureka()
+	if err == nil {
+		return client, nil
+	}
+
+	consul, consulErr := Consul()
+	if consulErr != nil {
+		// report why Eureka, the default, is missing
+		return nil, err
+	}
+	return consul, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/st3v/cfkit/discovery/consul"
+	"github.com/st3v/cfkit/discovery/eureka"
+)
+
+var _ = Describe(".Discovery", func() {
+	AfterEach(func() {
+		os.Unsetenv("VCAP_SERVICES")
+	})
+
+	Context("when a Eureka service is bound", func() {
+		BeforeEach(func() {
+			os.Setenv("VCAP_SERVICES", vcapServicesEureka)
+		})
+
+		It("returns a Eureka client", func() {
+			c, err := Discovery()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c).To(BeAssignableToTypeOf(&eureka.Client{}))
+		})
+	})
+
+	Context("when only a Consul service is bound", func() {
+		BeforeEach(func() {
+			os.Setenv("VCAP_SERVICES", vcapServicesConsul)
+		})
+
+		It("returns a Consul client", func() {
+			c, err := Discovery()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c).To(BeAssignableToTypeOf(&consul.Client{}))
+		})
+	})
+
+	Context("when no registry is bound", func() {
+		BeforeEach(func() {
+			os.Setenv("VCAP_SERVICES", "{}")
+		})
+
+		It("returns an error", func() {
+			c, err := Discovery()
+			Expect(err).To(HaveOccurred())
+			Expect(c).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,584 @@
+package service
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/st3v/cfkit/env"
+)
+
+var (
+	DefaultEmailTag = "smtp"
+
+	DefaultSMTPPort    = 587
+	DefaultSMTPSPort   = 465
+	DefaultSMTPTimeout = 10 * time.Second
+)
+
+var (
+	smtpDialer = net.DialTimeout
+	mailNow    = time.Now
+)
+
+type SMTP struct {
+	host        string
+	port        int
+	username    string
+	password    string
+	implicitTLS bool
+
+	// TLSConfig is used for both STARTTLS and implicit TLS. The server
+	// certificate is verified against the configured host unless the config
+	// names another server.
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+
+	// RequireTLS refuses to send over plain text if the server does not
+	// offer STARTTLS. It is set for services with credentials.
+	RequireTLS bool
+}
+
+func (s *SMTP) Host() string {
+	return s.host
+}
+
+func (s *SMTP) Port() int {
+	return s.port
+}
+
+func (s *SMTP) Username() string {
+	return s.username
+}
+
+func (s *SMTP) ImplicitTLS() bool {
+	return s.implicitTLS
+}
+
+func (s *SMTP) Addr() string {
+	return net.JoinHostPort(s.host, strconv.Itoa(s.port))
+}
+
+func (s *SMTP) Send(msg *Message) error {
+	recipients := msg.Recipients()
+	if msg.From == "" || len(recipients) == 0 {
+		return errors.New("Error sending email: sender and recipients are required")
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	client, err := s.dial()
+	if err != nil {
+		return fmt.Errorf("Error connecting to SMTP server '%s': %s", s.Addr(), err)
+	}
+	defer client.Close()
+
+	if !s.implicitTLS {
+		ok, _ := client.Extension("STARTTLS")
+		switch {
+		case ok:
+			if err := client.StartTLS(s.tlsConfig()); err != nil {
+				return fmt.Errorf("Error starting TLS: %s", err)
+			}
+		case s.RequireTLS:
+			return errors.New("Error starting TLS: server does not support STARTTLS")
+		}
+	}
+
+	if s.username != "" {
+		auth := smtp.PlainAuth("", s.username, s.password, s.host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("Error authenticating with SMTP server: %s", err)
+		}
+	}
+
+	if err := client.Mail(address(msg.From)); err != nil {
+		return fmt.Errorf("Error setting sender '%s': %s", msg.From, err)
+	}
+
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(address(rcpt)); err != nil {
+			return fmt.Errorf("Error adding recipient '%s': %s", rcpt, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("Error sending email: %s", err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("Error sending email: %s", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Error sending email: %s", err)
+	}
+
+	return client.Quit()
+}
+
+func (s *SMTP) dial() (*smtp.Client, error) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultSMTPTimeout
+	}
+
+	conn, err := smtpDialer("tcp", s.Addr(), timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if s.implicitTLS {
+		tlsConn := tls.Client(conn, s.tlsConfig())
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (s *SMTP) tlsConfig() *tls.Config {
+	if s.TLSConfig == nil {
+		return &tls.Config{ServerName: s.host}
+	}
+
+	config := copyTLSConfig(s.TLSConfig)
+	if config.ServerName == "" {
+		config.ServerName = s.host
+	}
+	return config
+}
+
+// copyTLSConfig copies the client side settings, leaving the caller's config
+// untouched when filling in the server name.
+func copyTLSConfig(config *tls.Config) *tls.Config {
+	return &tls.Config{
+		Rand:               config.Rand,
+		Time:               config.Time,
+		Certificates:       config.Certificates,
+		RootCAs:            config.RootCAs,
+		NextProtos:         config.NextProtos,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		CipherSuites:       config.CipherSuites,
+		ClientSessionCache: config.ClientSessionCache,
+		MinVersion:         config.MinVersion,
+		MaxVersion:         config.MaxVersion,
+		CurvePreferences:   config.CurvePreferences,
+	}
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type Message struct {
+	From        string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	ReplyTo     string
+	Subject     string
+	Text        string
+	HTML        string
+	Headers     map[string]string
+	Attachments []Attachment
+}
+
+func (m *Message) Attach(filename string, data []byte) {
+	m.Attachments = append(m.Attachments, Attachment{
+		Filename: filename,
+		Data:     data,
+	})
+}
+
+func (m *Message) Recipients() []string {
+	recipients := []string{}
+	recipients = append(recipients, m.To...)
+	recipients = append(recipients, m.Cc...)
+	recipients = append(recipients, m.Bcc...)
+	return recipients
+}
+
+// Bytes renders the message as RFC 5322 with MIME parts. Bcc recipients are
+// intentionally left out of the headers.
+func (m *Message) Bytes() ([]byte, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", m.From)
+	if len(m.To) > 0 {
+		header.Set("To", strings.Join(m.To, ", "))
+	}
+	if len(m.Cc) > 0 {
+		header.Set("Cc", strings.Join(m.Cc, ", "))
+	}
+	if m.ReplyTo != "" {
+		header.Set("Reply-To", m.ReplyTo)
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", mailNow().Format(time.RFC1123Z))
+	header.Set("MIME-Version", "1.0")
+	for k, v := range m.Headers {
+		header.Set(k, v)
+	}
+
+	bodyHeader, body, err := m.body()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Attachments) == 0 {
+		for k, v := range bodyHeader {
+			header[k] = v
+		}
+		writeHeader(buf, header)
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(buf)
+	header.Set("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	writeHeader(buf, header)
+
+	part, err := mixed.CreatePart(bodyHeader)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding email body: %s", err)
+	}
+	part.Write(body)
+
+	for _, a := range m.Attachments {
+		if err := writeAttachment(mixed, a); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mixed.Close(); err != nil {
+		return nil, fmt.Errorf("Error encoding email: %s", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// validate rejects header values that would end their header line early and
+// inject headers of their own.
+func (m *Message) validate() error {
+	fields := map[string][]string{
+		"From":     {m.From},
+		"To":       m.To,
+		"Cc":       m.Cc,
+		"Bcc":      m.Bcc,
+		"Reply-To": {m.ReplyTo},
+	}
+	for k, v := range m.Headers {
+		fields[k] = append(fields[k], k, v)
+	}
+	for _, a := range m.Attachments {
+		fields["Content-Type"] = append(fields["Content-Type"], a.ContentType)
+	}
+
+	for name, values := range fields {
+		for _, v := range values {
+			if strings.ContainsAny(v, "\r\n") {
+				return fmt.Errorf("Invalid email header '%s': contains line break", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// body renders the text and/or html content along with its content headers.
+// Messages carrying both are sent as multipart/alternative.
+func (m *Message) body() (textproto.MIMEHeader, []byte, error) {
+	buf := new(bytes.Buffer)
+
+	if m.Text != "" && m.HTML != "" {
+		alt := multipart.NewWriter(buf)
+
+		for _, p := range []struct{ contentType, content string }{
+			{"text/plain", m.Text},
+			{"text/html", m.HTML},
+		} {
+			part, err := alt.CreatePart(textHeader(p.contentType))
+			if err != nil {
+				return nil, nil, fmt.Errorf("Error encoding email body: %s", err)
+			}
+			if err := writeQuotedPrintable(part, p.content); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if err := alt.Close(); err != nil {
+			return nil, nil, fmt.Errorf("Error encoding email body: %s", err)
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "multipart/alternative; boundary="+alt.Boundary())
+		return header, buf.Bytes(), nil
+	}
+
+	contentType, content := "text/plain", m.Text
+	if m.HTML != "" {
+		contentType, content = "text/html", m.HTML
+	}
+
+	if err := writeQuotedPrintable(buf, content); err != nil {
+		return nil, nil, err
+	}
+
+	return textHeader(contentType), buf.Bytes(), nil
+}
+
+func writeAttachment(w *multipart.Writer, a Attachment) error {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(a.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", "attachment; "+filenameParam(a.Filename))
+
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return fmt.Errorf("Error encoding attachment '%s': %s", a.Filename, err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	for len(encoded) > 76 {
+		io.WriteString(part, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	_, err = io.WriteString(part, encoded+"\r\n")
+	return err
+}
+
+// filenameParam quotes plain ASCII file names and encodes all others as
+// specified by RFC 2231.
+func filenameParam(name string) string {
+	plain := true
+	for i := 0; i < len(name); i++ {
+		if name[i] < ' ' || name[i] > '~' {
+			plain = false
+			break
+		}
+	}
+
+	if plain {
+		escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name)
+		return fmt.Sprintf(`filename="%s"`, escaped)
+	}
+
+	encoded := new(bytes.Buffer)
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(encoded, "%%%02X", b)
+		}
+	}
+	return "filename*=utf-8''" + encoded.String()
+}
+
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+func textHeader(contentType string) textproto.MIMEHeader {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return header
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, content); err != nil {
+		return fmt.Errorf("Error encoding email body: %s", err)
+	}
+	return qp.Close()
+}
+
+func writeHeader(w io.Writer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	io.WriteString(w, "\r\n")
+}
+
+// address strips display names, e.g. "Jane <jane@example.com>".
+func address(addr string) string {
+	if start := strings.LastIndex(addr, "<"); start >= 0 {
+		if end := strings.LastIndex(addr, ">"); end > start {
+			return addr[start+1 : end]
+		}
+	}
+	return strings.TrimSpace(addr)
+}
+
+func Mail() (*SMTP, error) {
+	return MailWithTag(DefaultEmailTag)
+}
+
+func MailWithTag(tag string) (*SMTP, error) {
+	svc, err := env.ServiceWithTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return smtpLift(svc)
+}
+
+func MailWithName(name string) (*SMTP, error) {
+	svc, err := env.ServiceWithName(name)
+	if err != nil {
+		return nil, err
+	}
+	return smtpLift(svc)
+}
+
+var smtpLift = SMTPFromService
+
+func SMTPFromService(svc env.Service) (*SMTP, error) {
+	if uri, ok := svc.Credentials["uri"].(string); ok && uri != "" {
+		return smtpFromURI(uri)
+	}
+
+	host := credentialString(svc, "hostname")
+	if host == "" {
+		host = credentialString(svc, "host")
+	}
+	if host == "" {
+		return nil, errors.New("Missing SMTP hostname")
+	}
+
+	port, err := credentialInt(svc, "port", DefaultSMTPPort)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SMTP{
+		host:        host,
+		port:        port,
+		username:    credentialString(svc, "username"),
+		password:    credentialString(svc, "password"),
+		implicitTLS: port == DefaultSMTPSPort,
+	}
+	s.RequireTLS = s.username != "" || s.password != ""
+
+	return s, nil
+}
+
+func smtpFromURI(uri string) (*SMTP, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing SMTP URI: %s", err)
+	}
+
+	s := &SMTP{}
+	switch u.Scheme {
+	case "smtp":
+		s.port = DefaultSMTPPort
+	case "smtps":
+		s.port = DefaultSMTPSPort
+		s.implicitTLS = true
+	default:
+		return nil, errors.New("Invalid SMTP URI")
+	}
+
+	s.host = u.Host
+	if host, port, err := net.SplitHostPort(u.Host); err == nil {
+		s.host = host
+		if s.port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("Invalid SMTP port '%s'", port)
+		}
+	}
+
+	if s.host == "" {
+		return nil, errors.New("Missing SMTP hostname")
+	}
+
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	s.RequireTLS = s.username != "" || s.password != ""
+
+	return s, nil
+}
+
+func credentialString(svc env.Service, key string) string {
+	value, _ := svc.Credentials[key].(string)
+	return value
+}
+
+func credentialInt(svc env.Service, key string, fallback int) (int, error) {
+	switch value := svc.Credentials[key].(type) {
+	case nil:
+		return fallback, nil
+	case int:
+		return value, nil
+	case float64:
+		return int(value), nil
+	case string:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid %s '%s'", key, value)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("Invalid %s '%v'", key, value)
+	}
+}
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -23,6 +26,22 @@ var (
 	DefaultEurekaPortPropertyKey         = "port"
 	DefaultEurekaTimeoutPropertyKey      = "timeout"
 	DefaultEurekaPollIntervalPropertyKey = "poll_interval"
+
+	DefaultEurekaLeaseRenewalIntervalPropertyKey = "lease_renewal_interval"
+	DefaultEurekaLeaseDurationPropertyKey        = "lease_duration"
+
+	DefaultEurekaZonesPropertyKey          = "zones"
+	DefaultEurekaZonePropertyKey           = "zone"
+	DefaultEurekaRegionPropertyKey         = "region"
+	DefaultEurekaPreferSameZonePropertyKey = "prefer_same_zone"
+
+	DefaultEurekaRegistrationMethodPropertyKey = "registration_method"
+	DefaultEurekaInternalHostNamePropertyKey   = "internal_host_name"
+
+	DefaultEurekaCACertPropertyKey            = "ca_cert"
+	DefaultEurekaClientCertPropertyKey        = "client_cert"
+	DefaultEurekaClientKeyPropertyKey         = "client_key"
+	DefaultEurekaSkipSSLValidationPropertyKey = "skip_ssl_validation"
 )
 
 func Eureka() (*eureka.Client, error) {
@@ -48,16 +67,203 @@ func EurekaWithTag(tag string) (*eureka.Client, error) {
 var eurekaLift = EurekaFromService
 
 func EurekaFromService(svc env.Service) (*eureka.Client, error) {
-	uris, err := serviceURIs(svc)
+	zoneOpts, err := eurekaZoneOptions(svc)
 	if err != nil {
 		return nil, err
 	}
 
+	// per-zone service URLs make the flat uri and uris fields optional
+	uris := []string{}
+	if len(zoneOpts) == 0 || svc.Credentials["uri"] != nil || svc.Credentials["uris"] != nil {
+		if uris, err = serviceURIs(svc); err != nil {
+			return nil, err
+		}
+	}
+
 	port := eurekaPort(svc)
 	timeout := eurekaTimeout(svc)
 	pollInterval := eurekaPollInterval(svc)
 
-	return eureka.NewClient(uris, port, timeout, pollInterval), nil
+	opts, err := eurekaLeaseOptions(svc)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, zoneOpts...)
+
+	registrationOpts, err := eurekaRegistrationOptions(svc)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, registrationOpts...)
+
+	tlsOpts, err := eurekaTLSOptions(svc)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, tlsOpts...)
+
+	return eureka.NewClient(uris, port, timeout, pollInterval, opts...), nil
+}
+
+// eurekaZoneOptions reads the service URLs per zone, e.g.
+// {"zone-a": ["eureka-a1", "eureka-a2"], "zone-b": "eureka-b"}, as well as
+// the zone and region of the app. Knowing its zone, the app prefers Eureka
+// peers and instances in the same zone unless prefer_same_zone is false.
+func eurekaZoneOptions(svc env.Service) ([]eureka.Option, error) {
+	opts := []eureka.Option{}
+
+	if raw, ok := svc.Credentials[DefaultEurekaZonesPropertyKey]; ok {
+		zones, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Invalid Eureka zones")
+		}
+
+		names := make([]string, 0, len(zones))
+		for name := range zones {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			uris, err := zoneURIs(name, zones[name])
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, eureka.WithZonePeers(name, uris...))
+		}
+	}
+
+	if region, ok := svc.Credentials[DefaultEurekaRegionPropertyKey].(string); ok && region != "" {
+		opts = append(opts, eureka.WithRegion(region))
+	}
+
+	zone, ok := svc.Credentials[DefaultEurekaZonePropertyKey].(string)
+	if !ok || zone == "" {
+		return opts, nil
+	}
+	opts = append(opts, eureka.WithZone(zone))
+
+	if prefer, ok := svc.Credentials[DefaultEurekaPreferSameZonePropertyKey].(bool); !ok || prefer {
+		opts = append(opts, eureka.WithPreferSameZone())
+	}
+
+	return opts, nil
+}
+
+func zoneURIs(zone string, raw interface{}) ([]string, error) {
+	var rawURIs []interface{}
+	switch value := raw.(type) {
+	case string:
+		rawURIs = []interface{}{value}
+	case []interface{}:
+		rawURIs = value
+	}
+
+	if len(rawURIs) == 0 {
+		return nil, fmt.Errorf("Missing or invalid service URIs for zone '%s'", zone)
+	}
+
+	uris := make([]string, len(rawURIs))
+	for i, rawURI := range rawURIs {
+		uri, ok := rawURI.(string)
+		if !ok {
+			return nil, fmt.Errorf("Missing or invalid service URIs for zone '%s'", zone)
+		}
+
+		var err error
+		if uris[i], err = augmentURI(uri); err != nil {
+			return nil, err
+		}
+	}
+
+	return uris, nil
+}
+
+// eurekaLeaseOptions reads the optional lease settings, given in seconds.
+func eurekaLeaseOptions(svc env.Service) ([]eureka.Option, error) {
+	opts := []eureka.Option{}
+
+	interval, err := credentialInt(svc, DefaultEurekaLeaseRenewalIntervalPropertyKey, 0)
+	if err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		opts = append(opts, eureka.WithLeaseRenewalInterval(time.Duration(interval)*time.Second))
+	}
+
+	duration, err := credentialInt(svc, DefaultEurekaLeaseDurationPropertyKey, 0)
+	if err != nil {
+		return nil, err
+	}
+	if duration > 0 {
+		opts = append(opts, eureka.WithLeaseDuration(time.Duration(duration)*time.Second))
+	}
+
+	return opts, nil
+}
+
+// eurekaRegistrationOptions reads whether the app registers its route or,
+// with registration_method set to direct, its container address.
+func eurekaRegistrationOptions(svc env.Service) ([]eureka.Option, error) {
+	opts := []eureka.Option{}
+
+	raw, ok := svc.Credentials[DefaultEurekaRegistrationMethodPropertyKey]
+	if !ok {
+		return opts, nil
+	}
+
+	method, _ := raw.(string)
+	switch mode := eureka.RegistrationMode(method); mode {
+	case eureka.RouteRegistration, eureka.DirectRegistration:
+		opts = append(opts, eureka.WithRegistrationMode(mode))
+	default:
+		return nil, fmt.Errorf("Invalid Eureka registration method '%v'", raw)
+	}
+
+	if host, ok := svc.Credentials[DefaultEurekaInternalHostNamePropertyKey].(string); ok && host != "" {
+		opts = append(opts, eureka.WithInternalHostName(host))
+	}
+
+	return opts, nil
+}
+
+// eurekaTLSOptions reads the PEM encoded CA and client certificates used to
+// connect to Eureka. Basic auth credentials are taken from the service URIs.
+func eurekaTLSOptions(svc env.Service) ([]eureka.Option, error) {
+	opts := []eureka.Option{}
+
+	config := &tls.Config{}
+	configured := false
+
+	if ca, ok := svc.Credentials[DefaultEurekaCACertPropertyKey].(string); ok && ca != "" {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM([]byte(ca)) {
+			return nil, errors.New("Invalid Eureka CA certificate")
+		}
+		configured = true
+	}
+
+	if skip, ok := svc.Credentials[DefaultEurekaSkipSSLValidationPropertyKey].(bool); ok && skip {
+		config.InsecureSkipVerify = true
+		configured = true
+	}
+
+	if configured {
+		opts = append(opts, eureka.WithTLSConfig(config))
+	}
+
+	cert, _ := svc.Credentials[DefaultEurekaClientCertPropertyKey].(string)
+	key, _ := svc.Credentials[DefaultEurekaClientKeyPropertyKey].(string)
+	if cert == "" && key == "" {
+		return opts, nil
+	}
+
+	pair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Eureka client certificate: %s", err)
+	}
+
+	return append(opts, eureka.WithClientCertificate(pair)), nil
 }
 
 func serviceURIs(svc env.Service) ([]string, error) {
@@ -113,6 +319,7 @@ func augmentURI(uri string) (string, error) {
 	return url.String(), nil
 }
 
+// eurekaPort is only passed on for compatibility, eureka.Client ignores it.
 func eurekaPort(svc env.Service) int {
 	if port, ok := svc.Credentials[DefaultEurekaPortPropertyKey].(int); ok {
 		return port
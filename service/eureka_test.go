@@ -1,7 +1,18 @@
 package service
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -420,6 +431,17 @@ var _ = Describe(".EurekaFromService", func() {
 			c, _ := EurekaFromService(svc)
 			Expect(c.PollInterval()).To(Equal(30 * time.Second))
 		})
+
+		It("uses the default lease", func() {
+			c, _ := EurekaFromService(svc)
+			Expect(c.LeaseRenewalInterval()).To(Equal(eureka.DefaultLeaseRenewalInterval))
+			Expect(c.LeaseDuration()).To(Equal(eureka.DefaultLeaseDuration))
+		})
+
+		It("registers the route of the app", func() {
+			c, _ := EurekaFromService(svc)
+			Expect(c.RegistrationMode()).To(Equal(eureka.RouteRegistration))
+		})
 	})
 
 	Context("when the service specifies optional properties", func() {
@@ -447,8 +469,310 @@ var _ = Describe(".EurekaFromService", func() {
 			Expect(c.PollInterval()).To(Equal(89 * time.Second))
 		})
 	})
+
+	Context("when the service specifies zones", func() {
+		var svc env.Service
+
+		BeforeEach(func() {
+			svc = env.Service{
+				Credentials: map[string]interface{}{
+					"zones": map[string]interface{}{
+						"zone-b": "eureka-b.cfapps.io",
+						"zone-a": []interface{}{"https://eureka-a1.cfapps.io/eureka", "eureka-a2.cfapps.io"},
+					},
+					"zone":   "zone-b",
+					"region": "us-east",
+				},
+			}
+		})
+
+		It("does not require a uri field", func() {
+			_, err := EurekaFromService(svc)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("uses the service URLs of all zones", func() {
+			c, _ := EurekaFromService(svc)
+			Expect(c.URIs()).To(Equal([]string{
+				"https://eureka-a1.cfapps.io/eureka",
+				"http://eureka-a2.cfapps.io/eureka",
+				"http://eureka-b.cfapps.io/eureka",
+			}))
+		})
+
+		It("assigns the zones to the peers", func() {
+			c, _ := EurekaFromService(svc)
+			peers := c.Peers()
+			Expect(peers[0].Zone).To(Equal("zone-a"))
+			Expect(peers[2].Zone).To(Equal("zone-b"))
+		})
+
+		It("uses the specified zone and region", func() {
+			c, _ := EurekaFromService(svc)
+			Expect(c.Zone()).To(Equal("zone-b"))
+			Expect(c.Region()).To(Equal("us-east"))
+		})
+
+		It("prefers instances in the same zone", func() {
+			c, _ := EurekaFromService(svc)
+			Expect(c.PreferSameZone()).To(BeTrue())
+		})
+
+		Context("and the preference is disabled", func() {
+			BeforeEach(func() {
+				svc.Credentials["prefer_same_zone"] = false
+			})
+
+			It("does not prefer instances in the same zone", func() {
+				c, _ := EurekaFromService(svc)
+				Expect(c.PreferSameZone()).To(BeFalse())
+			})
+		})
+
+		Context("and a uri field", func() {
+			BeforeEach(func() {
+				svc.Credentials["uri"] = "eureka-default.cfapps.io"
+			})
+
+			It("uses it as well", func() {
+				c, _ := EurekaFromService(svc)
+				Expect(c.URIs()).To(HaveLen(4))
+				Expect(c.URIs()[0]).To(Equal("http://eureka-default.cfapps.io/eureka"))
+			})
+		})
+
+		Context("that are invalid", func() {
+			BeforeEach(func() {
+				svc.Credentials["zones"] = []interface{}{"eureka-a.cfapps.io"}
+			})
+
+			It("returns a corresponding error", func() {
+				_, err := EurekaFromService(svc)
+				Expect(err).To(MatchError("Invalid Eureka zones"))
+			})
+		})
+
+		Context("with a zone without URIs", func() {
+			BeforeEach(func() {
+				svc.Credentials["zones"] = map[string]interface{}{"zone-a": []interface{}{}}
+			})
+
+			It("returns a corresponding error", func() {
+				_, err := EurekaFromService(svc)
+				Expect(err).To(MatchError("Missing or invalid service URIs for zone 'zone-a'"))
+			})
+		})
+	})
+
+	Context("when the service specifies lease properties", func() {
+		var svc env.Service
+
+		BeforeEach(func() {
+			svc = env.Service{
+				Credentials: map[string]interface{}{
+					"uri":                    "http://my-host/eureka",
+					"lease_renewal_interval": float64(10),
+					"lease_duration":         "40",
+				},
+			}
+		})
+
+		It("uses the specified lease", func() {
+			c, err := EurekaFromService(svc)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.LeaseRenewalInterval()).To(Equal(10 * time.Second))
+			Expect(c.LeaseDuration()).To(Equal(40 * time.Second))
+		})
+
+		It("heartbeats at the renewal interval", func() {
+			c, _ := EurekaFromService(svc)
+			Expect(c.HeartbeatInterval()).To(Equal(10 * time.Second))
+		})
+
+		Context("that are invalid", func() {
+			BeforeEach(func() {
+				svc.Credentials["lease_duration"] = "forever"
+			})
+
+			It("returns a corresponding error", func() {
+				_, err := EurekaFromService(svc)
+				Expect(err).To(MatchError("Invalid lease_duration 'forever'"))
+			})
+		})
+	})
+
+	Context("when the service specifies a registration method", func() {
+		var svc env.Service
+
+		BeforeEach(func() {
+			svc = env.Service{
+				Credentials: map[string]interface{}{
+					"uri":                 "http://my-host/eureka",
+					"registration_method": "direct",
+				},
+			}
+		})
+
+		It("uses the specified registration mode", func() {
+			c, err := EurekaFromService(svc)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.RegistrationMode()).To(Equal(eureka.DirectRegistration))
+		})
+
+		Context("that is invalid", func() {
+			BeforeEach(func() {
+				svc.Credentials["registration_method"] = "carrier-pigeon"
+			})
+
+			It("returns a corresponding error", func() {
+				_, err := EurekaFromService(svc)
+				Expect(err).To(MatchError("Invalid Eureka registration method 'carrier-pigeon'"))
+			})
+		})
+	})
 })
 
+var _ = Describe("EurekaFromService with TLS", func() {
+	var (
+		server   *httptest.Server
+		svc      env.Service
+		username string
+		password string
+	)
+
+	BeforeEach(func() {
+		username, password = "", ""
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			username, password, _ = req.BasicAuth()
+			w.Write([]byte(`{"application": {"name": "APP", "instance": []}}`))
+		}))
+
+		svc = env.Service{
+			Credentials: map[string]interface{}{
+				"uri":     server.URL + "/eureka",
+				"ca_cert": certificatePEM(server.TLS.Certificates[0].Certificate[0]),
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("trusts the given CA", func() {
+		c, err := EurekaFromService(svc)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = c.App("app")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("does not trust other CAs", func() {
+		delete(svc.Credentials, "ca_cert")
+		c, _ := EurekaFromService(svc)
+
+		_, err := c.App("app")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("when SSL validation is skipped", func() {
+		BeforeEach(func() {
+			delete(svc.Credentials, "ca_cert")
+			svc.Credentials["skip_ssl_validation"] = true
+		})
+
+		It("connects anyway", func() {
+			c, _ := EurekaFromService(svc)
+			_, err := c.App("app")
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when the URI carries credentials", func() {
+		BeforeEach(func() {
+			svc.Credentials["uri"] = strings.Replace(server.URL, "https://", "https://some-user:some-password@", 1)
+		})
+
+		It("uses basic auth", func() {
+			c, _ := EurekaFromService(svc)
+			_, err := c.App("app")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(username).To(Equal("some-user"))
+			Expect(password).To(Equal("some-password"))
+		})
+	})
+
+	Context("when Eureka requires a client certificate", func() {
+		BeforeEach(func() {
+			server.TLS.ClientAuth = tls.RequireAnyClientCert
+		})
+
+		It("fails without one", func() {
+			c, _ := EurekaFromService(svc)
+			_, err := c.App("app")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("presents the given certificate", func() {
+			svc.Credentials["client_cert"], svc.Credentials["client_key"] = selfSignedPEM()
+
+			c, err := EurekaFromService(svc)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = c.App("app")
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when the CA certificate is invalid", func() {
+		BeforeEach(func() {
+			svc.Credentials["ca_cert"] = "not-a-cert"
+		})
+
+		It("returns a corresponding error", func() {
+			_, err := EurekaFromService(svc)
+			Expect(err).To(MatchError("Invalid Eureka CA certificate"))
+		})
+	})
+
+	Context("when the client key is missing", func() {
+		BeforeEach(func() {
+			svc.Credentials["client_cert"], _ = selfSignedPEM()
+		})
+
+		It("returns a corresponding error", func() {
+			_, err := EurekaFromService(svc)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Invalid Eureka client certificate"))
+		})
+	})
+})
+
+func certificatePEM(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func selfSignedPEM() (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "some-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	return certificatePEM(der), string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
 var vcapServicesEureka = `{
 	"user-provided": [
 	 {
@@ -0,0 +1,295 @@
+// This file was generated by counterfeiter
+package fake
+
+import (
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+type AMQPChannel struct {
+	ExchangeDeclareStub        func(name string, kind string, durable bool, autoDelete bool, internal bool, noWait bool, args amqp.Table) error
+	exchangeDeclareMutex       sync.RWMutex
+	exchangeDeclareArgsForCall []struct {
+		name       string
+		kind       string
+		durable    bool
+		autoDelete bool
+		internal   bool
+		noWait     bool
+		args       amqp.Table
+	}
+	exchangeDeclareReturns struct {
+		result1 error
+	}
+	QueueDeclareStub        func(name string, durable bool, autoDelete bool, exclusive bool, noWait bool, args amqp.Table) (amqp.Queue, error)
+	queueDeclareMutex       sync.RWMutex
+	queueDeclareArgsForCall []struct {
+		name       string
+		durable    bool
+		autoDelete bool
+		exclusive  bool
+		noWait     bool
+		args       amqp.Table
+	}
+	queueDeclareReturns struct {
+		result1 amqp.Queue
+		result2 error
+	}
+	QueueBindStub        func(name string, key string, exchange string, noWait bool, args amqp.Table) error
+	queueBindMutex       sync.RWMutex
+	queueBindArgsForCall []struct {
+		name     string
+		key      string
+		exchange string
+		noWait   bool
+		args     amqp.Table
+	}
+	queueBindReturns struct {
+		result1 error
+	}
+	ConsumeStub        func(queue string, consumer string, autoAck bool, exclusive bool, noLocal bool, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	consumeMutex       sync.RWMutex
+	consumeArgsForCall []struct {
+		queue     string
+		consumer  string
+		autoAck   bool
+		exclusive bool
+		noLocal   bool
+		noWait    bool
+		args      amqp.Table
+	}
+	consumeReturns struct {
+		result1 <-chan amqp.Delivery
+		result2 error
+	}
+	PublishStub        func(exchange string, key string, mandatory bool, immediate bool, msg amqp.Publishing) error
+	publishMutex       sync.RWMutex
+	publishArgsForCall []struct {
+		exchange  string
+		key       string
+		mandatory bool
+		immediate bool
+		msg       amqp.Publishing
+	}
+	publishReturns struct {
+		result1 error
+	}
+	CloseStub        func() error
+	closeMutex       sync.RWMutex
+	closeArgsForCall []struct{}
+	closeReturns     struct {
+		result1 error
+	}
+}
+
+func (fake *AMQPChannel) ExchangeDeclare(name string, kind string, durable bool, autoDelete bool, internal bool, noWait bool, args amqp.Table) error {
+	fake.exchangeDeclareMutex.Lock()
+	fake.exchangeDeclareArgsForCall = append(fake.exchangeDeclareArgsForCall, struct {
+		name       string
+		kind       string
+		durable    bool
+		autoDelete bool
+		internal   bool
+		noWait     bool
+		args       amqp.Table
+	}{name, kind, durable, autoDelete, internal, noWait, args})
+	fake.exchangeDeclareMutex.Unlock()
+	if fake.ExchangeDeclareStub != nil {
+		return fake.ExchangeDeclareStub(name, kind, durable, autoDelete, internal, noWait, args)
+	} else {
+		return fake.exchangeDeclareReturns.result1
+	}
+}
+
+func (fake *AMQPChannel) ExchangeDeclareCallCount() int {
+	fake.exchangeDeclareMutex.RLock()
+	defer fake.exchangeDeclareMutex.RUnlock()
+	return len(fake.exchangeDeclareArgsForCall)
+}
+
+func (fake *AMQPChannel) ExchangeDeclareArgsForCall(i int) (string, string, bool, bool, bool, bool, amqp.Table) {
+	fake.exchangeDeclareMutex.RLock()
+	defer fake.exchangeDeclareMutex.RUnlock()
+	return fake.exchangeDeclareArgsForCall[i].name, fake.exchangeDeclareArgsForCall[i].kind, fake.exchangeDeclareArgsForCall[i].durable, fake.exchangeDeclareArgsForCall[i].autoDelete, fake.exchangeDeclareArgsForCall[i].internal, fake.exchangeDeclareArgsForCall[i].noWait, fake.exchangeDeclareArgsForCall[i].args
+}
+
+func (fake *AMQPChannel) ExchangeDeclareReturns(result1 error) {
+	fake.ExchangeDeclareStub = nil
+	fake.exchangeDeclareReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *AMQPChannel) QueueDeclare(name string, durable bool, autoDelete bool, exclusive bool, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	fake.queueDeclareMutex.Lock()
+	fake.queueDeclareArgsForCall = append(fake.queueDeclareArgsForCall, struct {
+		name       string
+		durable    bool
+		autoDelete bool
+		exclusive  bool
+		noWait     bool
+		args       amqp.Table
+	}{name, durable, autoDelete, exclusive, noWait, args})
+	fake.queueDeclareMutex.Unlock()
+	if fake.QueueDeclareStub != nil {
+		return fake.QueueDeclareStub(name, durable, autoDelete, exclusive, noWait, args)
+	} else {
+		return fake.queueDeclareReturns.result1, fake.queueDeclareReturns.result2
+	}
+}
+
+func (fake *AMQPChannel) QueueDeclareCallCount() int {
+	fake.queueDeclareMutex.RLock()
+	defer fake.queueDeclareMutex.RUnlock()
+	return len(fake.queueDeclareArgsForCall)
+}
+
+func (fake *AMQPChannel) QueueDeclareArgsForCall(i int) (string, bool, bool, bool, bool, amqp.Table) {
+	fake.queueDeclareMutex.RLock()
+	defer fake.queueDeclareMutex.RUnlock()
+	return fake.queueDeclareArgsForCall[i].name, fake.queueDeclareArgsForCall[i].durable, fake.queueDeclareArgsForCall[i].autoDelete, fake.queueDeclareArgsForCall[i].exclusive, fake.queueDeclareArgsForCall[i].noWait, fake.queueDeclareArgsForCall[i].args
+}
+
+func (fake *AMQPChannel) QueueDeclareReturns(result1 amqp.Queue, result2 error) {
+	fake.QueueDeclareStub = nil
+	fake.queueDeclareReturns = struct {
+		result1 amqp.Queue
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *AMQPChannel) QueueBind(name string, key string, exchange string, noWait bool, args amqp.Table) error {
+	fake.queueBindMutex.Lock()
+	fake.queueBindArgsForCall = append(fake.queueBindArgsForCall, struct {
+		name     string
+		key      string
+		exchange string
+		noWait   bool
+		args     amqp.Table
+	}{name, key, exchange, noWait, args})
+	fake.queueBindMutex.Unlock()
+	if fake.QueueBindStub != nil {
+		return fake.QueueBindStub(name, key, exchange, noWait, args)
+	} else {
+		return fake.queueBindReturns.result1
+	}
+}
+
+func (fake *AMQPChannel) QueueBindCallCount() int {
+	fake.queueBindMutex.RLock()
+	defer fake.queueBindMutex.RUnlock()
+	return len(fake.queueBindArgsForCall)
+}
+
+func (fake *AMQPChannel) QueueBindArgsForCall(i int) (string, string, string, bool, amqp.Table) {
+	fake.queueBindMutex.RLock()
+	defer fake.queueBindMutex.RUnlock()
+	return fake.queueBindArgsForCall[i].name, fake.queueBindArgsForCall[i].key, fake.queueBindArgsForCall[i].exchange, fake.queueBindArgsForCall[i].noWait, fake.queueBindArgsForCall[i].args
+}
+
+func (fake *AMQPChannel) QueueBindReturns(result1 error) {
+	fake.QueueBindStub = nil
+	fake.queueBindReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *AMQPChannel) Consume(queue string, consumer string, autoAck bool, exclusive bool, noLocal bool, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	fake.consumeMutex.Lock()
+	fake.consumeArgsForCall = append(fake.consumeArgsForCall, struct {
+		queue     string
+		consumer  string
+		autoAck   bool
+		exclusive bool
+		noLocal   bool
+		noWait    bool
+		args      amqp.Table
+	}{queue, consumer, autoAck, exclusive, noLocal, noWait, args})
+	fake.consumeMutex.Unlock()
+	if fake.ConsumeStub != nil {
+		return fake.ConsumeStub(queue, consumer, autoAck, exclusive, noLocal, noWait, args)
+	} else {
+		return fake.consumeReturns.result1, fake.consumeReturns.result2
+	}
+}
+
+func (fake *AMQPChannel) ConsumeCallCount() int {
+	fake.consumeMutex.RLock()
+	defer fake.consumeMutex.RUnlock()
+	return len(fake.consumeArgsForCall)
+}
+
+func (fake *AMQPChannel) ConsumeArgsForCall(i int) (string, string, bool, bool, bool, bool, amqp.Table) {
+	fake.consumeMutex.RLock()
+	defer fake.consumeMutex.RUnlock()
+	return fake.consumeArgsForCall[i].queue, fake.consumeArgsForCall[i].consumer, fake.consumeArgsForCall[i].autoAck, fake.consumeArgsForCall[i].exclusive, fake.consumeArgsForCall[i].noLocal, fake.consumeArgsForCall[i].noWait, fake.consumeArgsForCall[i].args
+}
+
+func (fake *AMQPChannel) ConsumeReturns(result1 <-chan amqp.Delivery, result2 error) {
+	fake.ConsumeStub = nil
+	fake.consumeReturns = struct {
+		result1 <-chan amqp.Delivery
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *AMQPChannel) Publish(exchange string, key string, mandatory bool, immediate bool, msg amqp.Publishing) error {
+	fake.publishMutex.Lock()
+	fake.publishArgsForCall = append(fake.publishArgsForCall, struct {
+		exchange  string
+		key       string
+		mandatory bool
+		immediate bool
+		msg       amqp.Publishing
+	}{exchange, key, mandatory, immediate, msg})
+	fake.publishMutex.Unlock()
+	if fake.PublishStub != nil {
+		return fake.PublishStub(exchange, key, mandatory, immediate, msg)
+	} else {
+		return fake.publishReturns.result1
+	}
+}
+
+func (fake *AMQPChannel) PublishCallCount() int {
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	return len(fake.publishArgsForCall)
+}
+
+func (fake *AMQPChannel) PublishArgsForCall(i int) (string, string, bool, bool, amqp.Publishing) {
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	return fake.publishArgsForCall[i].exchange, fake.publishArgsForCall[i].key, fake.publishArgsForCall[i].mandatory, fake.publishArgsForCall[i].immediate, fake.publishArgsForCall[i].msg
+}
+
+func (fake *AMQPChannel) PublishReturns(result1 error) {
+	fake.PublishStub = nil
+	fake.publishReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *AMQPChannel) Close() error {
+	fake.closeMutex.Lock()
+	fake.closeArgsForCall = append(fake.closeArgsForCall, struct{}{})
+	fake.closeMutex.Unlock()
+	if fake.CloseStub != nil {
+		return fake.CloseStub()
+	} else {
+		return fake.closeReturns.result1
+	}
+}
+
+func (fake *AMQPChannel) CloseCallCount() int {
+	fake.closeMutex.RLock()
+	defer fake.closeMutex.RUnlock()
+	return len(fake.closeArgsForCall)
+}
+
+func (fake *AMQPChannel) CloseReturns(result1 error) {
+	fake.CloseStub = nil
+	fake.closeReturns = struct {
+		result1 error
+	}{result1}
+}
@@ -12,6 +12,26 @@ var DefaultRabbitTag = "rabbitmq"
 
 var amqpDialer = amqp.Dial
 
+type AMQPChannel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// rabbitChannel closes the underlying connection along with the channel.
+type rabbitChannel struct {
+	*amqp.Channel
+	conn *amqp.Connection
+}
+
+func (c *rabbitChannel) Close() error {
+	c.Channel.Close()
+	return c.conn.Close()
+}
+
 type RabbitMQ struct {
 	uri string
 }
@@ -20,6 +40,21 @@ func (r *RabbitMQ) Dial() (*amqp.Connection, error) {
 	return amqpDialer(r.uri)
 }
 
+func (r *RabbitMQ) Channel() (AMQPChannel, error) {
+	conn, err := r.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &rabbitChannel{ch, conn}, nil
+}
+
 func (r *RabbitMQ) URI() string {
 	return r.uri
 }
@@ -48,7 +83,7 @@ var rabbitLift = RabbitFromService
 
 func RabbitFromService(svc env.Service) (*RabbitMQ, error) {
 	uri, ok := svc.Credentials["uri"].(string)
-	if !ok || !strings.HasPrefix(uri, "amqp://") {
+	if !ok || !(strings.HasPrefix(uri, "amqp://") || strings.HasPrefix(uri, "amqps://")) {
 		return nil, errors.New("Invalid AMQP URI")
 	}
 	return &RabbitMQ{uri}, nil
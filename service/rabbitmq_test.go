@@ -152,6 +152,20 @@ var _ = Describe(".RabbitFromService", func() {
 		Expect(rabbit.uri).To(Equal("amqp://uri"))
 	})
 
+	Context("when service URI specifies amqps protocol", func() {
+		BeforeEach(func() {
+			svc.Credentials = map[string]interface{}{
+				"uri": "amqps://uri",
+			}
+		})
+
+		It("uses the correct URI", func() {
+			rabbit, err := RabbitFromService(svc)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rabbit.uri).To(Equal("amqps://uri"))
+		})
+	})
+
 	Context("when service credentials do NOT contain URI", func() {
 		BeforeEach(func() {
 			svc.Credentials = map[string]interface{}{}
@@ -256,6 +270,29 @@ var _ = Describe("RabbitMQ", func() {
 			})
 		})
 	})
+	Describe(".Channel", func() {
+		var origDialer = amqpDialer
+
+		AfterEach(func() {
+			amqpDialer = origDialer
+		})
+
+		Context("when the dialer fails", func() {
+			var expectedErr = errors.New("some-error")
+
+			BeforeEach(func() {
+				amqpDialer = func(uri string) (*amqp.Connection, error) {
+					return nil, expectedErr
+				}
+			})
+
+			It("returns the error", func() {
+				rabbit := &RabbitMQ{"amqp://rabbit.uri"}
+				_, err := rabbit.Channel()
+				Expect(err).To(Equal(expectedErr))
+			})
+		})
+	})
 })
 
 var vcapServices = `